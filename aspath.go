@@ -0,0 +1,184 @@
+package pwhois
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ASPathSegment is one hop of an AS path: a single ASN, or an unordered
+// AS_SET written as {64500,64501} that replaced aggregated hops.
+type ASPathSegment struct {
+	ASNs []uint32
+	Set  bool
+}
+
+// ASPath is a parsed AS path ordered from the first hop to the origin.
+// Prepends are kept as repeated segments; use Deprepended to collapse them.
+type ASPath []ASPathSegment
+
+// ParseASPath parses a whitespace-separated AS path such as "8220 1299 3356"
+// or "64500 64500 {64501,64502}". ASNs are 32-bit asplain values. Hops may be
+// separated by any run of spaces or tabs, and AS_SET members by commas or
+// whitespace. An empty value returns a nil path.
+func ParseASPath(value string) (ASPath, error) {
+	path, err := parseASPathSegments(value)
+	if err != nil {
+		return nil, invalidInputError(err.Error())
+	}
+	return path, nil
+}
+
+func parseASPathSegments(value string) (ASPath, error) {
+	var path ASPath
+	rest := strings.TrimSpace(value)
+	for rest != "" {
+		if strings.HasPrefix(rest, "{") {
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("AS path segment %d: unterminated AS_SET", len(path)+1)
+			}
+			members := strings.FieldsFunc(rest[1:end], func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+			if len(members) == 0 {
+				return nil, fmt.Errorf("AS path segment %d: empty AS_SET", len(path)+1)
+			}
+			segment := ASPathSegment{Set: true, ASNs: make([]uint32, 0, len(members))}
+			for _, member := range members {
+				asn, err := parseASPathASN(member)
+				if err != nil {
+					return nil, fmt.Errorf("AS path segment %d: %w", len(path)+1, err)
+				}
+				segment.ASNs = append(segment.ASNs, asn)
+			}
+			path = append(path, segment)
+			rest = strings.TrimSpace(rest[end+1:])
+			continue
+		}
+
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			end = len(rest)
+		}
+		asn, err := parseASPathASN(rest[:end])
+		if err != nil {
+			return nil, fmt.Errorf("AS path segment %d: %w", len(path)+1, err)
+		}
+		path = append(path, ASPathSegment{ASNs: []uint32{asn}})
+		rest = strings.TrimSpace(rest[end:])
+	}
+	return path, nil
+}
+
+func parseASPathASN(value string) (uint32, error) {
+	asn, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ASN %q", value)
+	}
+	return uint32(asn), nil
+}
+
+// Origin returns the originating ASN. It reports false for an empty path or
+// when the origin is an AS_SET with more than one member.
+func (path ASPath) Origin() (uint32, bool) {
+	if len(path) == 0 {
+		return 0, false
+	}
+	return path[len(path)-1].single()
+}
+
+// FirstHop returns the ASN nearest the observer. It reports false for an
+// empty path or when the first hop is an AS_SET with more than one member.
+func (path ASPath) FirstHop() (uint32, bool) {
+	if len(path) == 0 {
+		return 0, false
+	}
+	return path[0].single()
+}
+
+func (segment ASPathSegment) single() (uint32, bool) {
+	if len(segment.ASNs) != 1 {
+		return 0, false
+	}
+	return segment.ASNs[0], true
+}
+
+// Deprepended returns the path with consecutive repeats of the same ASN
+// collapsed to one segment. AS_SET segments are kept as they are, and
+// segments without ASNs are dropped.
+func (path ASPath) Deprepended() ASPath {
+	var deprepended ASPath
+	for _, segment := range path {
+		if len(segment.ASNs) == 0 {
+			continue
+		}
+		if !segment.Set && len(deprepended) > 0 {
+			previous := deprepended[len(deprepended)-1]
+			if !previous.Set && previous.ASNs[0] == segment.ASNs[0] {
+				continue
+			}
+		}
+		deprepended = append(deprepended, segment)
+	}
+	return deprepended
+}
+
+// Len returns the path length as BGP best-path selection counts it: one per
+// ASN in a sequence, including prepends, and one per AS_SET regardless of
+// its size. Segments without ASNs are not counted.
+func (path ASPath) Len() int {
+	length := 0
+	for _, segment := range path {
+		switch {
+		case len(segment.ASNs) == 0:
+		case segment.Set:
+			length++
+		default:
+			length += len(segment.ASNs)
+		}
+	}
+	return length
+}
+
+// Transit returns the distinct ASNs strictly between the first hop and the
+// origin, in path order, after prepends are removed. AS_SET members are
+// included.
+func (path ASPath) Transit() []uint32 {
+	deprepended := path.Deprepended()
+	if len(deprepended) < 3 {
+		return nil
+	}
+
+	var transit []uint32
+	seen := make(map[uint32]bool)
+	for _, segment := range deprepended[1 : len(deprepended)-1] {
+		for _, asn := range segment.ASNs {
+			if !seen[asn] {
+				seen[asn] = true
+				transit = append(transit, asn)
+			}
+		}
+	}
+	return transit
+}
+
+// String formats the path in the form ParseASPath accepts. Every ASN of a
+// sequence segment is written, and segments without ASNs are skipped.
+func (path ASPath) String() string {
+	parts := make([]string, 0, len(path))
+	for _, segment := range path {
+		if len(segment.ASNs) == 0 {
+			continue
+		}
+		members := make([]string, 0, len(segment.ASNs))
+		for _, asn := range segment.ASNs {
+			members = append(members, strconv.FormatUint(uint64(asn), 10))
+		}
+		if segment.Set {
+			parts = append(parts, "{"+strings.Join(members, ",")+"}")
+			continue
+		}
+		parts = append(parts, members...)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,126 @@
+package pwhois
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseASPath(t *testing.T) {
+	tests := []struct {
+		value string
+		want  ASPath
+	}{
+		{value: "", want: nil},
+		{value: "8220 1299 3356", want: ASPath{{ASNs: []uint32{8220}}, {ASNs: []uint32{1299}}, {ASNs: []uint32{3356}}}},
+		{value: "  4200000000   64500 ", want: ASPath{{ASNs: []uint32{4200000000}}, {ASNs: []uint32{64500}}}},
+		{value: "64500 {64501,64502}", want: ASPath{{ASNs: []uint32{64500}}, {ASNs: []uint32{64501, 64502}, Set: true}}},
+		{value: "{64501, 64502} 64500", want: ASPath{{ASNs: []uint32{64501, 64502}, Set: true}, {ASNs: []uint32{64500}}}},
+		{value: "64500\t64501 \t {64502,\t64503}", want: ASPath{{ASNs: []uint32{64500}}, {ASNs: []uint32{64501}}, {ASNs: []uint32{64502, 64503}, Set: true}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseASPath(test.value)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("path = %+v, want %+v", got, test.want)
+			}
+			if test.want != nil {
+				if reparsed, _ := ParseASPath(got.String()); !reflect.DeepEqual(reparsed, got) {
+					t.Errorf("String %q does not round-trip", got.String())
+				}
+			}
+		})
+	}
+
+	for _, value := range []string{"64500 AS64501", "4294967296", "-1", "64500 {64501", "{}"} {
+		if _, err := ParseASPath(value); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("ParseASPath(%q) error = %v, want ErrInvalidInput", value, err)
+		}
+	}
+}
+
+func TestASPathHelpers(t *testing.T) {
+	path, err := ParseASPath("64496 64496 64497 64498 64497 {64499,64500} 64510 64510 64510")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if origin, ok := path.Origin(); !ok || origin != 64510 {
+		t.Errorf("origin = %d %v, want 64510", origin, ok)
+	}
+	if firstHop, ok := path.FirstHop(); !ok || firstHop != 64496 {
+		t.Errorf("first hop = %d %v, want 64496", firstHop, ok)
+	}
+	if got, want := path.Len(), 9; got != want {
+		t.Errorf("length = %d, want %d", got, want)
+	}
+	if got, want := path.Deprepended().String(), "64496 64497 64498 64497 {64499,64500} 64510"; got != want {
+		t.Errorf("deprepended = %q, want %q", got, want)
+	}
+	if got, want := path.Transit(), []uint32{64497, 64498, 64499, 64500}; !reflect.DeepEqual(got, want) {
+		t.Errorf("transit = %v, want %v", got, want)
+	}
+
+	aggregated, _ := ParseASPath("64496 {64499,64500}")
+	if _, ok := aggregated.Origin(); ok {
+		t.Error("AS_SET origin reported a single ASN")
+	}
+	if transit := aggregated.Transit(); transit != nil {
+		t.Errorf("two-hop transit = %v, want none", transit)
+	}
+	if _, ok := ASPath(nil).FirstHop(); ok {
+		t.Error("empty path reported a first hop")
+	}
+
+	withEmpty := ASPath{{}, {ASNs: []uint32{64496}}, {Set: true}, {ASNs: []uint32{64496}}, {}}
+	if got, want := withEmpty.String(), "64496 64496"; got != want {
+		t.Errorf("path with empty segments = %q, want %q", got, want)
+	}
+	if got, want := withEmpty.Deprepended().String(), "64496"; got != want {
+		t.Errorf("deprepended path with empty segments = %q, want %q", got, want)
+	}
+	if got, want := withEmpty.Len(), 2; got != want {
+		t.Errorf("length of path with empty segments = %d, want %d", got, want)
+	}
+
+	sequence := ASPath{{ASNs: []uint32{64496, 64497, 64498}}, {Set: true, ASNs: []uint32{64499, 64500}}}
+	if got, want := sequence.String(), "64496 64497 64498 {64499,64500}"; got != want {
+		t.Errorf("multi-ASN sequence segment = %q, want %q", got, want)
+	}
+	if got, want := sequence.Len(), 4; got != want {
+		t.Errorf("length of multi-ASN sequence segment = %d, want %d", got, want)
+	}
+}
+
+func TestParseIpResponseFillsASPath(t *testing.T) {
+	records, err := parseIpResponse("IP: 192.0.2.1\nAS-Path: 64500 64500 4200000000\n")
+	if err != nil {
+		t.Fatalf("parse IP response: %v", err)
+	}
+	if origin, ok := records[0].ASPath.Origin(); !ok || origin != 4200000000 || records[0].ASPath.Len() != 3 {
+		t.Fatalf("AS path = %+v", records[0].ASPath)
+	}
+	if records[0].AsnPath != "64500 64500 4200000000" {
+		t.Errorf("raw AS path = %q", records[0].AsnPath)
+	}
+
+	records, err = parseIpResponse("IP: 192.0.2.1\nAS-Path: 64500\t64501  64502\n")
+	if err != nil {
+		t.Fatalf("parse tab-separated AS path: %v", err)
+	}
+	if records[0].ASPath.Len() != 3 {
+		t.Errorf("tab-separated AS path = %+v", records[0].ASPath)
+	}
+
+	records, err = parseIpResponse("IP: 192.0.2.1\nOrigin-AS: 64500\nAS-Path: 64500 transit\n")
+	if err != nil {
+		t.Fatalf("parse odd AS path: %v", err)
+	}
+	if len(records) != 1 || records[0].OriginAS != "64500" || records[0].AsnPath != "64500 transit" || records[0].ASPath != nil {
+		t.Errorf("odd AS path record = %+v, want the record with only AsnPath", records)
+	}
+}
@@ -0,0 +1,159 @@
+package pwhois
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// BulkIPOptions controls how LookupIPBulk splits and paces its batches.
+type BulkIPOptions struct {
+	// BatchSize is the number of addresses sent in each begin/end batch. Zero
+	// or a value above the server's BatchMaxSize uses BatchMaxSize.
+	BatchSize int
+	// Concurrency bounds the number of batch queries in flight. Zero uses 1.
+	Concurrency int
+	// Interval is the minimum time between starting consecutive batch
+	// queries. Zero disables pacing.
+	Interval time.Duration
+}
+
+func (options BulkIPOptions) normalize(server WhoisServer) (BulkIPOptions, error) {
+	if options.BatchSize < 0 || options.Concurrency < 0 || options.Interval < 0 {
+		return BulkIPOptions{}, invalidInputError("bulk IP options cannot be negative")
+	}
+	if options.BatchSize == 0 || options.BatchSize > server.BatchMaxSize {
+		options.BatchSize = server.BatchMaxSize
+	}
+	if options.Concurrency == 0 {
+		options.Concurrency = 1
+	}
+	return options, nil
+}
+
+// LookupIPBulk looks up any number of IP addresses by splitting them into
+// batches no larger than BatchMaxSize. Batches run with bounded concurrency
+// and optional pacing, each on its own connection.
+//
+// Addresses are canonicalized and deduplicated as by ValidateIPs, so each
+// distinct address is queried once. The result has one entry per distinct
+// input string; spellings of the same address share its result. Addresses
+// the server did not answer have IPLookupNoAnswer status. A failed batch or
+// an address rejected by ValidateIPs is reported as IPLookupFailed on the
+// affected addresses rather than aborting the run; if ctx ends, batches that
+// have not started fail with ErrCanceled or ErrTimeout. The returned error is
+// reserved for invalid options or a nil context.
+func (client *Client) LookupIPBulk(ctx context.Context, ips []string, options BulkIPOptions) (map[string]IPLookupResult, error) {
+	if ctx == nil {
+		return nil, invalidInputError("lookup context is required")
+	}
+	options, err := options.normalize(client.server)
+	if err != nil {
+		return nil, err
+	}
+
+	validation := ValidateIPs(ips)
+	addresses := validation.Addresses()
+	byAddress := make(map[netip.Addr]IPLookupResult, len(addresses))
+
+	var (
+		mu        sync.Mutex
+		waitGroup sync.WaitGroup
+	)
+	record := func(chunkResults map[netip.Addr]IPLookupResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		for address, result := range chunkResults {
+			byAddress[address] = result
+		}
+	}
+	slots := make(chan struct{}, options.Concurrency)
+	var nextStart time.Time
+
+	for start := 0; start < len(addresses); start += options.BatchSize {
+		chunk := validation.Accepted[start:min(start+options.BatchSize, len(addresses))]
+
+		err := waitUntil(ctx, nextStart)
+		if err == nil {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		if err != nil {
+			record(failedIPLookups(chunk, client.server.operationError("lookup IP", classifyTransportError(err))))
+			continue
+		}
+		if options.Interval > 0 {
+			nextStart = time.Now().Add(options.Interval)
+		}
+
+		waitGroup.Add(1)
+		go func(chunk []IPInput) {
+			defer waitGroup.Done()
+			defer func() { <-slots }()
+			record(client.lookupIPChunk(ctx, chunk))
+		}(chunk)
+	}
+	waitGroup.Wait()
+
+	// Every spelling of a canonical address shares its result.
+	results := make(map[string]IPLookupResult, len(ips))
+	for _, input := range validation.Rejected {
+		results[input.Value] = IPLookupResult{
+			Input:  input.Value,
+			Status: IPLookupFailed,
+			Err:    &IPValidationError{Rejected: []IPInput{input}},
+		}
+	}
+	for _, inputs := range [][]IPInput{validation.Accepted, validation.Duplicates} {
+		for _, input := range inputs {
+			result := byAddress[input.Addr]
+			result.Input = input.Value
+			results[input.Value] = result
+		}
+	}
+	return results, nil
+}
+
+// lookupIPChunk runs one batch of accepted inputs and returns its correlated
+// results.
+func (client *Client) lookupIPChunk(ctx context.Context, chunk []IPInput) map[netip.Addr]IPLookupResult {
+	addresses := make([]string, 0, len(chunk))
+	for _, input := range chunk {
+		addresses = append(addresses, input.Addr.String())
+	}
+
+	batch, err := client.LookupIPBatch(ctx, addresses...)
+	if err != nil {
+		return failedIPLookups(chunk, err)
+	}
+	return batch.Results
+}
+
+func failedIPLookups(chunk []IPInput, err error) map[netip.Addr]IPLookupResult {
+	results := make(map[netip.Addr]IPLookupResult, len(chunk))
+	for _, input := range chunk {
+		results[input.Addr] = IPLookupResult{Input: input.Value, Addr: input.Addr, Status: IPLookupFailed, Err: err}
+	}
+	return results
+}
+
+// waitUntil blocks until deadline or until ctx ends.
+func waitUntil(ctx context.Context, deadline time.Time) error {
+	delay := time.Until(deadline)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
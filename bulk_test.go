@@ -0,0 +1,273 @@
+package pwhois
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ipBatchServer is a multi-connection, loopback-only IP query server. It
+// parses single and begin/end batch queries, records every batch it served,
+// and tracks peak concurrency.
+type ipBatchServer struct {
+	mu        sync.Mutex
+	batches   [][]string
+	starts    []time.Time
+	active    int
+	maxActive int
+}
+
+func startIPBatchServer(t *testing.T, respond func(ips []string) string) (WhoisServer, *ipBatchServer) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	state := &ipBatchServer{}
+	var connections sync.WaitGroup
+	t.Cleanup(func() {
+		_ = listener.Close()
+		connections.Wait()
+	})
+
+	connections.Add(1)
+	go func() {
+		defer connections.Done()
+		for {
+			connection, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			connections.Add(1)
+			go func() {
+				defer connections.Done()
+				defer connection.Close()
+				_ = connection.SetDeadline(time.Now().Add(3 * time.Second))
+				ips, readErr := readIPQuery(bufio.NewReader(connection))
+				if readErr != nil {
+					return
+				}
+				state.begin(ips)
+				defer state.end()
+				_, _ = connection.Write([]byte(respond(ips)))
+			}()
+		}
+	}()
+
+	address := listener.Addr().(*net.TCPAddr)
+	return WhoisServer{Server: address.IP.String(), Port: address.Port, Timeout: 2 * time.Second}, state
+}
+
+func readIPQuery(reader *bufio.Reader) ([]string, error) {
+	readLine := func() (string, error) {
+		line, err := reader.ReadString('\n')
+		return strings.TrimSuffix(line, "\n"), err
+	}
+	if _, err := readLine(); err != nil {
+		return nil, err
+	}
+	first, err := readLine()
+	if err != nil {
+		return nil, err
+	}
+	if first+"\n" != BatchStart {
+		return []string{first}, nil
+	}
+	var ips []string
+	for {
+		line, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line+"\n" == BatchEnd {
+			return ips, nil
+		}
+		ips = append(ips, line)
+	}
+}
+
+func (state *ipBatchServer) begin(ips []string) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.batches = append(state.batches, ips)
+	state.starts = append(state.starts, time.Now())
+	state.active++
+	state.maxActive = max(state.maxActive, state.active)
+}
+
+func (state *ipBatchServer) end() {
+	time.Sleep(20 * time.Millisecond)
+	state.mu.Lock()
+	state.active--
+	state.mu.Unlock()
+}
+
+func ipRecords(ips []string) string {
+	records := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		records = append(records, fmt.Sprintf("IP: %s\nOrigin-AS: 64500\n", ip))
+	}
+	return strings.Join(records, "\n")
+}
+
+func TestLookupIPBulkChunksAndReportsPerAddress(t *testing.T) {
+	server, state := startIPBatchServer(t, func(ips []string) string {
+		switch {
+		case ips[0] == "192.0.2.4":
+			return "Error: query limit exceeded\n"
+		case ips[0] == "192.0.2.7":
+			return ipRecords(nil) + "IP: 192.0.2.8\nOrigin-AS: 64500\n"
+		default:
+			return ipRecords(ips)
+		}
+	})
+	client, err := NewClient(ClientConfig{Server: server})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	inputs := []string{
+		"192.0.2.1", "192.0.2.2", "192.0.2.3",
+		"192.0.2.4", "192.0.2.5", "192.0.2.6",
+		"192.0.2.7", "192.0.2.8", "not-an-ip", "192.0.2.1",
+	}
+	results, err := client.LookupIPBulk(context.Background(), inputs, BulkIPOptions{BatchSize: 3, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("bulk lookup: %v", err)
+	}
+	if got, want := len(results), 9; got != want {
+		t.Fatalf("result count = %d, want %d", got, want)
+	}
+
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.8"} {
+		if result := results[ip]; result.Status != IPLookupAnswered || result.Record.IP != ip || result.Input != ip {
+			t.Errorf("%s result = %+v, want answered", ip, result)
+		}
+	}
+	for _, ip := range []string{"192.0.2.4", "192.0.2.5", "192.0.2.6"} {
+		if result := results[ip]; result.Status != IPLookupFailed || !errors.Is(result.Err, ErrRateLimited) {
+			t.Errorf("%s result = %+v, want rate-limited failure", ip, result)
+		}
+	}
+	if result := results["192.0.2.7"]; result.Status != IPLookupNoAnswer || result.Err != nil {
+		t.Errorf("unanswered result = %+v, want no answer", result)
+	}
+	if result := results["not-an-ip"]; result.Status != IPLookupFailed || !errors.Is(result.Err, ErrInvalidInput) {
+		t.Errorf("invalid result = %+v, want ErrInvalidInput", result)
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if got, want := len(state.batches), 3; got != want {
+		t.Fatalf("served batches = %d, want %d", got, want)
+	}
+	for _, batch := range state.batches {
+		if len(batch) > 3 {
+			t.Errorf("batch size = %d, want at most 3", len(batch))
+		}
+	}
+	if state.maxActive > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", state.maxActive)
+	}
+}
+
+func TestLookupIPBulkQueriesCanonicalAddressesOnce(t *testing.T) {
+	server, state := startIPBatchServer(t, ipRecords)
+	client, err := NewClient(ClientConfig{Server: server})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	inputs := []string{"::ffff:192.0.2.1", "2001:DB8::1", "192.0.2.1", "2001:db8:0::1"}
+	results, err := client.LookupIPBulk(context.Background(), inputs, BulkIPOptions{})
+	if err != nil {
+		t.Fatalf("bulk lookup: %v", err)
+	}
+	for _, input := range inputs {
+		result := results[input]
+		if result.Status != IPLookupAnswered || result.Input != input || result.Addr.Is4In6() {
+			t.Errorf("%s result = %+v, want answered canonical address", input, result)
+		}
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if want := [][]string{{"192.0.2.1", "2001:db8::1"}}; !reflect.DeepEqual(state.batches, want) {
+		t.Errorf("served batches = %v, want %v", state.batches, want)
+	}
+}
+
+func TestLookupIPBulkPacesBatches(t *testing.T) {
+	server, state := startIPBatchServer(t, ipRecords)
+	client, err := NewClient(ClientConfig{Server: server})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	const interval = 60 * time.Millisecond
+	inputs := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}
+	if _, err := client.LookupIPBulk(context.Background(), inputs, BulkIPOptions{BatchSize: 1, Concurrency: 3, Interval: interval}); err != nil {
+		t.Fatalf("bulk lookup: %v", err)
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if len(state.starts) != 3 {
+		t.Fatalf("served batches = %d, want 3", len(state.starts))
+	}
+	if spread := state.starts[2].Sub(state.starts[0]); spread < 2*interval-10*time.Millisecond {
+		t.Errorf("batch start spread = %s, want at least %s", spread, 2*interval)
+	}
+}
+
+func TestLookupIPBulkCanceledContextFailsEveryAddress(t *testing.T) {
+	client, err := NewClient(ClientConfig{Server: WhoisServer{Server: "127.0.0.1", Port: 43}})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := client.LookupIPBulk(ctx, []string{"192.0.2.1", "192.0.2.2"}, BulkIPOptions{BatchSize: 1})
+	if err != nil {
+		t.Fatalf("bulk lookup: %v", err)
+	}
+	for ip, result := range results {
+		if result.Status != IPLookupFailed || !errors.Is(result.Err, ErrCanceled) {
+			t.Errorf("%s result = %+v, want ErrCanceled", ip, result)
+		}
+	}
+
+	if _, err := client.LookupIPBulk(context.Background(), nil, BulkIPOptions{Concurrency: -1}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("negative concurrency error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestLookupIPBatchReportsEmptyResponseAsNoAnswer(t *testing.T) {
+	server, _ := startIPBatchServer(t, func([]string) string { return "" })
+	client, err := NewClient(ClientConfig{Server: server})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	batch, err := client.LookupIPBatch(context.Background(), "192.0.2.1", "2001:db8::1", "bad")
+	if err != nil {
+		t.Fatalf("batch lookup: %v", err)
+	}
+	if len(batch.Requested) != 2 || len(batch.Validation.Rejected) != 1 {
+		t.Fatalf("batch = %+v, want two requested and one rejected input", batch)
+	}
+	for _, address := range batch.Requested {
+		if result := batch.Results[address]; result.Status != IPLookupNoAnswer {
+			t.Errorf("%s result = %+v, want no answer", address, result)
+		}
+	}
+}
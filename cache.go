@@ -188,6 +188,7 @@ const (
 	ProviderErrorResponseTooLarge  ProviderErrorClass = "response_too_large"
 	ProviderErrorMalformedResponse ProviderErrorClass = "malformed_response"
 	ProviderErrorNoRecords         ProviderErrorClass = "no_records"
+	ProviderErrorCircuitOpen       ProviderErrorClass = "circuit_open"
 	ProviderErrorUnknown           ProviderErrorClass = "unknown"
 )
 
@@ -215,6 +216,8 @@ func ClassifyProviderError(err error) ProviderErrorClass {
 		return ProviderErrorMalformedResponse
 	case errors.Is(err, ErrNoRecords):
 		return ProviderErrorNoRecords
+	case errors.Is(err, ErrCircuitOpen):
+		return ProviderErrorCircuitOpen
 	default:
 		return ProviderErrorUnknown
 	}
@@ -238,6 +241,8 @@ func providerErrorSentinel(class ProviderErrorClass) error {
 		return ErrMalformedResponse
 	case ProviderErrorNoRecords:
 		return ErrNoRecords
+	case ProviderErrorCircuitOpen:
+		return ErrCircuitOpen
 	default:
 		return nil
 	}
@@ -268,6 +273,16 @@ type CacheProvenance struct {
 	Details  map[string]string `json:"details,omitempty"`
 }
 
+// CacheProvenance describes server as the provenance of a cached PWHOIS
+// result, using "pwhois" as provider and protocol.
+func (server *WhoisServer) CacheProvenance() CacheProvenance {
+	return CacheProvenance{
+		Provider: "pwhois",
+		Endpoint: strings.ToLower(server.ServerAddressString()),
+		Protocol: "pwhois",
+	}
+}
+
 // CacheEnvelope is the versioned value stored by a Cache implementation.
 // NormalizedResult is parsed, bounded JSON; the contract has no raw-response
 // field so backends do not retain provider payloads by default.
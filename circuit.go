@@ -0,0 +1,258 @@
+package pwhois
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultCircuitFailureThreshold is the consecutive transport failures that
+// open a circuit when CircuitBreakerConfig.FailureThreshold is zero.
+const DefaultCircuitFailureThreshold int = 5
+
+// DefaultCircuitOpenTimeout is how long a circuit stays open before a probe
+// when CircuitBreakerConfig.OpenTimeout is zero.
+const DefaultCircuitOpenTimeout time.Duration = 30 * time.Second
+
+// CircuitState is the state of one endpoint's circuit.
+type CircuitState string
+
+const (
+	// CircuitClosed lets every query through.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen fails every query with ErrCircuitOpen without connecting.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets one probe query through. Its outcome closes or
+	// reopens the circuit.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitStateChange reports a circuit moving between states.
+type CircuitStateChange struct {
+	// Endpoint is the "host:port" string of the server.
+	Endpoint string
+	From     CircuitState
+	To       CircuitState
+	At       time.Time
+}
+
+// CircuitBreakerConfig configures a CircuitBreaker.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive ErrConnection or
+	// ErrTimeout failures that opens an endpoint's circuit. Zero uses
+	// DefaultCircuitFailureThreshold.
+	FailureThreshold int
+	// OpenTimeout is how long a circuit stays open before the next query is
+	// let through as a half-open probe. Zero uses DefaultCircuitOpenTimeout.
+	OpenTimeout time.Duration
+	// OnStateChange, when set, is called after every state change, outside
+	// the breaker's lock. It must not block.
+	OnStateChange func(CircuitStateChange)
+}
+
+// CircuitBreaker stops queries to an endpoint that keeps failing in
+// transport, so workers fail fast with ErrCircuitOpen instead of each
+// waiting for the full Timeout. One CircuitBreaker is safe for concurrent use
+// and tracks every endpoint it sees separately; share it between the
+// clients and pools that talk to the same servers.
+//
+// Only ErrConnection and ErrTimeout count as failures. A query that reaches
+// the server and gets any answer, including ErrRateLimited or ErrNoRecords,
+// closes the circuit. Cancellation by the caller counts as neither.
+type CircuitBreaker struct {
+	threshold     int
+	openTimeout   time.Duration
+	onStateChange func(CircuitStateChange)
+
+	mu        sync.Mutex
+	endpoints map[string]*circuit
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker validates configuration and returns a CircuitBreaker with
+// every circuit closed.
+func NewCircuitBreaker(config CircuitBreakerConfig) (*CircuitBreaker, error) {
+	if config.FailureThreshold < 0 || config.OpenTimeout < 0 {
+		return nil, invalidInputError("circuit breaker values cannot be negative")
+	}
+	breaker := &CircuitBreaker{
+		threshold:     config.FailureThreshold,
+		openTimeout:   config.OpenTimeout,
+		onStateChange: config.OnStateChange,
+		endpoints:     make(map[string]*circuit),
+	}
+	if breaker.threshold == 0 {
+		breaker.threshold = DefaultCircuitFailureThreshold
+	}
+	if breaker.openTimeout == 0 {
+		breaker.openTimeout = DefaultCircuitOpenTimeout
+	}
+	return breaker, nil
+}
+
+// Allow reports whether a query to endpoint may proceed. It returns
+// ErrCircuitOpen while the circuit is open, and while another query is
+// probing a half-open circuit. Once OpenTimeout has passed, the next call
+// moves the circuit to half-open and lets that query probe. Every allowed
+// query must be followed by Record.
+//
+// Client, Pool, and FailoverClient call Allow and Record themselves
+// when configured with a CircuitBreaker; call them directly only around the
+// channel-based WhoisServer lookups.
+func (breaker *CircuitBreaker) Allow(endpoint string) error {
+	_, err := breaker.allow(endpoint)
+	return err
+}
+
+// Record reports the outcome of a query that Allow let through. While a
+// circuit is half-open, Record cannot tell the probe from a query Allow let
+// through before the circuit opened, so it treats the outcome as the probe's.
+func (breaker *CircuitBreaker) Record(endpoint string, err error) {
+	breaker.mu.Lock()
+	state := breaker.circuitLocked(endpoint)
+	probe := state.state == CircuitHalfOpen && state.probing
+	breaker.mu.Unlock()
+	breaker.record(endpoint, probe, err)
+}
+
+// allow is Allow that also reports whether the query is the half-open probe.
+func (breaker *CircuitBreaker) allow(endpoint string) (bool, error) {
+	now := time.Now()
+	breaker.mu.Lock()
+	state := breaker.circuitLocked(endpoint)
+	var change *CircuitStateChange
+	var probe bool
+	var err error
+	switch state.state {
+	case CircuitOpen:
+		if now.Sub(state.openedAt) < breaker.openTimeout {
+			err = fmt.Errorf("%w: retry after %s", ErrCircuitOpen, state.openedAt.Add(breaker.openTimeout).Format(time.RFC3339))
+			break
+		}
+		change = state.moveLocked(endpoint, CircuitHalfOpen, now)
+		state.probing = true
+		probe = true
+	case CircuitHalfOpen:
+		if state.probing {
+			err = fmt.Errorf("%w: probe in progress", ErrCircuitOpen)
+			break
+		}
+		state.probing = true
+		probe = true
+	}
+	breaker.mu.Unlock()
+
+	breaker.notify(change)
+	return probe, err
+}
+
+// record applies a query's outcome. Only the probe's failure or cancellation
+// settles a half-open circuit; a query let through before the circuit opened
+// neither reopens it nor frees the probe, and a failure while already open
+// does not postpone the probe.
+func (breaker *CircuitBreaker) record(endpoint string, probe bool, err error) {
+	now := time.Now()
+	breaker.mu.Lock()
+	state := breaker.circuitLocked(endpoint)
+	probe = probe && state.state == CircuitHalfOpen
+	var change *CircuitStateChange
+	switch ClassifyProviderError(err) {
+	case ProviderErrorConnection, ProviderErrorTimeout:
+		state.failures++
+		if probe || (state.state == CircuitClosed && state.failures >= breaker.threshold) {
+			change = state.moveLocked(endpoint, CircuitOpen, now)
+			state.openedAt = now
+			state.probing = false
+		}
+	case ProviderErrorCanceled, ProviderErrorCircuitOpen:
+		if probe {
+			state.probing = false
+		}
+	default:
+		state.failures = 0
+		state.probing = false
+		change = state.moveLocked(endpoint, CircuitClosed, now)
+	}
+	breaker.mu.Unlock()
+
+	breaker.notify(change)
+}
+
+// State returns endpoint's current circuit state. An open circuit whose
+// OpenTimeout has passed is reported as open until a query probes it.
+func (breaker *CircuitBreaker) State(endpoint string) CircuitState {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	return breaker.circuitLocked(endpoint).state
+}
+
+func (breaker *CircuitBreaker) circuitLocked(endpoint string) *circuit {
+	state, ok := breaker.endpoints[endpoint]
+	if !ok {
+		state = &circuit{state: CircuitClosed}
+		breaker.endpoints[endpoint] = state
+	}
+	return state
+}
+
+// moveLocked changes state and returns the change to report, or nil when the
+// state is unchanged.
+func (state *circuit) moveLocked(endpoint string, to CircuitState, now time.Time) *CircuitStateChange {
+	if state.state == to {
+		return nil
+	}
+	change := &CircuitStateChange{Endpoint: endpoint, From: state.state, To: to, At: now}
+	state.state = to
+	return change
+}
+
+func (breaker *CircuitBreaker) notify(change *CircuitStateChange) {
+	if change != nil && breaker.onStateChange != nil {
+		breaker.onStateChange(*change)
+	}
+}
+
+// allowQuery applies Allow to server, wrapping a refusal in *OperationError,
+// and reports whether the query is the half-open probe. A nil breaker allows
+// every query.
+func (breaker *CircuitBreaker) allowQuery(server WhoisServer, operation string) (bool, error) {
+	if breaker == nil {
+		return false, nil
+	}
+	probe, err := breaker.allow(server.ServerAddressString())
+	if err != nil {
+		return false, server.operationError(operation, err)
+	}
+	return probe, nil
+}
+
+// abandonQuery records that a query allowQuery let through was never sent, so
+// it neither counts as a failure nor keeps a half-open probe. A nil breaker
+// ignores it.
+func (breaker *CircuitBreaker) abandonQuery(server WhoisServer, probe bool) {
+	if breaker == nil {
+		return
+	}
+	breaker.record(server.ServerAddressString(), probe, ErrCanceled)
+}
+
+// recordQuery applies Record to server. A query whose context the caller
+// canceled or let expire is recorded as a cancellation whatever error it
+// produced, since a short caller deadline says nothing about the endpoint. A
+// nil breaker ignores it.
+func (breaker *CircuitBreaker) recordQuery(ctx context.Context, server WhoisServer, probe bool, err error) {
+	if breaker == nil {
+		return
+	}
+	if err != nil && callerExpired(ctx) {
+		err = ErrCanceled
+	}
+	breaker.record(server.ServerAddressString(), probe, err)
+}
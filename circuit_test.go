@@ -0,0 +1,314 @@
+package pwhois
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// circuitChanges collects state changes reported by a CircuitBreaker.
+type circuitChanges struct {
+	mu      sync.Mutex
+	changes []CircuitStateChange
+}
+
+func (recorder *circuitChanges) record(change CircuitStateChange) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.changes = append(recorder.changes, change)
+}
+
+func (recorder *circuitChanges) transitions() []CircuitState {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	var states []CircuitState
+	for _, change := range recorder.changes {
+		states = append(states, change.To)
+	}
+	return states
+}
+
+func TestCircuitBreakerOpensAndProbes(t *testing.T) {
+	recorder := &circuitChanges{}
+	breaker, err := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: 30 * time.Millisecond, OnStateChange: recorder.record})
+	if err != nil {
+		t.Fatalf("new circuit breaker: %v", err)
+	}
+	const endpoint = "192.0.2.1:43"
+
+	for _, outcome := range []error{lookupFailure(ErrTimeout), nil, lookupFailure(ErrConnection), lookupFailure(ErrRateLimited), lookupFailure(ErrCanceled)} {
+		if err := breaker.Allow(endpoint); err != nil {
+			t.Fatalf("allow while closed: %v", err)
+		}
+		breaker.Record(endpoint, outcome)
+	}
+	if state := breaker.State(endpoint); state != CircuitClosed {
+		t.Fatalf("state after interleaved failures = %s, want closed", state)
+	}
+
+	for index := 0; index < 2; index++ {
+		if err := breaker.Allow(endpoint); err != nil {
+			t.Fatalf("allow: %v", err)
+		}
+		breaker.Record(endpoint, lookupFailure(ErrConnection))
+	}
+	if state := breaker.State(endpoint); state != CircuitOpen {
+		t.Fatalf("state after 2 failures = %s, want open", state)
+	}
+	if err := breaker.Allow(endpoint); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow while open = %v, want ErrCircuitOpen", err)
+	}
+	if breaker.State("192.0.2.2:43") != CircuitClosed {
+		t.Errorf("other endpoint was affected")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if err := breaker.Allow(endpoint); err != nil {
+		t.Fatalf("probe refused: %v", err)
+	}
+	if err := breaker.Allow(endpoint); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second query during probe = %v, want ErrCircuitOpen", err)
+	}
+	breaker.Record(endpoint, lookupFailure(ErrTimeout))
+	if state := breaker.State(endpoint); state != CircuitOpen {
+		t.Fatalf("state after failed probe = %s, want open", state)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if err := breaker.Allow(endpoint); err != nil {
+		t.Fatalf("probe refused: %v", err)
+	}
+	breaker.Record(endpoint, lookupFailure(ErrCanceled))
+	if err := breaker.Allow(endpoint); err != nil {
+		t.Fatalf("probe after canceled probe refused: %v", err)
+	}
+	breaker.Record(endpoint, lookupFailure(ErrNoRecords))
+
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	got := recorder.transitions()
+	if len(got) != len(want) {
+		t.Fatalf("transitions = %v, want %v", got, want)
+	}
+	for index := range want {
+		if got[index] != want[index] {
+			t.Fatalf("transitions = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCircuitBreakerFailsClientLookupsFast(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	closedPort := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+	down := WhoisServer{Server: "127.0.0.1", Port: closedPort, Timeout: time.Second}
+
+	breaker, err := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute})
+	if err != nil {
+		t.Fatalf("new circuit breaker: %v", err)
+	}
+	client, err := NewClient(ClientConfig{Server: down, CircuitBreaker: breaker})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.LookupRegistry(context.Background(), "64500"); !errors.Is(err, ErrConnection) {
+		t.Fatalf("first lookup error = %v, want ErrConnection", err)
+	}
+	_, err = client.LookupRegistry(context.Background(), "64500")
+	if !errors.Is(err, ErrCircuitOpen) || ClassifyProviderError(err) != ProviderErrorCircuitOpen {
+		t.Fatalf("second lookup error = %v, want ErrCircuitOpen", err)
+	}
+	assertOperationError(t, err, "lookup registry", down.ServerAddressString())
+
+	standby, _ := startIPBatchServer(t, ipRecords)
+	failover, err := NewFailoverClient(FailoverConfig{Endpoints: []FailoverEndpoint{{Server: down}, {Server: standby}}, CircuitBreaker: breaker})
+	if err != nil {
+		t.Fatalf("new failover client: %v", err)
+	}
+	if _, err := failover.LookupIP(context.Background(), "192.0.2.1"); err != nil {
+		t.Fatalf("failover around open circuit: %v", err)
+	}
+}
+
+func TestCircuitBreakerRefusesBeforeSpendingRateLimitBudget(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	closedPort := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+	down := WhoisServer{Server: "127.0.0.1", Port: closedPort, Timeout: time.Second}
+	endpoint := down.ServerAddressString()
+
+	breaker, err := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("new circuit breaker: %v", err)
+	}
+	limiter, err := NewRateLimiter(RateLimiterConfig{QueriesPerSecond: 0.1, QueryBurst: 2})
+	if err != nil {
+		t.Fatalf("new rate limiter: %v", err)
+	}
+	client, err := NewClient(ClientConfig{Server: down, RateLimiter: limiter, CircuitBreaker: breaker})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.LookupRegistry(context.Background(), "64500"); !errors.Is(err, ErrConnection) {
+		t.Fatalf("first lookup error = %v, want ErrConnection", err)
+	}
+	before := limiter.Remaining(endpoint)
+	if _, err := client.LookupRegistry(context.Background(), "64500"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("refused lookup error = %v, want ErrCircuitOpen", err)
+	}
+	if after := limiter.Remaining(endpoint); after != before {
+		t.Errorf("budget after refused lookup = %+v, want unchanged %+v", after, before)
+	}
+
+	// A probe that gives up waiting for budget is handed back.
+	time.Sleep(60 * time.Millisecond)
+	if err := limiter.Wait(context.Background(), endpoint, 0); err != nil {
+		t.Fatalf("spend remaining budget: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.LookupRegistry(ctx, "64500"); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("lookup without budget error = %v, want ErrTimeout", err)
+	}
+	if state := breaker.State(endpoint); state != CircuitHalfOpen {
+		t.Errorf("state after abandoned probe = %s, want half-open", state)
+	}
+	if err := breaker.Allow(endpoint); err != nil {
+		t.Errorf("next probe after abandoned probe: %v", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresCallerCancellation(t *testing.T) {
+	breaker, err := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1})
+	if err != nil {
+		t.Fatalf("new circuit breaker: %v", err)
+	}
+	server := WhoisServer{Server: "192.0.2.1", Port: 43}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	breaker.recordQuery(ctx, server, false, server.operationError("lookup IP", ErrConnection))
+	if state := breaker.State(server.ServerAddressString()); state != CircuitClosed {
+		t.Errorf("state after canceled query = %s, want closed", state)
+	}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	breaker.recordQuery(expired, server, false, server.operationError("lookup IP", ErrTimeout))
+	if state := breaker.State(server.ServerAddressString()); state != CircuitClosed {
+		t.Errorf("state after query past the caller's deadline = %s, want closed", state)
+	}
+
+	// The connection deadline can fire before the context's own timer marks
+	// it done.
+	breaker.recordQuery(pastDeadlineContext{context.Background()}, server, false, server.operationError("lookup IP", ErrTimeout))
+	if state := breaker.State(server.ServerAddressString()); state != CircuitClosed {
+		t.Errorf("state after query past a deadline not yet marked done = %s, want closed", state)
+	}
+
+	if _, err := NewCircuitBreaker(CircuitBreakerConfig{OpenTimeout: -time.Second}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("negative timeout error = %v, want ErrInvalidInput", err)
+	}
+}
+
+// pastDeadlineContext has a deadline that has passed but is not yet done, as
+// a context is between its deadline and its timer firing.
+type pastDeadlineContext struct {
+	context.Context
+}
+
+func (pastDeadlineContext) Deadline() (time.Time, bool) {
+	return time.Now().Add(-time.Millisecond), true
+}
+
+func TestCircuitBreakerLateFailuresDoNotPostponeProbe(t *testing.T) {
+	breaker, err := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: 40 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("new circuit breaker: %v", err)
+	}
+	const endpoint = "192.0.2.1:43"
+
+	for index := 0; index < 2; index++ {
+		if err := breaker.Allow(endpoint); err != nil {
+			t.Fatalf("allow while closed: %v", err)
+		}
+	}
+	breaker.Record(endpoint, lookupFailure(ErrTimeout))
+	time.Sleep(25 * time.Millisecond)
+	breaker.Record(endpoint, lookupFailure(ErrTimeout))
+	time.Sleep(25 * time.Millisecond)
+	if err := breaker.Allow(endpoint); err != nil {
+		t.Fatalf("probe after OpenTimeout from the first failure refused: %v", err)
+	}
+}
+
+func TestCircuitBreakerKeepsProbeAcrossLateCancellation(t *testing.T) {
+	breaker, err := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("new circuit breaker: %v", err)
+	}
+	const endpoint = "192.0.2.1:43"
+
+	for index := 0; index < 2; index++ {
+		if probe, err := breaker.allow(endpoint); err != nil || probe {
+			t.Fatalf("allow while closed = %t, %v, want no probe", probe, err)
+		}
+	}
+	breaker.record(endpoint, false, lookupFailure(ErrConnection))
+	time.Sleep(20 * time.Millisecond)
+	if probe, err := breaker.allow(endpoint); err != nil || !probe {
+		t.Fatalf("allow after OpenTimeout = %t, %v, want probe", probe, err)
+	}
+
+	// The query let through while closed is canceled after the probe began.
+	breaker.record(endpoint, false, lookupFailure(ErrCanceled))
+	if err := breaker.Allow(endpoint); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second probe after late cancellation = %v, want ErrCircuitOpen", err)
+	}
+	breaker.record(endpoint, false, lookupFailure(ErrTimeout))
+	if state := breaker.State(endpoint); state != CircuitHalfOpen {
+		t.Fatalf("state after late failure = %s, want half-open", state)
+	}
+	breaker.record(endpoint, true, nil)
+	if state := breaker.State(endpoint); state != CircuitClosed {
+		t.Errorf("state after probe success = %s, want closed", state)
+	}
+}
+
+func TestCircuitBreakerIgnoresShortCallerDeadlines(t *testing.T) {
+	// The listener completes connections but never answers, so each lookup
+	// runs until its caller's deadline.
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	defer listener.Close()
+	silent := WhoisServer{Server: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port, Timeout: time.Minute}
+
+	breaker, err := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute})
+	if err != nil {
+		t.Fatalf("new circuit breaker: %v", err)
+	}
+	client, err := NewClient(ClientConfig{Server: silent, CircuitBreaker: breaker})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	for attempt := 1; attempt <= 3; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		_, err := client.LookupRegistry(ctx, "64500")
+		cancel()
+		if !errors.Is(err, ErrTimeout) {
+			t.Fatalf("lookup %d error = %v, want ErrTimeout", attempt, err)
+		}
+	}
+	if state := breaker.State(silent.ServerAddressString()); state != CircuitClosed {
+		t.Errorf("state after short caller deadlines = %s, want closed", state)
+	}
+}
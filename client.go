@@ -0,0 +1,243 @@
+package pwhois
+
+import (
+	"context"
+	"errors"
+)
+
+// ClientConfig configures a context-aware Client.
+type ClientConfig struct {
+	// Server supplies the endpoint, timeout, batch size, and response-size
+	// limit used by every lookup. Zero values are filled by SetDefaultValues.
+	// Server.Connection is ignored; the Client owns its connections.
+	Server WhoisServer
+	// RateLimiter, when set, paces every lookup and observes rate-limited
+	// responses. It may be shared with other clients and pools.
+	RateLimiter *RateLimiter
+	// CircuitBreaker, when set, fails lookups fast with ErrCircuitOpen while
+	// the server keeps failing in transport. It may be shared like
+	// RateLimiter.
+	CircuitBreaker *CircuitBreaker
+}
+
+// Client performs context-aware PWHOIS lookups. Each lookup dials its own
+// connection, writes one query, reads the complete response, and closes the
+// connection, so one Client is safe for concurrent use by many goroutines.
+//
+// Cancellation or expiry of the lookup context interrupts dial, write, and
+// read. Failures use the same stable error classes and *OperationError
+// wrapping as the channel-based WhoisServer lookup methods.
+type Client struct {
+	server WhoisServer
+	guard  queryGuard
+}
+
+// NewClient validates configuration and returns a reusable Client.
+func NewClient(config ClientConfig) (*Client, error) {
+	server, err := prepareServerConfig(config.Server)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{server: server, guard: queryGuard{limiter: config.RateLimiter, breaker: config.CircuitBreaker}}, nil
+}
+
+// prepareServerConfig fills defaults and validates a server configuration
+// for types that manage their own connections.
+func prepareServerConfig(server WhoisServer) (WhoisServer, error) {
+	server.Connection = nil
+	server.SetDefaultValues()
+
+	if server.Port < 1 || server.Port > 65535 {
+		return WhoisServer{}, invalidInputError("server port must be between 1 and 65535")
+	}
+	if server.BatchMaxSize < 1 {
+		return WhoisServer{}, invalidInputError("batch maximum size must be positive")
+	}
+	if server.Timeout < 0 {
+		return WhoisServer{}, invalidInputError("timeout cannot be negative")
+	}
+	return server, nil
+}
+
+// Server returns a copy of the configuration used for each lookup. The copy
+// is never connected.
+func (client *Client) Server() WhoisServer {
+	return client.server
+}
+
+// queryFunc executes one native PWHOIS query and returns the complete
+// response with the server configuration that executed it, so parser
+// failures can be attributed to the same endpoint.
+type queryFunc func(ctx context.Context, operation, query string) (string, WhoisServer, error)
+
+// query connects, executes one native PWHOIS query, and closes the
+// connection.
+func (client *Client) query(ctx context.Context, operation, query string) (string, WhoisServer, error) {
+	server := client.server
+	if ctx == nil {
+		return "", server, invalidInputError("lookup context is required")
+	}
+	probe, err := client.guard.admit(ctx, server, operation, query)
+	if err != nil {
+		return "", server, err
+	}
+	response, err := server.dialQueryContext(ctx, operation, query)
+	client.guard.settle(ctx, server, probe, err)
+	return response, server, err
+}
+
+// dialQueryContext connects, runs one query, and closes the connection.
+func (server WhoisServer) dialQueryContext(ctx context.Context, operation, query string) (string, error) {
+	if err := server.ConnectContext(ctx); err != nil {
+		return "", err
+	}
+	defer server.Connection.Close()
+	return server.executeQueryContext(ctx, operation, query)
+}
+
+// queryGuard applies the optional rate limiter and circuit breaker around
+// each query.
+type queryGuard struct {
+	limiter *RateLimiter
+	breaker *CircuitBreaker
+}
+
+// admit asks the circuit breaker and then waits for rate-limit budget, so a
+// query refused by an open circuit spends no budget. It reports whether the
+// query is the circuit's half-open probe. A query that stops waiting hands
+// back the probe it was allowed. Every admitted query must be settled.
+func (guard queryGuard) admit(ctx context.Context, server WhoisServer, operation, query string) (bool, error) {
+	probe, err := guard.breaker.allowQuery(server, operation)
+	if err != nil {
+		return false, err
+	}
+	if err := guard.limiter.waitForQuery(ctx, server, operation, query); err != nil {
+		guard.breaker.abandonQuery(server, probe)
+		return false, err
+	}
+	return probe, nil
+}
+
+// settle reports the outcome of a query admit let through; probe is what
+// admit returned.
+func (guard queryGuard) settle(ctx context.Context, server WhoisServer, probe bool, err error) {
+	guard.breaker.recordQuery(ctx, server, probe, err)
+	guard.limiter.observeQuery(server, err)
+}
+
+// LookupIP looks up one or more IP addresses in a single query. Invalid
+// addresses are skipped as they are by FormatIpQuery.
+func (client *Client) LookupIP(ctx context.Context, ips ...string) ([]WhoIs, error) {
+	return lookupIP(ctx, client.server, client.query, ips)
+}
+
+// LookupIPBatch looks up up to BatchMaxSize addresses in a single query and
+// correlates the returned records with the requested addresses. A requested
+// address without a record has IPLookupNoAnswer status; a response with no
+// records at all is reported the same way rather than as ErrNoRecords.
+func (client *Client) LookupIPBatch(ctx context.Context, ips ...string) (IPBatchResult, error) {
+	return lookupIPBatch(ctx, client.server, client.query, ips)
+}
+
+// LookupRouteView returns the routes originated by asn. The returned Asn is
+// the normalized decimal value.
+func (client *Client) LookupRouteView(ctx context.Context, asn string) (BGPRoutes, error) {
+	return lookupRouteView(ctx, client.server, client.query, asn)
+}
+
+// LookupRegistry returns registry data for asn. The returned Asn is the
+// normalized decimal value.
+func (client *Client) LookupRegistry(ctx context.Context, asn string) (RegistryRecord, error) {
+	return lookupRegistry(ctx, client.server, client.query, asn)
+}
+
+// LookupNetblock returns the netblocks announced by asn. The returned Asn is
+// the normalized decimal value.
+func (client *Client) LookupNetblock(ctx context.Context, asn string) (NetblockRecord, error) {
+	return lookupNetblock(ctx, client.server, client.query, asn)
+}
+
+// The lookup functions below format a query with server, execute it through
+// query, and parse the response. Client and Pool share them so both
+// accept the same inputs and report the same errors.
+
+func lookupIP(ctx context.Context, server WhoisServer, query queryFunc, ips []string) ([]WhoIs, error) {
+	request, err := server.FormatIpQuery(ips)
+	if err != nil {
+		return nil, err
+	}
+
+	response, executed, err := query(ctx, "lookup IP", request)
+	if err != nil {
+		return nil, err
+	}
+	return executed.parseIPLookup(response)
+}
+
+func lookupIPBatch(ctx context.Context, server WhoisServer, query queryFunc, ips []string) (IPBatchResult, error) {
+	request, validation, err := server.FormatIpQueryWithValidation(ips, IPQueryOptions{})
+	if err != nil {
+		return IPBatchResult{Validation: validation}, err
+	}
+
+	response, executed, err := query(ctx, "lookup IP", request)
+	if err != nil {
+		return IPBatchResult{Validation: validation}, err
+	}
+	records, err := executed.parseIPLookup(response)
+	if err != nil && !errors.Is(err, ErrNoRecords) {
+		return IPBatchResult{Validation: validation}, err
+	}
+	return correlateIPRecords(validation, records), nil
+}
+
+func lookupRouteView(ctx context.Context, server WhoisServer, query queryFunc, asn string) (BGPRoutes, error) {
+	normalizedASN, err := normalizeASN(asn)
+	if err != nil {
+		return BGPRoutes{}, err
+	}
+	request, err := server.FormatRouteViewQuery(normalizedASN)
+	if err != nil {
+		return BGPRoutes{}, err
+	}
+
+	response, executed, err := query(ctx, "lookup RouteView", request)
+	if err != nil {
+		return BGPRoutes{}, err
+	}
+	return executed.parseRouteViewLookup(normalizedASN, response)
+}
+
+func lookupRegistry(ctx context.Context, server WhoisServer, query queryFunc, asn string) (RegistryRecord, error) {
+	normalizedASN, err := normalizeASN(asn)
+	if err != nil {
+		return RegistryRecord{}, err
+	}
+	request, err := server.FormatRegistryQuery(normalizedASN)
+	if err != nil {
+		return RegistryRecord{}, err
+	}
+
+	response, executed, err := query(ctx, "lookup registry", request)
+	if err != nil {
+		return RegistryRecord{}, err
+	}
+	return executed.parseRegistryLookup(normalizedASN, response)
+}
+
+func lookupNetblock(ctx context.Context, server WhoisServer, query queryFunc, asn string) (NetblockRecord, error) {
+	normalizedASN, err := normalizeASN(asn)
+	if err != nil {
+		return NetblockRecord{}, err
+	}
+	request, err := server.FormatNetblockQuery(normalizedASN)
+	if err != nil {
+		return NetblockRecord{}, err
+	}
+
+	response, executed, err := query(ctx, "lookup netblock", request)
+	if err != nil {
+		return NetblockRecord{}, err
+	}
+	return executed.parseNetblockLookup(normalizedASN, response)
+}
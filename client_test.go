@@ -0,0 +1,188 @@
+package pwhois
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newLoopbackClient(t *testing.T, script loopbackProtocolScript) (*Client, <-chan loopbackProtocolResult) {
+	t.Helper()
+
+	server, results := startLoopbackProtocolServer(t, script)
+	client, err := NewClient(ClientConfig{Server: server})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return client, results
+}
+
+func TestClientLookupsOwnConnectionLifecycle(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		response string
+		lookup   func(*Client) (any, error)
+		check    func(*testing.T, any)
+	}{
+		{
+			name:     "IP",
+			request:  "app=\"GO pwhois Module\"\nbegin\n192.0.2.1\n198.51.100.2\nend\n",
+			response: "IP: 192.0.2.1\nOrigin-AS: 64500\n\nIP: 198.51.100.2\nOrigin-AS: 64501\n",
+			lookup: func(client *Client) (any, error) {
+				return client.LookupIP(context.Background(), "192.0.2.1", "198.51.100.2")
+			},
+			check: func(t *testing.T, value any) {
+				records := value.([]WhoIs)
+				if len(records) != 2 || records[1].OriginAS != "64501" {
+					t.Fatalf("IP response = %+v", records)
+				}
+			},
+		},
+		{
+			name:     "RouteView",
+			request:  "app=\"GO pwhois Module\" routeview source-as=64500\n",
+			response: "*> 192.0.2.0/24 | Jul 18 2026 00:00:04 | Jul 18 2026 00:00:04 | May 28 2026 06:56:01 | 192.0.2.254 | 64501 64500",
+			lookup: func(client *Client) (any, error) {
+				return client.LookupRouteView(context.Background(), "AS64500")
+			},
+			check: func(t *testing.T, value any) {
+				routes := value.(BGPRoutes)
+				if routes.Asn != "64500" || len(routes.Routes) != 1 {
+					t.Fatalf("RouteView response = %+v", routes)
+				}
+			},
+		},
+		{
+			name:     "registry",
+			request:  "app=\"GO pwhois Module\" registry source-as=64500\n",
+			response: "Org-ID: TEST\nOrg-Name: Example Registry Organization\n",
+			lookup: func(client *Client) (any, error) {
+				return client.LookupRegistry(context.Background(), "as64500")
+			},
+			check: func(t *testing.T, value any) {
+				record := value.(RegistryRecord)
+				if record.Asn != "64500" || record.Registry.OrgID != "TEST" {
+					t.Fatalf("registry response = %+v", record)
+				}
+			},
+		},
+		{
+			name:     "netblock",
+			request:  "app=\"GO pwhois Module\" netblock source-as=64500\n",
+			response: "Origin-AS: 64500\nOrg-Name: Example Networks\n*> 192.0.2.0 - 192.0.2.255 | EXAMPLE-NET | reassignment | 2019-05-25 | 2019-09-25 | Jun 28 2019 16:53:01 | Jul 18 2026 03:19:32 | TEST",
+			lookup: func(client *Client) (any, error) {
+				return client.LookupNetblock(context.Background(), "64500")
+			},
+			check: func(t *testing.T, value any) {
+				record := value.(NetblockRecord)
+				if record.Asn != "64500" || len(record.Netblocks) != 1 {
+					t.Fatalf("netblock response = %+v", record)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, results := newLoopbackClient(t, loopbackProtocolScript{
+				expectedRequest: test.request,
+				responseChunks:  []string{test.response},
+			})
+			value, err := test.lookup(client)
+			if err != nil {
+				t.Fatalf("lookup: %v", err)
+			}
+			test.check(t, value)
+			waitForLoopbackProtocol(t, results, test.request)
+		})
+	}
+}
+
+func TestClientLookupHonorsContextDuringRead(t *testing.T) {
+	const request = "app=\"GO pwhois Module\" registry source-as=64500\n"
+
+	tests := []struct {
+		name    string
+		context func() (context.Context, context.CancelFunc)
+		want    error
+	}{
+		{
+			name: "canceled",
+			context: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(100*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			want: ErrCanceled,
+		},
+		{
+			name: "deadline",
+			context: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 100*time.Millisecond)
+			},
+			want: ErrTimeout,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, results := newLoopbackClient(t, loopbackProtocolScript{
+				expectedRequest: request,
+				noResponse:      true,
+			})
+			ctx, cancel := test.context()
+			defer cancel()
+
+			started := time.Now()
+			_, err := client.LookupRegistry(ctx, "64500")
+			if !errors.Is(err, test.want) {
+				t.Fatalf("error = %v, want %v", err, test.want)
+			}
+			if elapsed := time.Since(started); elapsed > time.Second {
+				t.Fatalf("lookup returned after %s, want prompt context interruption", elapsed)
+			}
+			server := client.Server()
+			assertOperationError(t, err, "lookup registry", server.ServerAddressString())
+			waitForLoopbackProtocol(t, results, request)
+		})
+	}
+}
+
+func TestClientLookupCanceledBeforeDial(t *testing.T) {
+	client, err := NewClient(ClientConfig{Server: WhoisServer{Server: "127.0.0.1", Port: 43}})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = client.LookupIP(ctx, "192.0.2.1")
+	if !errors.Is(err, ErrCanceled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want ErrCanceled and context.Canceled", err)
+	}
+	assertOperationError(t, err, "connect", "127.0.0.1:43")
+}
+
+func TestNewClientValidatesConfiguration(t *testing.T) {
+	client, err := NewClient(ClientConfig{})
+	if err != nil {
+		t.Fatalf("new client with defaults: %v", err)
+	}
+	server := client.Server()
+	if got, want := server.ServerAddressString(), "whois.pwhois.org:43"; got != want {
+		t.Errorf("default endpoint = %q, want %q", got, want)
+	}
+
+	invalid := []WhoisServer{
+		{Port: 70000},
+		{BatchMaxSize: -1},
+		{Timeout: -time.Second},
+	}
+	for _, server := range invalid {
+		if _, err := NewClient(ClientConfig{Server: server}); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("NewClient(%+v) error = %v, want ErrInvalidInput", server, err)
+		}
+	}
+}
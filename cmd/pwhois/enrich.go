@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/georgestarcher/pwhois"
+)
+
+// enrichWindow is the number of rows read before their new addresses are
+// looked up and the rows are written.
+const enrichWindow = 1000
+
+// maxEnrichLine bounds the length of one text or JSON-lines input line.
+const maxEnrichLine = 1024 * 1024
+
+// defaultAddressPattern finds IPv4 and IPv6 address candidates in free text.
+// Candidates that do not parse as addresses are ignored.
+const defaultAddressPattern = `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f.]*|\b\d{1,3}(?:\.\d{1,3}){3}\b`
+
+// enrichColumns name the annotations added to CSV, TSV, and JSON-lines rows.
+var enrichColumns = []string{"pwhois_origin_as", "pwhois_org_name", "pwhois_country_code", "pwhois_error"}
+
+// enrichRow is one input row: the addresses to annotate it with and the
+// function that writes it once their results are known.
+type enrichRow struct {
+	addresses []string
+	write     func(results []pwhois.IPLookupResult) error
+}
+
+// enricher reads rows in one input format.
+type enricher struct {
+	extract func(string) []string
+	field   string
+	header  bool
+	next    func() (enrichRow, error)
+}
+
+// enrich annotates each row of a log with the origin AS, org name, and
+// country of its addresses.
+func (cmd *command) enrich(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("pwhois enrich", flag.ContinueOnError)
+	flags.SetOutput(cmd.stderr)
+	format := flags.String("format", "text", "input `format`: text, csv, tsv, or jsonl")
+	field := flags.String("field", "", "address `column`: a 1-based index or, with -header, a name for csv and tsv; a key for jsonl")
+	header := flags.Bool("header", false, "the first csv or tsv row is a header")
+	pattern := flags.String("regex", defaultAddressPattern, "address `pattern`; its first group is used when it has one")
+	batchSize := flags.Int("batch-size", 0, "addresses per batch query; 0 uses the server maximum")
+	rate := flags.Float64("rate", 0, "maximum batch `queries` per second; 0 is unlimited")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() > 1 {
+		fmt.Fprintln(cmd.stderr, "usage: pwhois [flags] enrich [enrich flags] [file]")
+		return errUsage
+	}
+	expression, err := regexp.Compile(*pattern)
+	if err != nil {
+		fmt.Fprintf(cmd.stderr, "pwhois: -regex: %v\n", err)
+		return errUsage
+	}
+
+	client := cmd.client
+	if *rate != 0 {
+		limiter, err := pwhois.NewRateLimiter(pwhois.RateLimiterConfig{QueriesPerSecond: *rate})
+		if err != nil {
+			return err
+		}
+		if client, err = pwhois.NewClient(pwhois.ClientConfig{Server: cmd.server, RateLimiter: limiter}); err != nil {
+			return err
+		}
+	}
+
+	input := cmd.stdin
+	if flags.NArg() == 1 && flags.Arg(0) != "-" {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	rows := &enricher{extract: addressExtractor(expression), field: *field, header: *header}
+	output := bufio.NewWriter(cmd.stdout)
+	switch *format {
+	case "text":
+		rows.next = rows.text(input, output)
+	case "csv":
+		rows.next, err = rows.delimited(input, output, ',')
+	case "tsv":
+		rows.next, err = rows.delimited(input, output, '\t')
+	case "jsonl":
+		rows.next = rows.jsonLines(input, output)
+	default:
+		fmt.Fprintf(cmd.stderr, "pwhois: unknown -format %q\n", *format)
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+
+	var report lookupReport
+	err = enrichRows(ctx, client, pwhois.BulkIPOptions{BatchSize: *batchSize}, rows.next, func(input string, result pwhois.IPLookupResult) {
+		report.add(cmd.stderr, input, result)
+	})
+	if flushErr := output.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		return err
+	}
+	return report.err()
+}
+
+// enrichRows reads rows a window at a time, looks up the addresses not seen
+// before, and writes the window. resolved is called once for each distinct
+// address.
+func enrichRows(ctx context.Context, client *pwhois.Client, options pwhois.BulkIPOptions, next func() (enrichRow, error), resolved func(string, pwhois.IPLookupResult)) error {
+	known := make(map[string]pwhois.IPLookupResult)
+	for done := false; !done; {
+		var window []enrichRow
+		var pending []string
+		for len(window) < enrichWindow {
+			row, err := next()
+			if errors.Is(err, io.EOF) {
+				done = true
+				break
+			}
+			if err != nil {
+				return err
+			}
+			window = append(window, row)
+			for _, address := range row.addresses {
+				if _, ok := known[address]; !ok {
+					known[address] = pwhois.IPLookupResult{}
+					pending = append(pending, address)
+				}
+			}
+		}
+
+		if len(pending) > 0 {
+			switch err := ctx.Err(); {
+			case errors.Is(err, context.DeadlineExceeded):
+				return fmt.Errorf("enrich: %w", pwhois.ErrTimeout)
+			case err != nil:
+				return fmt.Errorf("enrich: %w", pwhois.ErrCanceled)
+			}
+			results, err := client.LookupIPBulk(ctx, pending, options)
+			if err != nil {
+				return err
+			}
+			for _, address := range pending {
+				known[address] = results[address]
+				resolved(address, results[address])
+			}
+		}
+		for _, row := range window {
+			results := make([]pwhois.IPLookupResult, len(row.addresses))
+			for index, address := range row.addresses {
+				results[index] = known[address]
+			}
+			if err := row.write(results); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addressExtractor returns the addresses expression finds in text, in order.
+// An expression with groups contributes its first group.
+func addressExtractor(expression *regexp.Regexp) func(string) []string {
+	return func(text string) []string {
+		var addresses []string
+		for _, match := range expression.FindAllStringSubmatch(text, -1) {
+			candidate := match[0]
+			if len(match) > 1 {
+				candidate = match[1]
+			}
+			if address, err := netip.ParseAddr(candidate); err == nil && address.Zone() == "" {
+				addresses = append(addresses, candidate)
+			}
+		}
+		return addresses
+	}
+}
+
+// text reads free-text lines and appends a bracketed annotation for every
+// address found in each.
+func (rows *enricher) text(input io.Reader, output *bufio.Writer) func() (enrichRow, error) {
+	scanner := newLineScanner(input)
+	return func() (enrichRow, error) {
+		if !scanner.Scan() {
+			return enrichRow{}, scanError(scanner)
+		}
+		line := scanner.Text()
+		return enrichRow{addresses: rows.extract(line), write: func(results []pwhois.IPLookupResult) error {
+			output.WriteString(line)
+			for _, result := range results {
+				values := annotation(result)
+				if values[3] != "" {
+					fmt.Fprintf(output, " [ip=%s error=%s]", result.Input, values[3])
+					continue
+				}
+				fmt.Fprintf(output, " [ip=%s origin_as=%s org_name=%q country_code=%s]", result.Input, values[0], values[1], values[2])
+			}
+			return output.WriteByte('\n')
+		}}, nil
+	}
+}
+
+// delimited reads CSV or TSV records and appends the annotation columns for
+// the address in the -field column, or the first address found in the row.
+// With -header, annotation columns already in the header are filled in
+// place, so enriching a file again replaces the earlier annotations.
+func (rows *enricher) delimited(input io.Reader, output *bufio.Writer, comma rune) (func() (enrichRow, error), error) {
+	reader := csv.NewReader(input)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = comma == '\t'
+	writer := csv.NewWriter(output)
+	writer.Comma = comma
+
+	column := -1
+	if rows.field != "" {
+		index, err := strconv.Atoi(rows.field)
+		switch {
+		case err == nil && index >= 1:
+			column = index - 1
+		case err == nil || !rows.header:
+			return nil, fmt.Errorf("-field %q is not a 1-based column index: %w", rows.field, pwhois.ErrInvalidInput)
+		}
+	}
+	first := true
+	// positions holds the column of each enrichColumns value once a header
+	// is read; nil appends the values instead.
+	var positions []int
+
+	return func() (enrichRow, error) {
+		record, err := reader.Read()
+		if err != nil {
+			return enrichRow{}, err
+		}
+		if first && rows.header {
+			first = false
+			if column < 0 && rows.field != "" {
+				for index, name := range record {
+					if name == rows.field {
+						column = index
+					}
+				}
+				if column < 0 {
+					return enrichRow{}, fmt.Errorf("-field %q is not in the header: %w", rows.field, pwhois.ErrInvalidInput)
+				}
+			}
+			header := record
+			positions = make([]int, len(enrichColumns))
+			for index, name := range enrichColumns {
+				positions[index] = slices.Index(header, name)
+				if positions[index] < 0 {
+					positions[index] = len(header)
+					header = append(header, name)
+				}
+			}
+			return enrichRow{write: func([]pwhois.IPLookupResult) error {
+				return writeRecord(writer, header)
+			}}, nil
+		}
+		first = false
+
+		var addresses []string
+		switch {
+		case column >= 0 && column < len(record):
+			if value := strings.TrimSpace(record[column]); value != "" {
+				addresses = []string{value}
+			}
+		case column < 0:
+			addresses = firstAddress(rows.extract(strings.Join(record, string(comma))))
+		}
+		return enrichRow{addresses: addresses, write: func(results []pwhois.IPLookupResult) error {
+			values := make([]string, len(enrichColumns))
+			if len(results) > 0 {
+				values = annotation(results[0])
+			}
+			return writeRecord(writer, annotated(record, positions, values))
+		}}, nil
+	}, nil
+}
+
+// annotated returns record with values at positions, padding it with empty
+// columns as needed. Nil positions appends values.
+func annotated(record []string, positions []int, values []string) []string {
+	if positions == nil {
+		return append(record, values...)
+	}
+	for index, value := range values {
+		for len(record) <= positions[index] {
+			record = append(record, "")
+		}
+		record[positions[index]] = value
+	}
+	return record
+}
+
+func writeRecord(writer *csv.Writer, record []string) error {
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// jsonLines reads one JSON object per line and adds the annotation keys for
+// the address in the -field key, or the first address found in the line.
+// The original text of each object is kept, except that annotation keys from
+// an earlier run are replaced. Blank lines are copied.
+func (rows *enricher) jsonLines(input io.Reader, output *bufio.Writer) func() (enrichRow, error) {
+	scanner := newLineScanner(input)
+	lineNumber := 0
+	return func() (enrichRow, error) {
+		if !scanner.Scan() {
+			return enrichRow{}, scanError(scanner)
+		}
+		lineNumber++
+		line := strings.TrimRightFunc(scanner.Text(), unicode.IsSpace)
+		if strings.TrimSpace(line) == "" {
+			return enrichRow{write: func([]pwhois.IPLookupResult) error {
+				return output.WriteByte('\n')
+			}}, nil
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &object); err != nil || !strings.HasSuffix(line, "}") {
+			return enrichRow{}, fmt.Errorf("line %d is not a JSON object: %w", lineNumber, pwhois.ErrInvalidInput)
+		}
+
+		var addresses []string
+		if rows.field != "" {
+			var value string
+			if json.Unmarshal(object[rows.field], &value) == nil && strings.TrimSpace(value) != "" {
+				addresses = []string{strings.TrimSpace(value)}
+			}
+		} else {
+			addresses = firstAddress(rows.extract(line))
+		}
+		return enrichRow{addresses: addresses, write: func(results []pwhois.IPLookupResult) error {
+			if len(results) == 0 {
+				output.WriteString(line)
+				return output.WriteByte('\n')
+			}
+			kept := withoutEnrichKeys(line, object)
+			body := strings.TrimRightFunc(kept[:len(kept)-1], unicode.IsSpace)
+			output.WriteString(body)
+			for index, value := range annotation(results[0]) {
+				if index > 0 || !strings.HasSuffix(body, "{") {
+					output.WriteByte(',')
+				}
+				key, _ := json.Marshal(enrichColumns[index])
+				encoded, _ := json.Marshal(value)
+				fmt.Fprintf(output, "%s:%s", key, encoded)
+			}
+			_, err := output.WriteString("}\n")
+			return err
+		}}, nil
+	}
+}
+
+// withoutEnrichKeys returns the JSON object line without its enrichColumns
+// members, or line itself when it has none. The other members keep their
+// text and order.
+func withoutEnrichKeys(line string, object map[string]json.RawMessage) string {
+	found := false
+	for _, key := range enrichColumns {
+		if _, ok := object[key]; ok {
+			found = true
+		}
+	}
+	if !found {
+		return line
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(line))
+	if _, err := decoder.Token(); err != nil {
+		return line
+	}
+	var members []string
+	for decoder.More() {
+		start := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err != nil {
+			return line
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return line
+		}
+		if key, _ := token.(string); slices.Contains(enrichColumns, key) {
+			continue
+		}
+		member := line[start:decoder.InputOffset()]
+		members = append(members, strings.TrimLeftFunc(member, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }))
+	}
+	return "{" + strings.Join(members, ",") + "}"
+}
+
+// annotation returns the values of enrichColumns for result. Unresolved
+// addresses have only the error class.
+func annotation(result pwhois.IPLookupResult) []string {
+	switch result.Status {
+	case pwhois.IPLookupAnswered:
+		return []string{result.Record.OriginAS, result.Record.OrgName, result.Record.CountryCode, ""}
+	case pwhois.IPLookupNoAnswer:
+		return []string{"", "", "", string(pwhois.ProviderErrorNoRecords)}
+	default:
+		return []string{"", "", "", string(pwhois.ClassifyProviderError(result.Err))}
+	}
+}
+
+func firstAddress(addresses []string) []string {
+	if len(addresses) > 1 {
+		return addresses[:1]
+	}
+	return addresses
+}
+
+func newLineScanner(input io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEnrichLine)
+	return scanner
+}
+
+// scanError returns the scanner's error, or io.EOF at the end of input.
+func scanError(scanner *bufio.Scanner) error {
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
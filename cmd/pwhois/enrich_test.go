@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georgestarcher/pwhois/pwhoistest"
+)
+
+func TestEnrichAnnotatesEachFormat(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		args  []string
+		input string
+		want  string
+	}{
+		{
+			name:  "text",
+			args:  []string{"enrich"},
+			input: "Jul 18 10:11:12 fw DROP src=192.0.2.1:443 dst=[2001:db8::1]:80\nno addresses here\n",
+			want: "Jul 18 10:11:12 fw DROP src=192.0.2.1:443 dst=[2001:db8::1]:80" +
+				` [ip=192.0.2.1 origin_as=64500 org_name="Example Networks" country_code=US]` +
+				` [ip=2001:db8::1 origin_as=64501 org_name="Example Six" country_code=NL]` + "\n" +
+				"no addresses here\n",
+		},
+		{
+			name:  "csv header field name",
+			args:  []string{"enrich", "-format", "csv", "-header", "-field", "client"},
+			input: "time,client,bytes\n10:11:12,192.0.2.1,512\n10:11:13,,0\n",
+			want: "time,client,bytes,pwhois_origin_as,pwhois_org_name,pwhois_country_code,pwhois_error\n" +
+				"10:11:12,192.0.2.1,512,64500,Example Networks,US,\n" +
+				"10:11:13,,0,,,,\n",
+		},
+		{
+			name:  "tsv field index",
+			args:  []string{"enrich", "-format", "tsv", "-field", "2"},
+			input: "a\t2001:db8::1\n",
+			want:  "a\t2001:db8::1\t64501\tExample Six\tNL\t\n",
+		},
+		{
+			name:  "csv regex",
+			args:  []string{"enrich", "-format", "csv", "-regex", `src=([0-9.]+)`},
+			input: "\"msg src=192.0.2.1\",x\n",
+			want:  "msg src=192.0.2.1,x,64500,Example Networks,US,\n",
+		},
+		{
+			name:  "jsonl key",
+			args:  []string{"enrich", "-format", "jsonl", "-field", "src_ip"},
+			input: "{\"src_ip\": \"192.0.2.1\", \"n\": 1}\n\n{}\n",
+			want: "{\"src_ip\": \"192.0.2.1\", \"n\": 1," +
+				`"pwhois_origin_as":"64500","pwhois_org_name":"Example Networks","pwhois_country_code":"US","pwhois_error":""}` + "\n\n{}\n",
+		},
+		{
+			name:  "jsonl regex",
+			args:  []string{"enrich", "-format", "jsonl"},
+			input: `{"message":"from 2001:db8::1"}` + "\n",
+			want:  `{"message":"from 2001:db8::1","pwhois_origin_as":"64501","pwhois_org_name":"Example Six","pwhois_country_code":"NL","pwhois_error":""}` + "\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := runAgainst(server, test.input, test.args...)
+			if got.code != exitOK || got.stdout != test.want {
+				t.Errorf("enrich = %d, stderr %q\ngot  %q\nwant %q", got.code, got.stderr, got.stdout, test.want)
+			}
+		})
+	}
+}
+
+func TestEnrichReplacesJSONAnnotationsOnRerun(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+
+	input := `{"pwhois_error":"timeout","src_ip":"192.0.2.1", "pwhois_origin_as":"1","n":1}` + "\n" +
+		`{"pwhois_org_name":"stale","src_ip":"2001:db8::1"}` + "\n"
+	want := `{"src_ip":"192.0.2.1","n":1,"pwhois_origin_as":"64500","pwhois_org_name":"Example Networks","pwhois_country_code":"US","pwhois_error":""}` + "\n" +
+		`{"src_ip":"2001:db8::1","pwhois_origin_as":"64501","pwhois_org_name":"Example Six","pwhois_country_code":"NL","pwhois_error":""}` + "\n"
+	first := runAgainst(server, input, "enrich", "-format", "jsonl", "-field", "src_ip")
+	if first.code != exitOK || first.stdout != want {
+		t.Fatalf("enrich = %d, stderr %q\ngot  %q\nwant %q", first.code, first.stderr, first.stdout, want)
+	}
+	second := runAgainst(server, first.stdout, "enrich", "-format", "jsonl", "-field", "src_ip")
+	if second.code != exitOK || second.stdout != want {
+		t.Errorf("re-enrich = %d, stderr %q\ngot  %q\nwant %q", second.code, second.stderr, second.stdout, want)
+	}
+}
+
+func TestEnrichReplacesDelimitedAnnotationsOnRerun(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+
+	input := "time,client\n10:11:12,192.0.2.1\n10:11:13,2001:db8::1\n"
+	want := "time,client,pwhois_origin_as,pwhois_org_name,pwhois_country_code,pwhois_error\n" +
+		"10:11:12,192.0.2.1,64500,Example Networks,US,\n" +
+		"10:11:13,2001:db8::1,64501,Example Six,NL,\n"
+	first := runAgainst(server, input, "enrich", "-format", "csv", "-header", "-field", "client")
+	if first.code != exitOK || first.stdout != want {
+		t.Fatalf("enrich = %d, stderr %q\ngot  %q\nwant %q", first.code, first.stderr, first.stdout, want)
+	}
+	second := runAgainst(server, first.stdout, "enrich", "-format", "csv", "-header", "-field", "client")
+	if second.code != exitOK || second.stdout != want {
+		t.Errorf("re-enrich = %d, stderr %q\ngot  %q\nwant %q", second.code, second.stderr, second.stdout, want)
+	}
+
+	stale := "pwhois_error\tclient\ttime\ntimeout\t192.0.2.1\t10:11:12\n"
+	want = "pwhois_error\tclient\ttime\tpwhois_origin_as\tpwhois_org_name\tpwhois_country_code\n" +
+		"\t192.0.2.1\t10:11:12\t64500\tExample Networks\tUS\n"
+	if got := runAgainst(server, stale, "enrich", "-format", "tsv", "-header", "-field", "client"); got.code != exitOK || got.stdout != want {
+		t.Errorf("partial annotations = %d, stderr %q\ngot  %q\nwant %q", got.code, got.stderr, got.stdout, want)
+	}
+}
+
+func TestEnrichReportsUnresolvedAddressesOnce(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+
+	input := strings.Repeat("hit 192.0.2.1 miss 198.51.100.7\n", 3)
+	got := runAgainst(server, input, "enrich", "-batch-size", "1", "-rate", "1000")
+	wantLine := `hit 192.0.2.1 miss 198.51.100.7 [ip=192.0.2.1 origin_as=64500 org_name="Example Networks" country_code=US] [ip=198.51.100.7 error=no_records]` + "\n"
+	if got.code != exitNoRecords || got.stdout != strings.Repeat(wantLine, 3) {
+		t.Errorf("enrich = %d\n%s", got.code, got.stdout)
+	}
+	if strings.Count(got.stderr, "198.51.100.7: no records") != 1 || !strings.Contains(got.stderr, "1 of 2 addresses not answered") {
+		t.Errorf("stderr = %q", got.stderr)
+	}
+	if queries := server.Queries(); len(queries) != 2 {
+		t.Errorf("queries = %d, want one per distinct address", len(queries))
+	}
+
+	got = runAgainst(server, "a,not-an-ip\n", "enrich", "-format", "csv", "-field", "2")
+	if got.code != exitInvalidInput || got.stdout != "a,not-an-ip,,,,invalid_input\n" {
+		t.Errorf("invalid field = %d, %q, %q", got.code, got.stdout, got.stderr)
+	}
+}
+
+func TestEnrichClassifiesEndedContexts(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Addr())
+	args := []string{"-server", host, "-port", port, "enrich"}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, stop := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer stop()
+	for name, test := range map[string]struct {
+		ctx  context.Context
+		want int
+	}{
+		"canceled": {ctx: canceled, want: exitCanceled},
+		"expired":  {ctx: expired, want: exitTimeout},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run(test.ctx, args, strings.NewReader("src=192.0.2.1\n"), &stdout, &stderr); code != test.want {
+			t.Errorf("%s: enrich = %d, %q, want %d", name, code, stderr.String(), test.want)
+		}
+	}
+	if queries := server.Queries(); len(queries) != 0 {
+		t.Errorf("queries = %d, want none after the context ended", len(queries))
+	}
+}
+
+func TestEnrichRejectsBadArguments(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+
+	for _, test := range []struct {
+		args  []string
+		input string
+		want  int
+	}{
+		{args: []string{"enrich", "-format", "xml"}, want: exitUsage},
+		{args: []string{"enrich", "-regex", "("}, want: exitUsage},
+		{args: []string{"enrich", "a", "b"}, want: exitUsage},
+		{args: []string{"enrich", "-format", "csv", "-field", "client"}, want: exitInvalidInput},
+		{args: []string{"enrich", "-format", "csv", "-header", "-field", "client"}, input: "time,server\n", want: exitInvalidInput},
+		{args: []string{"enrich", "-format", "jsonl"}, input: "[1]\n", want: exitInvalidInput},
+		{args: []string{"enrich", "-rate", "-1"}, want: exitInvalidInput},
+	} {
+		if got := runAgainst(server, test.input, test.args...); got.code != test.want {
+			t.Errorf("run(%q) = %d, %q, want %d", test.args, got.code, got.stderr, test.want)
+		}
+	}
+}
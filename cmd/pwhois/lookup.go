@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/georgestarcher/pwhois"
+)
+
+// ip looks up the addresses named by args, a file, or standard input and
+// prints the answered records in input order. Each address that failed or
+// was not answered is reported on stderr.
+func (cmd *command) ip(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("pwhois ip", flag.ContinueOnError)
+	flags.SetOutput(cmd.stderr)
+	file := flags.String("f", "", "read addresses from `file`, or standard input for -")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	inputs := flags.Args()
+	if *file != "" || len(inputs) == 0 {
+		fromFile, err := cmd.readAddresses(*file)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, fromFile...)
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("no addresses to look up: %w", pwhois.ErrInvalidInput)
+	}
+
+	results, err := cmd.client.LookupIPBulk(ctx, inputs, pwhois.BulkIPOptions{})
+	if err != nil {
+		return err
+	}
+
+	var (
+		records []pwhois.WhoIs
+		printed = make(map[netip.Addr]bool)
+		report  lookupReport
+	)
+	for _, input := range inputs {
+		result := results[input]
+		if !report.add(cmd.stderr, input, result) {
+			continue
+		}
+		if result.Status == pwhois.IPLookupAnswered && !printed[result.Addr] {
+			printed[result.Addr] = true
+			records = append(records, result.Record)
+		}
+	}
+
+	if err := cmd.printIP(records); err != nil {
+		return err
+	}
+	return report.err()
+}
+
+// lookupReport tallies IP lookup results by input, reporting each input that
+// failed or was not answered on stderr once.
+type lookupReport struct {
+	seen       map[string]bool
+	failure    error
+	failed     int
+	unanswered int
+}
+
+// add records the result for input and reports false if input was already
+// seen.
+func (report *lookupReport) add(stderr io.Writer, input string, result pwhois.IPLookupResult) bool {
+	if report.seen == nil {
+		report.seen = make(map[string]bool)
+	}
+	if report.seen[input] {
+		return false
+	}
+	report.seen[input] = true
+	switch result.Status {
+	case pwhois.IPLookupAnswered:
+	case pwhois.IPLookupNoAnswer:
+		report.unanswered++
+		fmt.Fprintf(stderr, "pwhois: %s: no records\n", input)
+	default:
+		report.failed++
+		if report.failure == nil {
+			report.failure = result.Err
+		}
+		fmt.Fprintf(stderr, "pwhois: %s: %v\n", input, result.Err)
+	}
+	return true
+}
+
+// err summarizes the inputs that were not answered. Failures take precedence
+// over inputs the server had no records for.
+func (report *lookupReport) err() error {
+	switch {
+	case report.failure != nil:
+		return fmt.Errorf("%d of %d addresses failed: %w", report.failed, len(report.seen), report.failure)
+	case report.unanswered > 0:
+		return fmt.Errorf("%d of %d addresses not answered: %w", report.unanswered, len(report.seen), pwhois.ErrNoRecords)
+	}
+	return nil
+}
+
+// readAddresses reads one address per line from path, or from standard input
+// when path is empty or "-".
+func (cmd *command) readAddresses(path string) ([]string, error) {
+	reader := cmd.stdin
+	if path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	var addresses []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addresses = append(addresses, line)
+	}
+	return addresses, scanner.Err()
+}
+
+func (cmd *command) printIP(records []pwhois.WhoIs) error {
+	if cmd.json {
+		encoder := json.NewEncoder(cmd.stdout)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	text, err := pwhois.EncodeIPResponse(records)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(cmd.stdout, text)
+	return err
+}
+
+// asn runs a routeview, registry, or netblock lookup and prints its record.
+func (cmd *command) asn(ctx context.Context, name string, args []string) error {
+	flags := flag.NewFlagSet("pwhois "+name, flag.ContinueOnError)
+	flags.SetOutput(cmd.stderr)
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(cmd.stderr, "usage: pwhois [flags] %s asn\n", name)
+		return errUsage
+	}
+	asn := flags.Arg(0)
+
+	var (
+		record any
+		text   string
+		err    error
+	)
+	switch name {
+	case "routeview":
+		var routes pwhois.BGPRoutes
+		if routes, err = cmd.client.LookupRouteView(ctx, asn); err == nil {
+			record = routes
+			text, err = pwhois.EncodeRouteViewResponse(routes)
+		}
+	case "registry":
+		var registry pwhois.RegistryRecord
+		if registry, err = cmd.client.LookupRegistry(ctx, asn); err == nil {
+			record = registry
+			text, err = pwhois.EncodeRegistryResponse(registry)
+		}
+	default:
+		var netblock pwhois.NetblockRecord
+		if netblock, err = cmd.client.LookupNetblock(ctx, asn); err == nil {
+			record = netblock
+			text, err = pwhois.EncodeNetblockResponse(netblock)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if cmd.json {
+		return json.NewEncoder(cmd.stdout).Encode(record)
+	}
+	_, err = io.WriteString(cmd.stdout, text)
+	return err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/pwhoistest"
+)
+
+func TestIPReadsArgumentsFilesAndStdin(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+
+	want := testFixtures.IP["192.0.2.1"] + "\n\n" + testFixtures.IP["2001:db8::1"] + "\n"
+	if got := runAgainst(server, "", "ip", "192.0.2.1", "2001:DB8::1", "::ffff:192.0.2.1"); got.code != exitOK || got.stdout != want {
+		t.Errorf("ip from arguments = %d, %q, %q; want %q", got.code, got.stdout, got.stderr, want)
+	}
+
+	file := filepath.Join(t.TempDir(), "addresses")
+	if err := os.WriteFile(file, []byte("# suspects\n192.0.2.1\n\n2001:db8::1\n"), 0o600); err != nil {
+		t.Fatalf("write addresses: %v", err)
+	}
+	if got := runAgainst(server, "", "ip", "-f", file); got.code != exitOK || got.stdout != want {
+		t.Errorf("ip from file = %d, %q, %q; want %q", got.code, got.stdout, got.stderr, want)
+	}
+	if got := runAgainst(server, "192.0.2.1\n2001:db8::1\n", "ip"); got.code != exitOK || got.stdout != want {
+		t.Errorf("ip from stdin = %d, %q, %q; want %q", got.code, got.stdout, got.stderr, want)
+	}
+	if got := runAgainst(server, "2001:db8::1\n", "ip", "-f", "-", "192.0.2.1"); got.code != exitOK || got.stdout != want {
+		t.Errorf("ip from arguments and stdin = %d, %q, %q; want %q", got.code, got.stdout, got.stderr, want)
+	}
+	if got := runAgainst(server, "", "ip", "-f", filepath.Join(t.TempDir(), "missing")); got.code != exitFailure {
+		t.Errorf("missing file exit = %d, want %d", got.code, exitFailure)
+	}
+}
+
+func TestIPReportsUnansweredAndInvalidAddresses(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+
+	got := runAgainst(server, "", "ip", "192.0.2.1", "198.51.100.7")
+	if got.code != exitNoRecords || got.stdout != testFixtures.IP["192.0.2.1"]+"\n" || !strings.Contains(got.stderr, "198.51.100.7: no records") {
+		t.Errorf("unanswered = %d, %q, %q", got.code, got.stdout, got.stderr)
+	}
+	got = runAgainst(server, "", "ip", "198.51.100.7", "not-an-ip", "192.0.2.1")
+	if got.code != exitInvalidInput || !strings.Contains(got.stderr, "not-an-ip") || !strings.Contains(got.stderr, "1 of 3 addresses failed") {
+		t.Errorf("invalid = %d, %q, %q", got.code, got.stdout, got.stderr)
+	}
+	if got := runAgainst(server, "\n# nothing\n", "ip"); got.code != exitInvalidInput {
+		t.Errorf("empty input exit = %d, want %d", got.code, exitInvalidInput)
+	}
+}
+
+func TestIPPrintsRecordsWithUnparsedASPath(t *testing.T) {
+	const record = "IP: 192.0.2.1\nOrigin-AS: 64500\nPrefix: 192.0.2.0/24\nAS-Path: 64501 {64502 64500"
+	server := pwhoistest.NewServer(pwhoistest.Fixtures{IP: map[string]string{"192.0.2.1": record}})
+	defer server.Close()
+
+	if got := runAgainst(server, "", "ip", "192.0.2.1"); got.code != exitOK || got.stdout != record+"\n" {
+		t.Errorf("ip = %d, %q, %q; want %q", got.code, got.stdout, got.stderr, record+"\n")
+	}
+}
+
+func TestASNCommandsPrintNativeTextAndJSON(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+
+	for _, kind := range []string{"routeview", "registry", "netblock"} {
+		fixtures := map[string]map[string]string{"routeview": testFixtures.RouteView, "registry": testFixtures.Registry, "netblock": testFixtures.Netblock}[kind]
+		if got := runAgainst(server, "", kind, "AS64500"); got.code != exitOK || got.stdout != fixtures["64500"]+"\n" {
+			t.Errorf("%s = %d, %q, %q", kind, got.code, got.stdout, got.stderr)
+		}
+	}
+
+	got := runAgainst(server, "", "-json", "netblock", "64500")
+	var netblock pwhois.NetblockRecord
+	if err := json.Unmarshal([]byte(got.stdout), &netblock); err != nil || netblock.OrgName != "Example Networks" || len(netblock.Netblocks) != 1 {
+		t.Errorf("netblock JSON = %q, %v", got.stdout, err)
+	}
+	got = runAgainst(server, "", "-json", "ip", "192.0.2.1", "2001:db8::1")
+	lines := strings.Split(strings.TrimSpace(got.stdout), "\n")
+	var record pwhois.WhoIs
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &record) != nil || record.CountryCode != "NL" {
+		t.Errorf("IP JSON lines = %q", got.stdout)
+	}
+}
@@ -0,0 +1,220 @@
+// Command pwhois looks up IP addresses and ASNs on a PWHOIS server in the
+// manner of whob.
+//
+// Usage:
+//
+//	pwhois [flags] ip [-f file] [address ...]
+//	pwhois [flags] routeview asn
+//	pwhois [flags] registry asn
+//	pwhois [flags] netblock asn
+//	pwhois [flags] enrich [-format f] [-field column] [-header] [-regex re] [-batch-size n] [-rate qps] [file]
+//	pwhois [flags] table [-f file] [-previous file] [-o file] [asn ...]
+//
+// The ip command reads addresses from its arguments, from a file given with
+// -f, or, when there are neither, from standard input, one per line. Blank
+// lines and lines starting with # are skipped. Any number of addresses may be
+// given; they are sent in batches of at most the server's batch size.
+//
+// Records are printed in the native PWHOIS text format, or as JSON with
+// -json, one IP record per line.
+//
+// The enrich command copies a log from file or standard input to standard
+// output, annotating each row with the origin AS, org name, and country of
+// its addresses. The -format flag selects text, csv, tsv, or jsonl input.
+// Text lines get a bracketed annotation for every address -regex finds.
+// CSV and TSV rows get pwhois_origin_as, pwhois_org_name,
+// pwhois_country_code, and pwhois_error columns, and JSON objects get keys of
+// the same names, for the address in the -field column or key, or else the
+// first address -regex finds. New addresses are looked up in batches of
+// -batch-size, at most -rate batch queries per second, and each address that
+// could not be resolved is reported once on standard error.
+//
+// The table command builds a CIDR lookup table for SIEM lookups from the
+// netblocks and routes of ASNs read like the ip command's addresses. Each
+// netblock range becomes its minimal set of CIDR prefixes, and the table has
+// one prefix,asn,org_name,net_name,source row per distinct prefix. It is
+// written to standard output, or replaced atomically in the -o file. With
+// -previous, the rows added, removed, and changed since that table are
+// summarized on standard error for review before deployment. ASNs with
+// neither netblocks nor routes are reported on standard error.
+//
+// The flags are:
+//
+//	-server host
+//		PWHOIS server host (default whois.pwhois.org)
+//	-port n
+//		PWHOIS server port (default 43)
+//	-timeout duration
+//		connection and lookup timeout (default 5s)
+//	-max-response-bytes n
+//		largest response accepted (default 8 MiB)
+//	-json
+//		print records as JSON
+//
+// The exit status identifies the error class of the first failure:
+//
+//	0   every lookup was answered
+//	1   other failure
+//	2   usage error
+//	3   invalid input (ErrInvalidInput)
+//	4   no records (ErrNoRecords)
+//	5   connection failure (ErrConnection)
+//	6   timeout (ErrTimeout)
+//	7   rate limited (ErrRateLimited)
+//	8   response too large (ErrResponseTooLarge)
+//	9   malformed response (ErrMalformedResponse)
+//	10  canceled (ErrCanceled)
+//
+// For the ip and enrich commands, addresses that failed take precedence over
+// addresses the server did not answer. For the table command, an ASN with
+// neither netblocks nor routes counts as no records; the table is still
+// written.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/georgestarcher/pwhois"
+)
+
+const (
+	exitOK                = 0
+	exitFailure           = 1
+	exitUsage             = 2
+	exitInvalidInput      = 3
+	exitNoRecords         = 4
+	exitConnection        = 5
+	exitTimeout           = 6
+	exitRateLimited       = 7
+	exitResponseTooLarge  = 8
+	exitMalformedResponse = 9
+	exitCanceled          = 10
+)
+
+// errUsage reports a command line that could not be understood. The message
+// has already been written.
+var errUsage = errors.New("usage error")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// command is the state shared by every subcommand.
+type command struct {
+	// server is the configuration client was built from.
+	server pwhois.WhoisServer
+	client *pwhois.Client
+	json   bool
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// run executes one command line and returns its exit status.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	defaults := pwhois.WhoisServer{}
+	defaults.SetDefaultValues()
+
+	flags := flag.NewFlagSet("pwhois", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, "usage: pwhois [flags] ip [-f file] [address ...]\n"+
+			"       pwhois [flags] routeview|registry|netblock asn\n"+
+			"       pwhois [flags] enrich [enrich flags] [file]\n"+
+			"       pwhois [flags] table [table flags] [asn ...]\n\nflags:\n")
+		flags.PrintDefaults()
+	}
+	server := flags.String("server", defaults.Server, "PWHOIS server `host`")
+	port := flags.Int("port", defaults.Port, "PWHOIS server `port`")
+	timeout := flags.Duration("timeout", defaults.Timeout, "connection and lookup `timeout`")
+	maxResponseBytes := flags.Int64("max-response-bytes", defaults.MaxResponseBytes, "largest response accepted, in `bytes`")
+	jsonOutput := flags.Bool("json", false, "print records as JSON")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	if *timeout <= 0 || *maxResponseBytes <= 0 {
+		fmt.Fprintln(stderr, "pwhois: -timeout and -max-response-bytes must be positive")
+		return exitUsage
+	}
+
+	whoisServer := pwhois.WhoisServer{
+		Server:           *server,
+		Port:             *port,
+		Timeout:          *timeout,
+		MaxResponseBytes: *maxResponseBytes,
+	}
+	client, err := pwhois.NewClient(pwhois.ClientConfig{Server: whoisServer})
+	if err != nil {
+		fmt.Fprintf(stderr, "pwhois: %v\n", err)
+		return exitCode(err)
+	}
+	cmd := &command{server: whoisServer, client: client, json: *jsonOutput, stdin: stdin, stdout: stdout, stderr: stderr}
+
+	name, rest := flags.Arg(0), flags.Args()[1:]
+	switch name {
+	case "ip":
+		err = cmd.ip(ctx, rest)
+	case "routeview", "registry", "netblock":
+		err = cmd.asn(ctx, name, rest)
+	case "enrich":
+		err = cmd.enrich(ctx, rest)
+	case "table":
+		err = cmd.table(ctx, rest)
+	default:
+		fmt.Fprintf(stderr, "pwhois: unknown command %q\n", name)
+		flags.Usage()
+		return exitUsage
+	}
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errUsage):
+		return exitUsage
+	default:
+		fmt.Fprintf(stderr, "pwhois: %v\n", err)
+		return exitCode(err)
+	}
+}
+
+// exitCode maps an error to the exit status of its stable error class.
+func exitCode(err error) int {
+	switch pwhois.ClassifyProviderError(err) {
+	case pwhois.ProviderErrorNone:
+		return exitOK
+	case pwhois.ProviderErrorInvalidInput:
+		return exitInvalidInput
+	case pwhois.ProviderErrorNoRecords:
+		return exitNoRecords
+	case pwhois.ProviderErrorConnection:
+		return exitConnection
+	case pwhois.ProviderErrorTimeout:
+		return exitTimeout
+	case pwhois.ProviderErrorRateLimited:
+		return exitRateLimited
+	case pwhois.ProviderErrorResponseTooLarge:
+		return exitResponseTooLarge
+	case pwhois.ProviderErrorMalformedResponse:
+		return exitMalformedResponse
+	case pwhois.ProviderErrorCanceled:
+		return exitCanceled
+	default:
+		return exitFailure
+	}
+}
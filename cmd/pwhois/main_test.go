@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/pwhoistest"
+)
+
+var testFixtures = pwhoistest.Fixtures{
+	IP: map[string]string{
+		"192.0.2.1":   "IP: 192.0.2.1\nOrigin-AS: 64500\nPrefix: 192.0.2.0/24\nOrg-Name: Example Networks\nCountry-Code: US",
+		"2001:db8::1": "IP: 2001:db8::1\nOrigin-AS: 64501\nPrefix: 2001:db8::/32\nOrg-Name: Example Six\nCountry-Code: NL",
+	},
+	RouteView: map[string]string{
+		"64500": "*> 192.0.2.0/24 | Jul 18 2026 00:00:04 | Jul 18 2026 00:00:04 | May 28 2026 06:56:01 | 192.0.2.254 | 64501 64500",
+	},
+	Registry: map[string]string{
+		"64500": "Org-ID: EXAMPLE\nOrg-Name: Example Registry Organization\nCan-Allocate: 0",
+	},
+	Netblock: map[string]string{
+		"64500": "Origin-AS: 64500\nOrg-Name: Example Networks\n*> 192.0.2.0 - 192.0.2.255 | EXAMPLE-NET | reassignment | 2019-05-25 | 2019-09-25 | Jun 28 2019 16:53:01 | Jul 18 2026 03:19:32 | TEST",
+	},
+}
+
+// invocation is the outcome of one run.
+type invocation struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+// runAgainst runs the command line against server with stdin as input.
+func runAgainst(server *pwhoistest.Server, stdin string, args ...string) invocation {
+	host, port, _ := net.SplitHostPort(server.Addr())
+	return runArgs(stdin, append([]string{"-server", host, "-port", port}, args...)...)
+}
+
+func runArgs(stdin string, args ...string) invocation {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return invocation{code: code, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+func TestRunMapsErrorClassesToExitCodes(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	closedPort := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	_ = listener.Close()
+
+	tests := []struct {
+		name   string
+		faults pwhoistest.FaultFunc
+		args   []string
+		want   int
+	}{
+		{name: "answered", args: []string{"registry", "64500"}, want: exitOK},
+		{name: "invalid ASN", args: []string{"registry", "AS-x"}, want: exitInvalidInput},
+		{name: "no records", args: []string{"netblock", "64999"}, want: exitNoRecords},
+		{name: "rate limited", faults: func(pwhoistest.Query) pwhoistest.Fault { return pwhoistest.Fault{RateLimit: true} }, args: []string{"routeview", "64500"}, want: exitRateLimited},
+		{name: "malformed", faults: func(pwhoistest.Query) pwhoistest.Fault { return pwhoistest.Fault{MalformedLine: "garbage"} }, args: []string{"registry", "64500"}, want: exitMalformedResponse},
+		{name: "too large", faults: func(pwhoistest.Query) pwhoistest.Fault { return pwhoistest.Fault{OversizeBytes: 4096} }, args: []string{"-max-response-bytes", "1024", "registry", "64500"}, want: exitResponseTooLarge},
+		{name: "timeout", faults: func(pwhoistest.Query) pwhoistest.Fault { return pwhoistest.Fault{Delay: 2 * time.Second} }, args: []string{"-timeout", "50ms", "registry", "64500"}, want: exitTimeout},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server.SetFaults(test.faults)
+			if got := runAgainst(server, "", test.args...); got.code != test.want {
+				t.Errorf("exit = %d, want %d; stderr %q", got.code, test.want, got.stderr)
+			}
+		})
+	}
+	server.SetFaults(nil)
+
+	if got := runArgs("", "-server", "127.0.0.1", "-port", closedPort, "registry", "64500"); got.code != exitConnection {
+		t.Errorf("connection refused exit = %d, want %d", got.code, exitConnection)
+	}
+	for _, args := range [][]string{{}, {"whois", "64500"}, {"registry"}, {"registry", "1", "2"}, {"-timeout", "0s", "registry", "1"}, {"-nope"}} {
+		if got := runArgs("", args...); got.code != exitUsage || got.stderr == "" {
+			t.Errorf("run(%q) = %d, %q, want usage error", args, got.code, got.stderr)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	host, port, _ := net.SplitHostPort(server.Addr())
+	if code := run(ctx, []string{"-server", host, "-port", port, "registry", "64500"}, nil, &bytes.Buffer{}, &bytes.Buffer{}); code != exitCanceled {
+		t.Errorf("canceled exit = %d, want %d", code, exitCanceled)
+	}
+}
+
+func TestExitCodesAreDistinct(t *testing.T) {
+	codes := map[int]error{}
+	for _, class := range []error{
+		pwhois.ErrInvalidInput, pwhois.ErrNoRecords, pwhois.ErrConnection, pwhois.ErrTimeout,
+		pwhois.ErrRateLimited, pwhois.ErrResponseTooLarge, pwhois.ErrMalformedResponse, pwhois.ErrCanceled,
+	} {
+		code := exitCode(fmt.Errorf("wrapped: %w", class))
+		if code <= exitUsage {
+			t.Errorf("%v exit = %d, want a class-specific code", class, code)
+		}
+		if previous, ok := codes[code]; ok {
+			t.Errorf("%v and %v share exit code %d", class, previous, code)
+		}
+		codes[code] = class
+	}
+}
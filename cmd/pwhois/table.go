@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/lookuptable"
+)
+
+// table generates a prefix lookup table for the ASNs named by args, a file,
+// or standard input and writes it as CSV. With -previous, how it differs from
+// the previous table is summarized on stderr.
+func (cmd *command) table(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("pwhois table", flag.ContinueOnError)
+	flags.SetOutput(cmd.stderr)
+	file := flags.String("f", "", "read ASNs from `file`, or standard input for -")
+	previousPath := flags.String("previous", "", "summarize changes from the table in `file`")
+	outputPath := flags.String("o", "", "write the table to `file` instead of standard output")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	asns := flags.Args()
+	if *file != "" || len(asns) == 0 {
+		fromFile, err := cmd.readAddresses(*file)
+		if err != nil {
+			return err
+		}
+		asns = append(asns, fromFile...)
+	}
+	if len(asns) == 0 {
+		return fmt.Errorf("no ASNs to look up: %w", pwhois.ErrInvalidInput)
+	}
+
+	// The previous table is read first so -o may replace it.
+	var previous lookuptable.Table
+	if *previousPath != "" {
+		input, err := os.Open(*previousPath)
+		if err != nil {
+			return err
+		}
+		previous, err = lookuptable.ReadCSV(input)
+		input.Close()
+		if err != nil {
+			return fmt.Errorf("read %s: %w", *previousPath, err)
+		}
+	}
+
+	table, err := lookuptable.Generate(ctx, cmd.client, asns)
+	if err != nil {
+		return err
+	}
+	if *outputPath == "" {
+		err = table.WriteCSV(cmd.stdout)
+	} else {
+		err = writeTableFile(*outputPath, table)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *previousPath != "" {
+		if err := lookuptable.Compare(previous, table).WriteSummary(cmd.stderr); err != nil {
+			return err
+		}
+	}
+	for _, asn := range table.Missing {
+		fmt.Fprintf(cmd.stderr, "pwhois: %s: no netblocks or routes\n", asn)
+	}
+	if len(table.Missing) > 0 {
+		return fmt.Errorf("%d of %d ASNs not answered: %w", len(table.Missing), len(asns), pwhois.ErrNoRecords)
+	}
+	return nil
+}
+
+// writeTableFile writes table to a temporary file beside path and renames it
+// into place, so a failed run never leaves a partial table to be deployed.
+func writeTableFile(path string, table lookuptable.Table) error {
+	output, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(output.Name())
+	err = output.Chmod(0o644)
+	if err == nil {
+		err = table.WriteCSV(output)
+	}
+	if err != nil {
+		output.Close()
+		return err
+	}
+	if err := output.Close(); err != nil {
+		return err
+	}
+	return os.Rename(output.Name(), path)
+}
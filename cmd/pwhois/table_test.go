@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/georgestarcher/pwhois/pwhoistest"
+)
+
+const testTable = "prefix,asn,org_name,net_name,source\n" +
+	"192.0.2.0/24,64500,Example Networks,EXAMPLE-NET,netblock routeview\n"
+
+func TestTableWritesCSVAndSummarizesChanges(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+
+	if got := runAgainst(server, "# ASNs\nAS64500\n", "table"); got.code != exitOK || got.stdout != testTable || got.stderr != "" {
+		t.Errorf("table from stdin = %+v, want %q", got, testTable)
+	}
+
+	path := filepath.Join(t.TempDir(), "pwhois_prefixes.csv")
+	previous := "prefix,asn,org_name,net_name,source\n" +
+		"192.0.2.0/24,64500,Example Networks,EXAMPLE-NET,netblock\n" +
+		"198.51.100.0/24,64500,Example Networks,LAB-NET,netblock\n"
+	if err := os.WriteFile(path, []byte(previous), 0o644); err != nil {
+		t.Fatalf("write previous table: %v", err)
+	}
+	got := runAgainst(server, "", "table", "-previous", path, "-o", path, "64500")
+	wantSummary := "0 added, 1 removed, 1 changed, 0 unchanged\n" +
+		"- 198.51.100.0/24 asn=\"64500\" org_name=\"Example Networks\" net_name=\"LAB-NET\" source=\"netblock\"\n" +
+		"~ 192.0.2.0/24 source \"netblock\" -> \"netblock routeview\"\n"
+	if got.code != exitOK || got.stdout != "" || got.stderr != wantSummary {
+		t.Errorf("table -previous -o = %+v, want summary %q", got, wantSummary)
+	}
+	if written, err := os.ReadFile(path); err != nil || string(written) != testTable {
+		t.Errorf("written table = %q, %v; want %q", written, err, testTable)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("table directory has %d entries, want only the table", len(entries))
+	}
+}
+
+func TestTableReportsMissingASNsAndBadPreviousTables(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+
+	got := runAgainst(server, "", "table", "64500", "64999")
+	if got.code != exitNoRecords || got.stdout != testTable || !strings.Contains(got.stderr, "pwhois: 64999: no netblocks or routes") {
+		t.Errorf("table with missing ASN = %+v", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "previous.csv")
+	if err := os.WriteFile(path, []byte("cidr,asn\n"), 0o644); err != nil {
+		t.Fatalf("write previous table: %v", err)
+	}
+	if got := runAgainst(server, "", "table", "-previous", path, "64500"); got.code != exitInvalidInput || got.stdout != "" {
+		t.Errorf("table with bad previous table = %+v", got)
+	}
+	if got := runAgainst(server, "", "table", "AS-x"); got.code != exitInvalidInput {
+		t.Errorf("table with bad ASN = %+v", got)
+	}
+}
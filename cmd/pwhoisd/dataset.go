@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/internal/check"
+)
+
+// route is one line of the prefix file.
+type route struct {
+	prefix      netip.Prefix
+	originAS    string
+	asPath      []int
+	nextHop     netip.Addr
+	originated  time.Time
+	asOrgName   string
+	orgName     string
+	netName     string
+	countryCode string
+}
+
+// dataset is the data pwhoisd answers from. It is read-only once loaded.
+type dataset struct {
+	loaded    time.Time
+	routes    map[netip.Prefix]*route
+	byOrigin  map[string][]*route
+	registry  map[string]string
+	netblocks map[string]string
+}
+
+func newDataset(loaded time.Time) *dataset {
+	return &dataset{
+		loaded:    loaded.UTC(),
+		routes:    make(map[netip.Prefix]*route),
+		byOrigin:  make(map[string][]*route),
+		registry:  make(map[string]string),
+		netblocks: make(map[string]string),
+	}
+}
+
+// loadDataset reads the files named by paths. Empty paths are skipped, so any
+// subset of the data can be served.
+func loadDataset(prefixes, registry, netblocks string, loaded time.Time) (*dataset, error) {
+	data := newDataset(loaded)
+	for _, file := range []struct {
+		path string
+		load func(io.Reader) error
+	}{
+		{prefixes, data.loadPrefixes},
+		{registry, data.loadRegistry},
+		{netblocks, data.loadNetblocks},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if err := loadFile(file.path, file.load); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func loadFile(path string, load func(io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := load(file); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// loadPrefixes reads a prefix-to-origin table. Each line is
+//
+//	prefix | origin-as | as-path | next-hop | originated | as-org-name | org-name | net-name | country-code
+//
+// where only the prefix and origin are required and trailing fields may be
+// omitted. The AS path defaults to the origin, the next hop to the
+// unspecified address, and the originated time, in RFC 3339 form, to the load
+// time. Blank lines and lines starting with # are ignored.
+func (data *dataset) loadPrefixes(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, err := data.parseRoute(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if _, ok := data.routes[entry.prefix]; ok {
+			return fmt.Errorf("line %d: duplicate prefix %s", lineNumber, entry.prefix)
+		}
+		data.routes[entry.prefix] = entry
+		data.byOrigin[entry.originAS] = append(data.byOrigin[entry.originAS], entry)
+	}
+	return scanner.Err()
+}
+
+func (data *dataset) parseRoute(line string) (*route, error) {
+	fields := strings.Split(line, "|")
+	for index := range fields {
+		fields[index] = strings.TrimSpace(fields[index])
+	}
+	if len(fields) < 2 || len(fields) > 9 {
+		return nil, fmt.Errorf("expected 2 to 9 fields, got %d", len(fields))
+	}
+	fields = append(fields, make([]string, 9-len(fields))...)
+
+	prefix, err := netip.ParsePrefix(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	originAS, ok := canonicalASN(fields[1])
+	if !ok {
+		return nil, fmt.Errorf("invalid origin AS %q", fields[1])
+	}
+	entry := &route{
+		prefix:      prefix.Masked(),
+		originAS:    originAS,
+		originated:  data.loaded,
+		asOrgName:   fields[5],
+		orgName:     fields[6],
+		netName:     fields[7],
+		countryCode: fields[8],
+	}
+
+	asPath := strings.Fields(fields[2])
+	if len(asPath) == 0 {
+		asPath = []string{originAS}
+	}
+	for _, value := range asPath {
+		asn, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AS path %q", fields[2])
+		}
+		entry.asPath = append(entry.asPath, int(asn))
+	}
+	if strconv.Itoa(entry.asPath[len(entry.asPath)-1]) != originAS {
+		return nil, fmt.Errorf("AS path %q does not end at origin AS %s", fields[2], originAS)
+	}
+
+	if fields[3] == "" {
+		entry.nextHop = netip.IPv4Unspecified()
+		if prefix.Addr().Is6() {
+			entry.nextHop = netip.IPv6Unspecified()
+		}
+	} else if entry.nextHop, err = netip.ParseAddr(fields[3]); err != nil {
+		return nil, err
+	}
+	if fields[4] != "" {
+		if entry.originated, err = time.Parse(time.RFC3339, fields[4]); err != nil {
+			return nil, err
+		}
+		entry.originated = entry.originated.UTC()
+	}
+	return entry, nil
+}
+
+// loadRegistry reads registry records in the native "Key: value" form,
+// separated by blank lines. Each record names the ASN it answers for with an
+// Origin-AS line, which is not sent to clients. An ASN may have several
+// records.
+func (data *dataset) loadRegistry(reader io.Reader) error {
+	return readRecords(reader, func(lineNumber int, lines []string) error {
+		var asn string
+		kept := lines[:0]
+		for _, line := range lines {
+			if value, ok := strings.CutPrefix(line, "Origin-AS: "); ok {
+				asn = value
+				continue
+			}
+			kept = append(kept, line)
+		}
+		canonical, ok := canonicalASN(asn)
+		if !ok {
+			return fmt.Errorf("record at line %d: missing or invalid Origin-AS", lineNumber)
+		}
+		record := strings.Join(kept, "\n")
+		if previous, ok := data.registry[canonical]; ok {
+			record = previous + "\n\n" + record
+		}
+		data.registry[canonical] = record
+		return nil
+	})
+}
+
+// loadNetblocks reads netblock responses in the native form: a "Key: value"
+// header with an Origin-AS line followed by "*>" block lines. Responses are
+// separated by blank lines and sent as written.
+func (data *dataset) loadNetblocks(reader io.Reader) error {
+	return readRecords(reader, func(lineNumber int, lines []string) error {
+		var asn string
+		for _, line := range lines {
+			if value, ok := strings.CutPrefix(line, "Origin-AS: "); ok {
+				asn = value
+			}
+		}
+		canonical, ok := canonicalASN(asn)
+		if !ok {
+			return fmt.Errorf("record at line %d: missing or invalid Origin-AS", lineNumber)
+		}
+		if _, ok := data.netblocks[canonical]; ok {
+			return fmt.Errorf("record at line %d: duplicate netblocks for AS%s", lineNumber, canonical)
+		}
+		data.netblocks[canonical] = strings.Join(lines, "\n")
+		return nil
+	})
+}
+
+// readRecords calls record with the lines of each blank-line separated record
+// and the line number it starts on. Lines starting with # are ignored.
+func readRecords(reader io.Reader, record func(int, []string) error) error {
+	scanner := bufio.NewScanner(reader)
+	var lines []string
+	start := 0
+	flush := func() error {
+		if len(lines) == 0 {
+			return nil
+		}
+		err := record(start, lines)
+		lines = nil
+		return err
+	}
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		switch {
+		case strings.HasPrefix(line, "#"):
+		case line == "":
+			if err := flush(); err != nil {
+				return err
+			}
+		default:
+			if len(lines) == 0 {
+				start = lineNumber
+			}
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// lookup returns the most specific route covering address.
+func (data *dataset) lookup(address netip.Addr) (*route, bool) {
+	address = address.Unmap()
+	for bits := address.BitLen(); bits >= 0; bits-- {
+		prefix, err := address.Prefix(bits)
+		if err != nil {
+			return nil, false
+		}
+		if entry, ok := data.routes[prefix]; ok {
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+// ipRecord returns the IP record for address.
+func (data *dataset) ipRecord(address netip.Addr) (pwhois.WhoIs, bool) {
+	entry, ok := data.lookup(address)
+	if !ok {
+		return pwhois.WhoIs{}, false
+	}
+	return pwhois.WhoIs{
+		IP:                  address.Unmap().String(),
+		OriginAS:            entry.originAS,
+		Prefix:              entry.prefix.String(),
+		AsnPath:             joinASPath(entry.asPath),
+		AsnOrgName:          entry.asOrgName,
+		OrgName:             entry.orgName,
+		NetworkName:         entry.netName,
+		CacheDate:           data.loaded,
+		CountryCode:         entry.countryCode,
+		RouteOriginatedDate: entry.originated,
+		RouteOriginatedTS:   entry.originated.Unix(),
+	}, true
+}
+
+// routeView returns the routes originated by asn in file order.
+func (data *dataset) routeView(asn string) pwhois.BGPRoutes {
+	routes := pwhois.BGPRoutes{Asn: asn}
+	for _, entry := range data.byOrigin[asn] {
+		routes.Routes = append(routes.Routes, pwhois.BGPRoute{
+			Prefix:         entry.prefix.String(),
+			CreateDate:     data.loaded,
+			ModifyDate:     data.loaded,
+			OriginatedDate: entry.originated,
+			NextHop:        entry.nextHop.String(),
+			ASPath:         entry.asPath,
+		})
+	}
+	return routes
+}
+
+func joinASPath(path []int) string {
+	asns := make([]string, len(path))
+	for index, asn := range path {
+		asns[index] = strconv.Itoa(asn)
+	}
+	return strings.Join(asns, " ")
+}
+
+// canonicalASN strips an optional AS prefix and leading zeros.
+func canonicalASN(value string) (string, bool) {
+	number, err := check.ParseASN(value)
+	if err != nil {
+		return "", false
+	}
+	return strconv.FormatUint(uint64(number), 10), true
+}
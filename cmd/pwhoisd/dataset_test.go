@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testLoaded = time.Date(2026, time.July, 18, 0, 0, 4, 0, time.UTC)
+
+const testPrefixes = `# prefix | origin | path | next hop | originated | AS org | org | net | country
+192.0.2.0/24 | 64500 | 64501 64500 | 192.0.2.254 | 2026-05-28T06:56:01Z | Example AS | Example Networks | EXAMPLE-NET | US
+192.0.2.128/25 | AS64502
+10.0.0.0/8 | 64500 | | | | | Private
+
+2001:db8::/32 | 64501 | 64501 | 2001:db8::fe
+198.18.0.0/15 | AS64510 | 64496 064510
+`
+
+const testRegistry = `# registry records
+Origin-AS: 64500
+Org-ID: EXAMPLE
+Org-Name: Example Registry Organization
+Register-Date: 2019-05-25
+
+Origin-AS: 64500
+Org-ID: EXAMPLE-2
+Org-Name: Second Example Organization
+`
+
+const testNetblocks = `Origin-AS: 64500
+Org-Name: Example Networks
+*> 192.0.2.0 - 192.0.2.255 | EXAMPLE-NET | reassignment | 2019-05-25 | 2019-09-25 | Jun 28 2019 16:53:01 | Jul 18 2026 03:19:32 | TEST
+`
+
+func loadTestDataset(t *testing.T) *dataset {
+	t.Helper()
+	directory := t.TempDir()
+	paths := make([]string, 3)
+	for index, content := range []string{testPrefixes, testRegistry, testNetblocks} {
+		paths[index] = filepath.Join(directory, []string{"prefixes", "registry", "netblocks"}[index])
+		if err := os.WriteFile(paths[index], []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", paths[index], err)
+		}
+	}
+	data, err := loadDataset(paths[0], paths[1], paths[2], testLoaded)
+	if err != nil {
+		t.Fatalf("load dataset: %v", err)
+	}
+	return data
+}
+
+func TestDatasetLongestPrefixMatch(t *testing.T) {
+	data := loadTestDataset(t)
+	tests := []struct {
+		address string
+		prefix  string
+	}{
+		{"192.0.2.1", "192.0.2.0/24"},
+		{"192.0.2.200", "192.0.2.128/25"},
+		{"::ffff:192.0.2.200", "192.0.2.128/25"},
+		{"10.1.2.3", "10.0.0.0/8"},
+		{"2001:db8::1", "2001:db8::/32"},
+		{"198.51.100.1", ""},
+	}
+	for _, test := range tests {
+		entry, ok := data.lookup(netip.MustParseAddr(test.address))
+		if test.prefix == "" {
+			if ok {
+				t.Errorf("lookup(%s) = %s, want no route", test.address, entry.prefix)
+			}
+			continue
+		}
+		if !ok || entry.prefix.String() != test.prefix {
+			t.Errorf("lookup(%s) = %v, %v, want %s", test.address, entry, ok, test.prefix)
+		}
+	}
+
+	entry, _ := data.lookup(netip.MustParseAddr("192.0.2.200"))
+	if entry.originAS != "64502" || joinASPath(entry.asPath) != "64502" || entry.nextHop != netip.IPv4Unspecified() || !entry.originated.Equal(testLoaded) {
+		t.Errorf("defaulted route = %+v", entry)
+	}
+	if entry, _ := data.lookup(netip.MustParseAddr("198.18.0.1")); entry == nil || joinASPath(entry.asPath) != "64496 64510" {
+		t.Errorf("route with a zero-padded origin in its path = %+v", entry)
+	}
+	if len(data.byOrigin["64500"]) != 2 || len(data.registry) != 1 || len(data.netblocks) != 1 {
+		t.Errorf("dataset = %d AS64500 routes, %d registry, %d netblocks", len(data.byOrigin["64500"]), len(data.registry), len(data.netblocks))
+	}
+	if strings.Contains(data.registry["64500"], "Origin-AS") || strings.Count(data.registry["64500"], "Org-ID:") != 2 {
+		t.Errorf("registry = %q", data.registry["64500"])
+	}
+}
+
+func TestDatasetRejectsBadFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		load func(*dataset, string) error
+		text string
+		want string
+	}{
+		{"bad prefix", loadPrefixesText, "192.0.2.0/33 | 64500\n", "line 1"},
+		{"missing origin", loadPrefixesText, "# header\n192.0.2.0/24\n", "line 2"},
+		{"bad origin", loadPrefixesText, "192.0.2.0/24 | ASX\n", "origin AS"},
+		{"path not ending at origin", loadPrefixesText, "192.0.2.0/24 | 64500 | 64500 64501\n", "does not end"},
+		{"bad next hop", loadPrefixesText, "192.0.2.0/24 | 64500 | | nowhere\n", "line 1"},
+		{"bad originated", loadPrefixesText, "192.0.2.0/24 | 64500 | | | yesterday\n", "line 1"},
+		{"duplicate prefix", loadPrefixesText, "192.0.2.0/24 | 64500\n192.0.2.1/24 | 64501\n", "duplicate prefix"},
+		{"registry without ASN", loadRegistryText, "Org-ID: EXAMPLE\n\nOrigin-AS: 1\nOrg-ID: X\n", "line 1"},
+		{"duplicate netblocks", loadNetblocksText, testNetblocks + "\n" + testNetblocks, "line 5"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.load(newDataset(testLoaded), test.text)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error = %v, want one mentioning %q", err, test.want)
+			}
+		})
+	}
+}
+
+func loadPrefixesText(data *dataset, text string) error {
+	return data.loadPrefixes(strings.NewReader(text))
+}
+
+func loadRegistryText(data *dataset, text string) error {
+	return data.loadRegistry(strings.NewReader(text))
+}
+
+func loadNetblocksText(data *dataset, text string) error {
+	return data.loadNetblocks(strings.NewReader(text))
+}
@@ -0,0 +1,87 @@
+// Command pwhoisd is a PWHOIS-compatible server that answers from local data.
+//
+// It speaks the protocol the pwhois package sends: single and batched IP
+// queries and routeview, registry, and netblock queries, one per connection.
+// Air-gapped networks can point existing tools at it as an internal mirror,
+// and tests can use it as a realistic local stand-in.
+//
+// Usage:
+//
+//	pwhoisd [-listen addr] [-prefixes file] [-registry file] [-netblocks file] [-idle duration] [-max-batch n]
+//
+// The prefix file maps prefixes to origins, one per line:
+//
+//	# prefix | origin-as | as-path | next-hop | originated | as-org-name | org-name | net-name | country-code
+//	192.0.2.0/24 | 64500 | 64501 64500 | 192.0.2.254 | 2026-05-28T06:56:01Z | Example AS | Example Networks | EXAMPLE-NET | US
+//	2001:db8::/32 | 64501
+//
+// Only the prefix and origin are required. IP queries are answered from the
+// most specific covering prefix, and routeview queries list the prefixes of
+// an origin.
+//
+// The registry and netblock files hold responses in the native PWHOIS text
+// form, separated by blank lines. Each registry record carries an
+// "Origin-AS: N" line naming the ASN it answers for; the line is not sent to
+// clients. Each netblock response is keyed by its own Origin-AS header.
+//
+// A client must send each request line within -idle. Connections with a line
+// longer than 4 KiB or a batch of more than -max-batch addresses are closed
+// without an answer.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/georgestarcher/pwhois/internal/wire"
+)
+
+func main() {
+	listen := flag.String("listen", ":43", "address to listen on")
+	prefixes := flag.String("prefixes", "", "prefix-to-origin table `file`")
+	registry := flag.String("registry", "", "registry records `file`")
+	netblocks := flag.String("netblocks", "", "netblock responses `file`")
+	idle := flag.Duration("idle", 30*time.Second, "close connections that wait this long to send a request line")
+	maxBatch := flag.Int("max-batch", 500, "most addresses accepted in one batch query")
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "pwhoisd: unexpected arguments %q\n", flag.Args())
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *idle <= 0 {
+		log.Fatal("pwhoisd: -idle must be positive")
+	}
+	if *maxBatch <= 0 {
+		log.Fatal("pwhoisd: -max-batch must be positive")
+	}
+
+	data, err := loadDataset(*prefixes, *registry, *netblocks, time.Now())
+	if err != nil {
+		log.Fatalf("pwhoisd: load dataset: %v", err)
+	}
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("pwhoisd: %v", err)
+	}
+	log.Printf("pwhoisd: serving %d prefixes, %d registry ASNs, %d netblock ASNs on %s",
+		len(data.routes), len(data.registry), len(data.netblocks), listener.Addr())
+
+	srv := newServer(data, wire.Limits{Idle: *idle, MaxBatch: *maxBatch})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		srv.close()
+	}()
+	if err := srv.serve(listener); err != nil {
+		log.Fatalf("pwhoisd: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/internal/wire"
+)
+
+// server answers PWHOIS queries from a dataset.
+type server struct {
+	data *dataset
+	// limits bounds each request read. Its Idle also bounds each response
+	// write.
+	limits wire.Limits
+
+	serving     sync.WaitGroup
+	mu          sync.Mutex
+	listener    net.Listener
+	connections map[net.Conn]struct{}
+	closed      bool
+}
+
+func newServer(data *dataset, limits wire.Limits) *server {
+	return &server{data: data, limits: limits, connections: make(map[net.Conn]struct{})}
+}
+
+// serve accepts connections on listener until close is called. It returns nil
+// after close and the accept error otherwise.
+func (srv *server) serve(listener net.Listener) error {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		return net.ErrClosed
+	}
+	srv.listener = listener
+	srv.serving.Add(1)
+	srv.mu.Unlock()
+	defer srv.serving.Done()
+
+	for {
+		connection, err := listener.Accept()
+		if err != nil {
+			srv.mu.Lock()
+			closed := srv.closed
+			srv.mu.Unlock()
+			if closed && errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		srv.mu.Lock()
+		if srv.closed {
+			srv.mu.Unlock()
+			_ = connection.Close()
+			return nil
+		}
+		srv.connections[connection] = struct{}{}
+		srv.serving.Add(1)
+		srv.mu.Unlock()
+
+		go func() {
+			defer srv.serving.Done()
+			defer srv.forget(connection)
+			wire.Serve(connection, srv.limits, srv.respond)
+		}()
+	}
+}
+
+// close stops accepting, closes every open connection, and waits for their
+// handlers to return.
+func (srv *server) close() {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		return
+	}
+	srv.closed = true
+	if srv.listener != nil {
+		_ = srv.listener.Close()
+	}
+	for connection := range srv.connections {
+		_ = connection.Close()
+	}
+	srv.mu.Unlock()
+	srv.serving.Wait()
+}
+
+func (srv *server) forget(connection net.Conn) {
+	srv.mu.Lock()
+	delete(srv.connections, connection)
+	srv.mu.Unlock()
+	_ = connection.Close()
+}
+
+// respond writes the answer to request. The caller closes the connection,
+// which ends the response.
+func (srv *server) respond(connection net.Conn, request wire.Request) {
+	_ = connection.SetWriteDeadline(time.Now().Add(srv.limits.Idle))
+	_, _ = io.WriteString(connection, srv.answer(request))
+}
+
+// answer returns the response text for request. Requests the dataset has no
+// data for, and requests that could not be parsed, get an empty response,
+// which clients report as no records.
+func (srv *server) answer(request wire.Request) string {
+	var response string
+	var err error
+	switch request.Kind {
+	case wire.KindIP:
+		var records []pwhois.WhoIs
+		for _, value := range request.Addresses {
+			address, err := netip.ParseAddr(strings.TrimSpace(value))
+			if err != nil {
+				continue
+			}
+			if record, ok := srv.data.ipRecord(address); ok {
+				records = append(records, record)
+			}
+		}
+		response, err = pwhois.EncodeIPResponse(records)
+	case wire.KindRouteView, wire.KindRegistry, wire.KindNetblock:
+		asn, ok := canonicalASN(request.ASN)
+		if !ok {
+			return ""
+		}
+		switch request.Kind {
+		case wire.KindRouteView:
+			response, err = pwhois.EncodeRouteViewResponse(srv.data.routeView(asn))
+		case wire.KindRegistry:
+			response = withNewline(srv.data.registry[asn])
+		default:
+			response = withNewline(srv.data.netblocks[asn])
+		}
+	}
+	if err != nil {
+		log.Printf("pwhoisd: answer %s request: %v", request.Kind, err)
+		return ""
+	}
+	return response
+}
+
+func withNewline(response string) string {
+	if response == "" {
+		return ""
+	}
+	return response + "\n"
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/internal/wire"
+)
+
+// startTestServer serves the test dataset on a loopback port.
+func startTestServer(t *testing.T) pwhois.WhoisServer {
+	t.Helper()
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	srv := newServer(loadTestDataset(t), wire.Limits{Idle: time.Second, MaxBatch: 500})
+	done := make(chan error, 1)
+	go func() { done <- srv.serve(listener) }()
+	t.Cleanup(func() {
+		srv.close()
+		if err := <-done; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+
+	address := listener.Addr().(*net.TCPAddr)
+	server := pwhois.WhoisServer{Server: address.IP.String(), Port: address.Port}
+	server.SetDefaultValues()
+	return server
+}
+
+func TestServerAnswersClientLookups(t *testing.T) {
+	server := startTestServer(t)
+	client, err := pwhois.NewClient(pwhois.ClientConfig{Server: server})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx := context.Background()
+
+	records, err := client.LookupIP(ctx, "192.0.2.1")
+	if err != nil || len(records) != 1 {
+		t.Fatalf("IP lookup = %+v, %v", records, err)
+	}
+	record := records[0]
+	if record.OriginAS != "64500" || record.Prefix != "192.0.2.0/24" || record.AsnPath != "64501 64500" || record.OrgName != "Example Networks" || record.CountryCode != "US" {
+		t.Errorf("IP record = %+v", record)
+	}
+	if !record.RouteOriginatedDate.Equal(time.Date(2026, time.May, 28, 6, 56, 1, 0, time.UTC)) || !record.CacheDate.Equal(testLoaded) {
+		t.Errorf("IP record dates = %v, %v", record.RouteOriginatedDate, record.CacheDate)
+	}
+
+	batch, err := client.LookupIPBatch(ctx, "192.0.2.200", "2001:db8::1", "198.51.100.1")
+	if err != nil {
+		t.Fatalf("batch lookup: %v", err)
+	}
+	answered := 0
+	for _, result := range batch.Results {
+		if result.Status == pwhois.IPLookupAnswered {
+			answered++
+		}
+	}
+	if len(batch.Results) != 3 || answered != 2 {
+		t.Errorf("batch = %+v, want 2 of 3 answered", batch.Results)
+	}
+
+	routes, err := client.LookupRouteView(ctx, "AS64500")
+	if err != nil || len(routes.Routes) != 2 {
+		t.Fatalf("routeview = %+v, %v", routes, err)
+	}
+	if route := routes.Routes[0]; route.Prefix != "192.0.2.0/24" || route.NextHop != "192.0.2.254" || len(route.ASPath) != 2 {
+		t.Errorf("route = %+v", route)
+	}
+	registry, err := client.LookupRegistry(ctx, "64500")
+	if err != nil || registry.Registry.OrgID != "EXAMPLE" {
+		t.Fatalf("registry = %+v, %v", registry, err)
+	}
+	netblock, err := client.LookupNetblock(ctx, "64500")
+	if err != nil || len(netblock.Netblocks) != 1 || netblock.Netblocks[0].Name != "EXAMPLE-NET" {
+		t.Fatalf("netblock = %+v, %v", netblock, err)
+	}
+
+	for _, lookup := range []func() error{
+		func() error { _, err := client.LookupIP(ctx, "198.51.100.1"); return err },
+		func() error { _, err := client.LookupRouteView(ctx, "64999"); return err },
+		func() error { _, err := client.LookupRegistry(ctx, "64501"); return err },
+		func() error { _, err := client.LookupNetblock(ctx, "64501"); return err },
+	} {
+		if err := lookup(); !errors.Is(err, pwhois.ErrNoRecords) {
+			t.Errorf("missing data error = %v, want ErrNoRecords", err)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package pwhois
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// responseTimeLayout is the date and time layout of PWHOIS responses.
+const responseTimeLayout = "Jan 02 2006 15:04:05"
+
+// responseDateLayout is the date-only layout of registry and netblock dates.
+const responseDateLayout = "2006-01-02"
+
+// EncodeIPResponse renders records as an IP lookup response: one
+// "Key: value" record per address, separated by blank lines. Every record
+// must have an IP. An empty slice encodes as an empty response.
+//
+// The Encode functions are the inverse of the lookup parsers: parsing the
+// encoding of a record a lookup returned yields an equal record. Empty text
+// and zero numbers and times are omitted where the format allows, and times
+// are written in UTC to the second. When a text field such as IP is empty,
+// its typed counterpart such as IPAddr is written instead. A value the format
+// cannot carry, such as text with a line break, fails with ErrInvalidInput.
+func EncodeIPResponse(records []WhoIs) (string, error) {
+	encoded := make([]string, 0, len(records))
+	for index, record := range records {
+		text, err := encodeIPRecord(record)
+		if err != nil {
+			return "", fmt.Errorf("encode IP record %d: %w", index+1, err)
+		}
+		encoded = append(encoded, text)
+	}
+	if len(encoded) == 0 {
+		return "", nil
+	}
+	return strings.Join(encoded, "\n\n") + "\n", nil
+}
+
+func encodeIPRecord(record WhoIs) (string, error) {
+	ip := textOrAddr(record.IP, record.IPAddr)
+	if ip == "" {
+		return "", invalidInputError("IP record has no IP")
+	}
+	if _, err := parseResponseAddr("IP", ip); err != nil {
+		return "", invalidInputError(err.Error())
+	}
+	prefix := textOrPrefix(record.Prefix, record.PrefixNet)
+	if _, err := parseResponsePrefix("Prefix", prefix); err != nil {
+		return "", invalidInputError(err.Error())
+	}
+	// AsnPath is written verbatim, even when ASPath could not parse it, so a
+	// record keeps the AS-Path the server sent.
+	asPath := record.AsnPath
+	if asPath == "" && len(record.ASPath) > 0 {
+		asPath = record.ASPath.String()
+	}
+
+	var fields responseFields
+	fields.text("IP", ip)
+	fields.text("Origin-AS", record.OriginAS)
+	fields.text("Prefix", prefix)
+	fields.text("AS-Path", asPath)
+	fields.text("AS-Org-Name", record.AsnOrgName)
+	fields.text("Org-Name", record.OrgName)
+	fields.text("Net-Name", record.NetworkName)
+	fields.time("Cache-Date", record.CacheDate, responseTimeLayout)
+	fields.float("Latitude", record.Latitude)
+	fields.float("Longitude", record.Longitude)
+	fields.text("City", record.City)
+	fields.text("Region", record.Region)
+	fields.text("Country", record.Country)
+	fields.text("Country-Code", record.CountryCode)
+	fields.time("Route-Originated-Date", record.RouteOriginatedDate, responseTimeLayout)
+	fields.int("Route-Originated-TS", record.RouteOriginatedTS)
+	return fields.encode()
+}
+
+// EncodeRouteViewResponse renders routes as a RouteView lookup response, one
+// "*>" line per route. Every route needs a prefix, a next hop, and a
+// non-empty AS path. The ASN is not part of the response.
+func EncodeRouteViewResponse(routes BGPRoutes) (string, error) {
+	var response strings.Builder
+	for index, route := range routes.Routes {
+		line, err := encodeRouteLine(route)
+		if err != nil {
+			return "", fmt.Errorf("encode route %d: %w", index+1, err)
+		}
+		response.WriteString(line)
+		response.WriteByte('\n')
+	}
+	return response.String(), nil
+}
+
+func encodeRouteLine(route BGPRoute) (string, error) {
+	prefix := textOrPrefix(route.Prefix, route.PrefixNet)
+	if prefix == "" {
+		return "", invalidInputError("route has no prefix")
+	}
+	if _, err := parseResponsePrefix("Prefix", prefix); err != nil {
+		return "", invalidInputError(err.Error())
+	}
+	nextHop := textOrAddr(route.NextHop, route.NextHopAddr)
+	if nextHop == "" {
+		return "", invalidInputError("route has no next hop")
+	}
+	if _, err := parseResponseAddr("Next-Hop", nextHop); err != nil {
+		return "", invalidInputError(err.Error())
+	}
+	if len(route.ASPath) == 0 {
+		return "", invalidInputError("route has no AS path")
+	}
+	asPath := make([]string, len(route.ASPath))
+	for index, asn := range route.ASPath {
+		if asn < 0 {
+			return "", invalidInputError(fmt.Sprintf("AS path value at position %d is negative", index+1))
+		}
+		asPath[index] = strconv.Itoa(asn)
+	}
+
+	return fmt.Sprintf("*> %s | %s | %s | %s | %s | %s", prefix,
+		formatResponseTime(route.CreateDate, responseTimeLayout),
+		formatResponseTime(route.ModifyDate, responseTimeLayout),
+		formatResponseTime(route.OriginatedDate, responseTimeLayout),
+		nextHop, strings.Join(asPath, " ")), nil
+}
+
+// EncodeRegistryResponse renders the registry of record as a registry lookup
+// response. Can-Allocate is always written, so even an empty Registry
+// encodes as a record. Register and update dates with no time of day use the
+// date-only layout. The ASN is not part of the response.
+func EncodeRegistryResponse(record RegistryRecord) (string, error) {
+	registry := record.Registry
+	canAllocate := "0"
+	if registry.CanAllocate {
+		canAllocate = "1"
+	}
+
+	var fields responseFields
+	fields.text("Org-Record", registry.OrgRecord)
+	fields.text("Org-ID", registry.OrgID)
+	fields.text("Org-Name", registry.OrgName)
+	fields.text("Can-Allocate", canAllocate)
+	fields.text("Source", registry.Source)
+	fields.text("Street-1", registry.Street1)
+	fields.text("Postal-Code", registry.PostalCode)
+	fields.text("City", registry.City)
+	fields.text("Region", registry.Region)
+	fields.text("Country", registry.Country)
+	fields.text("Country-Code", registry.CountryCode)
+	fields.time("Register-Date", registry.RegisterDate, registryDateLayout(registry.RegisterDate))
+	fields.time("Update-Date", registry.UpdateDate, registryDateLayout(registry.UpdateDate))
+	fields.time("Create-Date", registry.CreateDate, responseTimeLayout)
+	fields.time("Modify-Date", registry.ModifyDate, responseTimeLayout)
+	fields.text("Admin-0-Handle", registry.AdminHandle0)
+	fields.text("Abuse-0-Handle", registry.AbuseHandle0)
+	fields.text("CTech-0-Handle", registry.TechHandle0)
+	fields.text("Comment", registry.Comment)
+	text, err := fields.encode()
+	if err != nil {
+		return "", fmt.Errorf("encode registry record: %w", err)
+	}
+	return text + "\n", nil
+}
+
+func registryDateLayout(value time.Time) string {
+	if hour, minute, second := value.UTC().Clock(); hour == 0 && minute == 0 && second == 0 {
+		return responseDateLayout
+	}
+	return responseTimeLayout
+}
+
+// EncodeNetblockResponse renders record as a netblock lookup response: a
+// "Key: value" header followed by one "*>" line per netblock. The header must
+// have at least one field and there must be at least one netblock. Netblock
+// names, types, and sources are single words, and register and update dates
+// are written to the day. The ASN is not part of the response; Origin-AS
+// carries OriginAs.
+func EncodeNetblockResponse(record NetblockRecord) (string, error) {
+	var fields responseFields
+	fields.text("Origin-AS", record.OriginAs)
+	fields.int("AS", record.AS)
+	fields.text("AS-Source", record.ASSource)
+	fields.int("Org", record.Org)
+	fields.text("Org-ID", record.OrgID)
+	fields.text("Org-Name", record.OrgName)
+	fields.text("Org-Source", record.OrgSource)
+	header, err := fields.encode()
+	if err != nil {
+		return "", fmt.Errorf("encode netblock header: %w", err)
+	}
+	if header == "" {
+		return "", invalidInputError("netblock record has no header fields")
+	}
+	if len(record.Netblocks) == 0 {
+		return "", invalidInputError("netblock record has no netblocks")
+	}
+
+	var response strings.Builder
+	response.WriteString(header)
+	response.WriteByte('\n')
+	for index, block := range record.Netblocks {
+		line, err := encodeNetblockLine(block)
+		if err != nil {
+			return "", fmt.Errorf("encode netblock %d: %w", index+1, err)
+		}
+		response.WriteString(line)
+		response.WriteByte('\n')
+	}
+	return response.String(), nil
+}
+
+func encodeNetblockLine(block Netblock) (string, error) {
+	start, end := textOrAddr("", block.RangeStart), textOrAddr("", block.RangeEnd)
+	if block.Range != "" {
+		var ok bool
+		start, end, ok = strings.Cut(block.Range, "-")
+		if !ok {
+			return "", invalidInputError(fmt.Sprintf("netblock range %q is not start-end", block.Range))
+		}
+		start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+	}
+	if start == "" || end == "" {
+		return "", invalidInputError("netblock has no range")
+	}
+	if _, _, err := parseNetblockRange(start, end); err != nil {
+		return "", invalidInputError(err.Error())
+	}
+	for _, word := range []struct{ field, value string }{
+		{"name", block.Name},
+		{"type", block.Type},
+		{"source", block.Source},
+	} {
+		if word.value == "" || strings.ContainsAny(word.value, " \t\r\n") {
+			return "", invalidInputError(fmt.Sprintf("netblock %s %q is not a single word", word.field, word.value))
+		}
+	}
+
+	return fmt.Sprintf("*> %s - %s | %s | %s | %s | %s | %s | %s | %s", start, end, block.Name, block.Type,
+		formatResponseTime(block.RegisterDate, responseDateLayout),
+		formatResponseTime(block.UpdateDate, responseDateLayout),
+		formatResponseTime(block.CreateDate, responseTimeLayout),
+		formatResponseTime(block.ModifyDate, responseTimeLayout),
+		block.Source), nil
+}
+
+// responseFields accumulates "Key: value" lines, skipping empty values and
+// remembering the first value that cannot be written.
+type responseFields struct {
+	lines []string
+	err   error
+}
+
+func (fields *responseFields) text(key, value string) {
+	if value == "" || fields.err != nil {
+		return
+	}
+	if strings.ContainsAny(value, "\r\n") || strings.TrimSpace(value) != value {
+		fields.err = invalidInputError(fmt.Sprintf("%s value %q has surrounding space or a line break", key, value))
+		return
+	}
+	fields.lines = append(fields.lines, key+": "+value)
+}
+
+func (fields *responseFields) int(key string, value int64) {
+	if value != 0 {
+		fields.text(key, strconv.FormatInt(value, 10))
+	}
+}
+
+func (fields *responseFields) float(key string, value float64) {
+	if value != 0 {
+		fields.text(key, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+}
+
+func (fields *responseFields) time(key string, value time.Time, layout string) {
+	if !value.IsZero() {
+		fields.text(key, formatResponseTime(value, layout))
+	}
+}
+
+func (fields *responseFields) encode() (string, error) {
+	if fields.err != nil {
+		return "", fields.err
+	}
+	return strings.Join(fields.lines, "\n"), nil
+}
+
+func formatResponseTime(value time.Time, layout string) string {
+	return value.UTC().Format(layout)
+}
+
+func textOrAddr(text string, address netip.Addr) string {
+	if text == "" && address.IsValid() {
+		return address.String()
+	}
+	return text
+}
+
+func textOrPrefix(text string, prefix netip.Prefix) string {
+	if text == "" && prefix.IsValid() {
+		return prefix.String()
+	}
+	return text
+}
@@ -0,0 +1,181 @@
+package pwhois
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	encodeIPFixture = "IP: 192.0.2.1\nOrigin-AS: 64500\nPrefix: 192.0.2.0/24\nAS-Path: 64501 {64502,64503} 64500\n" +
+		"AS-Org-Name: Example AS\nOrg-Name: Example Networks\nNet-Name: EXAMPLE-NET\nCache-Date: Jul 18 2026 00:00:04\n" +
+		"Latitude: 37.751\nLongitude: -97.822\nCity: Wichita\nRegion: Kansas\nCountry: United States\nCountry-Code: US\n" +
+		"Route-Originated-Date: May 28 2026 06:56:01\nRoute-Originated-TS: 1779951361\n\n" +
+		"IP: 2001:db8::1\nOrigin-AS: 64501\nPrefix: 2001:db8::/32\n"
+	encodeRouteViewFixture = "*> 192.0.2.0/24 | Jul 18 2026 00:00:04 | Jul 18 2026 00:00:04 | May 28 2026 06:56:01 | 192.0.2.254 | 64501 64500\n" +
+		"*> 2001:db8::/32 | Jul 18 2026 00:00:04 | Jul 19 2026 01:02:03 | May 28 2026 06:56:01 | 2001:db8::fe | 64500\n"
+	encodeRegistryFixture = "Org-Record: 12345\nOrg-ID: EXAMPLE\nOrg-Name: Example Registry Organization\nCan-Allocate: 1\n" +
+		"Source: TEST\nStreet-1: 1 Example Way\nPostal-Code: 01234\nCity: Wichita\nRegion: KS\nCountry: United States\n" +
+		"Country-Code: US\nRegister-Date: 2019-05-25\nUpdate-Date: Sep 25 2019 10:11:12\nCreate-Date: Jun 28 2019 16:53:01\n" +
+		"Modify-Date: Jul 18 2026 03:19:32\nAdmin-0-Handle: ADMIN-TEST\nAbuse-0-Handle: ABUSE-TEST\nCTech-0-Handle: TECH-TEST\n" +
+		"Comment: Example: a comment with a delimiter\n"
+	encodeNetblockFixture = "Origin-AS: 64500\nAS: 64500\nAS-Source: TEST\nOrg: 1\nOrg-ID: TEST\nOrg-Name: Example Networks\nOrg-Source: TEST\n" +
+		"*> 192.0.2.0 - 192.0.2.255 | EXAMPLE-NET | reassignment | 2019-05-25 | 2019-09-25 | Jun 28 2019 16:53:01 | Jul 18 2026 03:19:32 | TEST\n" +
+		"*> 2001:db8:: - 2001:db8::ffff | EXAMPLE-V6 | allocation | 2019-05-25 | 2019-09-25 | Jun 28 2019 16:53:01 | Jul 18 2026 03:19:32 | TEST\n"
+)
+
+func TestEncodersReproduceNativeResponses(t *testing.T) {
+	records, err := parseIpResponse(encodeIPFixture)
+	if err != nil {
+		t.Fatalf("parse IP fixture: %v", err)
+	}
+	encoded, err := EncodeIPResponse(records)
+	assertRoundTrip(t, "IP", encoded, err, encodeIPFixture)
+	if reparsed, err := parseIpResponse(encoded); err != nil || !reflect.DeepEqual(reparsed, records) {
+		t.Errorf("IP round trip = %+v, %v, want %+v", reparsed, err, records)
+	}
+
+	routes, err := parseBgpResponse(encodeRouteViewFixture)
+	if err != nil {
+		t.Fatalf("parse routeview fixture: %v", err)
+	}
+	encoded, err = EncodeRouteViewResponse(BGPRoutes{Asn: "64500", Routes: routes})
+	assertRoundTrip(t, "routeview", encoded, err, encodeRouteViewFixture)
+	if reparsed, err := parseBgpResponse(encoded); err != nil || !reflect.DeepEqual(reparsed, routes) {
+		t.Errorf("routeview round trip = %+v, %v, want %+v", reparsed, err, routes)
+	}
+
+	registry, err := parseRegistryResponse(encodeRegistryFixture)
+	if err != nil {
+		t.Fatalf("parse registry fixture: %v", err)
+	}
+	encoded, err = EncodeRegistryResponse(RegistryRecord{Asn: "64500", Registry: registry[0]})
+	assertRoundTrip(t, "registry", encoded, err, encodeRegistryFixture)
+	if reparsed, err := parseRegistryResponse(encoded); err != nil || !reflect.DeepEqual(reparsed, registry) {
+		t.Errorf("registry round trip = %+v, %v, want %+v", reparsed, err, registry)
+	}
+
+	netblocks, err := parseNetblockResponse("64500", encodeNetblockFixture)
+	if err != nil {
+		t.Fatalf("parse netblock fixture: %v", err)
+	}
+	encoded, err = EncodeNetblockResponse(netblocks[0])
+	assertRoundTrip(t, "netblock", encoded, err, encodeNetblockFixture)
+	if reparsed, err := parseNetblockResponse("64500", encoded); err != nil || !reflect.DeepEqual(reparsed, netblocks) {
+		t.Errorf("netblock round trip = %+v, %v, want %+v", reparsed, err, netblocks)
+	}
+}
+
+func TestEncodeIPResponseKeepsUnparsedASPath(t *testing.T) {
+	const response = "IP: 192.0.2.1\nOrigin-AS: 64500\nPrefix: 192.0.2.0/24\nAS-Path: 64501 {64502 64500\n"
+	records, err := parseIpResponse(response)
+	if err != nil {
+		t.Fatalf("parse IP response: %v", err)
+	}
+	if len(records) != 1 || records[0].ASPath != nil {
+		t.Fatalf("parsed records = %+v, want one record without ASPath", records)
+	}
+	encoded, err := EncodeIPResponse(records)
+	assertRoundTrip(t, "IP with unparsed AS-Path", encoded, err, response)
+	if reparsed, err := parseIpResponse(encoded); err != nil || !reflect.DeepEqual(reparsed, records) {
+		t.Errorf("IP round trip = %+v, %v, want %+v", reparsed, err, records)
+	}
+}
+
+func assertRoundTrip(t *testing.T, kind, encoded string, err error, want string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("encode %s: %v", kind, err)
+	}
+	if encoded != want {
+		t.Errorf("encoded %s =\n%s\nwant\n%s", kind, encoded, want)
+	}
+}
+
+func TestEncodersUseTypedFields(t *testing.T) {
+	originated := time.Date(2026, time.May, 28, 6, 56, 1, 0, time.FixedZone("EST", -5*60*60))
+	record := WhoIs{
+		IPAddr:              netip.MustParseAddr("192.0.2.1"),
+		PrefixNet:           netip.MustParsePrefix("192.0.2.0/24"),
+		ASPath:              ASPath{{ASNs: []uint32{64501}}, {ASNs: []uint32{64500}}},
+		OriginAS:            "64500",
+		RouteOriginatedDate: originated,
+	}
+	encoded, err := EncodeIPResponse([]WhoIs{record})
+	if err != nil {
+		t.Fatalf("encode IP: %v", err)
+	}
+	want := "IP: 192.0.2.1\nOrigin-AS: 64500\nPrefix: 192.0.2.0/24\nAS-Path: 64501 64500\nRoute-Originated-Date: May 28 2026 11:56:01\n"
+	if encoded != want {
+		t.Errorf("encoded = %q, want %q", encoded, want)
+	}
+
+	route := BGPRoute{PrefixNet: netip.MustParsePrefix("2001:db8::/32"), NextHopAddr: netip.MustParseAddr("2001:db8::fe"), ASPath: []int{64500}}
+	encoded, err = EncodeRouteViewResponse(BGPRoutes{Routes: []BGPRoute{route}})
+	if err != nil || !strings.HasPrefix(encoded, "*> 2001:db8::/32 | Jan 01 0001 00:00:00 |") {
+		t.Fatalf("encoded route = %q, %v", encoded, err)
+	}
+	routes, err := parseBgpResponse(encoded)
+	if err != nil || !routes[0].CreateDate.IsZero() || routes[0].NextHopAddr != route.NextHopAddr {
+		t.Errorf("parsed route = %+v, %v", routes, err)
+	}
+
+	block := Netblock{RangeStart: netip.MustParseAddr("192.0.2.0"), RangeEnd: netip.MustParseAddr("192.0.2.255"), Name: "NET", Type: "assignment", Source: "TEST"}
+	encoded, err = EncodeNetblockResponse(NetblockRecord{OriginAs: "64500", Netblocks: []Netblock{block}})
+	if err != nil {
+		t.Fatalf("encode netblock: %v", err)
+	}
+	netblocks, err := parseNetblockResponse("64500", encoded)
+	if err != nil || netblocks[0].Netblocks[0].Range != "192.0.2.0-192.0.2.255" {
+		t.Errorf("parsed netblock = %+v, %v", netblocks, err)
+	}
+
+	encoded, err = EncodeRegistryResponse(RegistryRecord{})
+	if err != nil || encoded != "Can-Allocate: 0\n" {
+		t.Errorf("empty registry = %q, %v", encoded, err)
+	}
+}
+
+func TestEncodersRejectUnrepresentableValues(t *testing.T) {
+	block := Netblock{Range: "192.0.2.0-192.0.2.255", Name: "NET", Type: "assignment", Source: "TEST"}
+	route := BGPRoute{Prefix: "192.0.2.0/24", NextHop: "192.0.2.254", ASPath: []int{64500}}
+	tests := map[string]func() (string, error){
+		"IP without address": func() (string, error) { return EncodeIPResponse([]WhoIs{{OriginAS: "64500"}}) },
+		"IP line break": func() (string, error) {
+			return EncodeIPResponse([]WhoIs{{IP: "192.0.2.1", OrgName: "Example\nIP: 198.51.100.1"}})
+		},
+		"IP surrounding space": func() (string, error) { return EncodeIPResponse([]WhoIs{{IP: "192.0.2.1", City: " Wichita"}}) },
+		"IP bad address":       func() (string, error) { return EncodeIPResponse([]WhoIs{{IP: "192.0.2.300"}}) },
+		"route without next hop": func() (string, error) {
+			return EncodeRouteViewResponse(BGPRoutes{Routes: []BGPRoute{{Prefix: "192.0.2.0/24", ASPath: []int{64500}}}})
+		},
+		"route without AS path": func() (string, error) {
+			return EncodeRouteViewResponse(BGPRoutes{Routes: []BGPRoute{route, {Prefix: route.Prefix, NextHop: route.NextHop}}})
+		},
+		"registry line break": func() (string, error) {
+			return EncodeRegistryResponse(RegistryRecord{Registry: Registry{Comment: "one\ntwo"}})
+		},
+		"netblock without header": func() (string, error) { return EncodeNetblockResponse(NetblockRecord{Netblocks: []Netblock{block}}) },
+		"netblock without blocks": func() (string, error) { return EncodeNetblockResponse(NetblockRecord{OriginAs: "64500"}) },
+		"netblock name with space": func() (string, error) {
+			spaced := block
+			spaced.Name = "EXAMPLE NET"
+			return EncodeNetblockResponse(NetblockRecord{OriginAs: "64500", Netblocks: []Netblock{spaced}})
+		},
+		"netblock descending range": func() (string, error) {
+			descending := block
+			descending.Range = "192.0.2.255-192.0.2.0"
+			return EncodeNetblockResponse(NetblockRecord{OriginAs: "64500", Netblocks: []Netblock{descending}})
+		},
+	}
+	for name, encode := range tests {
+		t.Run(name, func(t *testing.T) {
+			if encoded, err := encode(); !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("encode = %q, %v, want ErrInvalidInput", encoded, err)
+			}
+		})
+	}
+}
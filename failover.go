@@ -0,0 +1,261 @@
+package pwhois
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultFailoverCooldown is the time an endpoint is skipped after a failure
+// when FailoverConfig.Cooldown is zero.
+const DefaultFailoverCooldown time.Duration = 30 * time.Second
+
+// FailoverStrategy chooses the order in which healthy endpoints are tried.
+type FailoverStrategy string
+
+const (
+	// FailoverOrdered tries endpoints in configuration order, so the first is
+	// the primary and the rest are standbys.
+	FailoverOrdered FailoverStrategy = "ordered"
+	// FailoverWeighted spreads lookups across endpoints in proportion to
+	// their weights, trying the others in weighted random order on failure.
+	FailoverWeighted FailoverStrategy = "weighted"
+)
+
+// FailoverEndpoint is one server in a FailoverConfig.
+type FailoverEndpoint struct {
+	// Server is prepared like ClientConfig.Server.
+	Server WhoisServer
+	// Weight is the endpoint's share of lookups under FailoverWeighted. Zero
+	// uses 1. It is ignored under FailoverOrdered.
+	Weight int
+}
+
+// FailoverConfig configures a FailoverClient.
+type FailoverConfig struct {
+	// Endpoints lists the servers to use. At least one is required.
+	Endpoints []FailoverEndpoint
+	// Strategy orders the endpoints. Empty uses FailoverOrdered.
+	Strategy FailoverStrategy
+	// Cooldown is how long an endpoint that failed over is skipped. Zero uses
+	// DefaultFailoverCooldown.
+	Cooldown time.Duration
+	// RateLimiter, when set, paces every endpoint as ClientConfig.RateLimiter
+	// does.
+	RateLimiter *RateLimiter
+	// CircuitBreaker, when set, guards every endpoint as
+	// ClientConfig.CircuitBreaker does. An endpoint whose circuit is open
+	// fails over at once without connecting.
+	CircuitBreaker *CircuitBreaker
+}
+
+// FailoverFunc performs a lookup with the Client for one endpoint.
+type FailoverFunc func(ctx context.Context, client *Client) error
+
+// FailoverClient spreads lookups across several PWHOIS endpoints, such as a
+// private mirror and the public server. A lookup that fails with
+// ErrConnection, ErrTimeout, ErrRateLimited, or ErrCircuitOpen moves on to
+// the next endpoint and puts the failed one in a cool-down. Endpoints cooling
+// down are tried only after every healthy endpoint has failed, soonest to
+// recover first, so a lookup is never refused without a query. Any other
+// error, or the end of the lookup context, is returned at once.
+//
+// When every endpoint fails, the last endpoint's error is returned, so
+// OperationError.Server names the endpoint that produced it. FailoverClient
+// is safe for concurrent use.
+type FailoverClient struct {
+	strategy FailoverStrategy
+	cooldown time.Duration
+
+	mu        sync.Mutex
+	endpoints []*failoverEndpoint
+	random    *rand.Rand
+}
+
+type failoverEndpoint struct {
+	client         *Client
+	weight         int
+	unhealthyUntil time.Time
+}
+
+// NewFailoverClient validates configuration and returns a FailoverClient.
+func NewFailoverClient(config FailoverConfig) (*FailoverClient, error) {
+	if len(config.Endpoints) == 0 {
+		return nil, invalidInputError("failover requires at least one endpoint")
+	}
+	if config.Cooldown < 0 {
+		return nil, invalidInputError("failover cool-down cannot be negative")
+	}
+	switch config.Strategy {
+	case "":
+		config.Strategy = FailoverOrdered
+	case FailoverOrdered, FailoverWeighted:
+	default:
+		return nil, invalidInputError("unknown failover strategy")
+	}
+	if config.Cooldown == 0 {
+		config.Cooldown = DefaultFailoverCooldown
+	}
+
+	failover := &FailoverClient{
+		strategy: config.Strategy,
+		cooldown: config.Cooldown,
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, configured := range config.Endpoints {
+		if configured.Weight < 0 {
+			return nil, invalidInputError("failover weight cannot be negative")
+		}
+		client, err := NewClient(ClientConfig{Server: configured.Server, RateLimiter: config.RateLimiter, CircuitBreaker: config.CircuitBreaker})
+		if err != nil {
+			return nil, err
+		}
+		failover.endpoints = append(failover.endpoints, &failoverEndpoint{client: client, weight: max(configured.Weight, 1)})
+	}
+	return failover, nil
+}
+
+// Do calls lookup with each endpoint's Client in turn until one succeeds or
+// returns an error that does not fail over, and returns the server of the
+// endpoint that answered or failed last. Use it to record the answering
+// endpoint, for example as CacheProvenance.Endpoint in a cache fetch.
+func (failover *FailoverClient) Do(ctx context.Context, lookup FailoverFunc) (WhoisServer, error) {
+	if ctx == nil {
+		return failover.endpoints[0].client.server, invalidInputError("lookup context is required")
+	}
+
+	var server WhoisServer
+	var err error
+	for _, endpoint := range failover.order(time.Now()) {
+		server = endpoint.client.server
+		err = lookup(ctx, endpoint.client)
+		if err == nil {
+			failover.markHealthy(endpoint)
+			return server, nil
+		}
+		if !failsOver(err) || callerExpired(ctx) {
+			return server, err
+		}
+		failover.markUnhealthy(endpoint, time.Now())
+	}
+	return server, err
+}
+
+// LookupIP looks up one or more IP addresses in a single query.
+func (failover *FailoverClient) LookupIP(ctx context.Context, ips ...string) ([]WhoIs, error) {
+	var records []WhoIs
+	_, err := failover.Do(ctx, func(ctx context.Context, client *Client) (err error) {
+		records, err = client.LookupIP(ctx, ips...)
+		return err
+	})
+	return records, err
+}
+
+// LookupIPBatch is Client.LookupIPBatch on the first endpoint that answers.
+func (failover *FailoverClient) LookupIPBatch(ctx context.Context, ips ...string) (IPBatchResult, error) {
+	var result IPBatchResult
+	_, err := failover.Do(ctx, func(ctx context.Context, client *Client) (err error) {
+		result, err = client.LookupIPBatch(ctx, ips...)
+		return err
+	})
+	return result, err
+}
+
+// LookupRouteView returns the routes originated by asn.
+func (failover *FailoverClient) LookupRouteView(ctx context.Context, asn string) (BGPRoutes, error) {
+	var routes BGPRoutes
+	_, err := failover.Do(ctx, func(ctx context.Context, client *Client) (err error) {
+		routes, err = client.LookupRouteView(ctx, asn)
+		return err
+	})
+	return routes, err
+}
+
+// LookupRegistry returns registry data for asn.
+func (failover *FailoverClient) LookupRegistry(ctx context.Context, asn string) (RegistryRecord, error) {
+	var record RegistryRecord
+	_, err := failover.Do(ctx, func(ctx context.Context, client *Client) (err error) {
+		record, err = client.LookupRegistry(ctx, asn)
+		return err
+	})
+	return record, err
+}
+
+// LookupNetblock returns the netblocks announced by asn.
+func (failover *FailoverClient) LookupNetblock(ctx context.Context, asn string) (NetblockRecord, error) {
+	var record NetblockRecord
+	_, err := failover.Do(ctx, func(ctx context.Context, client *Client) (err error) {
+		record, err = client.LookupNetblock(ctx, asn)
+		return err
+	})
+	return record, err
+}
+
+// order returns the endpoints to try: healthy ones by strategy, then those
+// cooling down, soonest to recover first.
+func (failover *FailoverClient) order(now time.Time) []*failoverEndpoint {
+	failover.mu.Lock()
+	defer failover.mu.Unlock()
+
+	var healthy, cooling []*failoverEndpoint
+	for _, endpoint := range failover.endpoints {
+		if now.Before(endpoint.unhealthyUntil) {
+			cooling = append(cooling, endpoint)
+		} else {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	if failover.strategy == FailoverWeighted {
+		healthy = failover.weightedOrderLocked(healthy)
+	}
+	sort.SliceStable(cooling, func(i, j int) bool {
+		return cooling[i].unhealthyUntil.Before(cooling[j].unhealthyUntil)
+	})
+	return append(healthy, cooling...)
+}
+
+// weightedOrderLocked draws endpoints without replacement, each with
+// probability proportional to its weight among those left.
+func (failover *FailoverClient) weightedOrderLocked(endpoints []*failoverEndpoint) []*failoverEndpoint {
+	remaining := append([]*failoverEndpoint(nil), endpoints...)
+	ordered := make([]*failoverEndpoint, 0, len(remaining))
+	for len(remaining) > 0 {
+		total := 0
+		for _, endpoint := range remaining {
+			total += endpoint.weight
+		}
+		pick := failover.random.Intn(total)
+		index := 0
+		for pick >= remaining[index].weight {
+			pick -= remaining[index].weight
+			index++
+		}
+		ordered = append(ordered, remaining[index])
+		remaining = append(remaining[:index], remaining[index+1:]...)
+	}
+	return ordered
+}
+
+func (failover *FailoverClient) markHealthy(endpoint *failoverEndpoint) {
+	failover.mu.Lock()
+	defer failover.mu.Unlock()
+	endpoint.unhealthyUntil = time.Time{}
+}
+
+func (failover *FailoverClient) markUnhealthy(endpoint *failoverEndpoint, now time.Time) {
+	failover.mu.Lock()
+	defer failover.mu.Unlock()
+	endpoint.unhealthyUntil = now.Add(failover.cooldown)
+}
+
+// failsOver reports whether err should move a lookup to the next endpoint.
+func failsOver(err error) bool {
+	switch ClassifyProviderError(err) {
+	case ProviderErrorConnection, ProviderErrorTimeout, ProviderErrorRateLimited, ProviderErrorCircuitOpen:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,183 @@
+package pwhois
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func rateLimitedRecords([]string) string {
+	return "Error: query limit exceeded\n"
+}
+
+func servedBatches(state *ipBatchServer) int {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return len(state.batches)
+}
+
+func TestFailoverClientMovesToStandby(t *testing.T) {
+	primary, primaryState := startIPBatchServer(t, rateLimitedRecords)
+	standby, standbyState := startIPBatchServer(t, ipRecords)
+	failover, err := NewFailoverClient(FailoverConfig{Endpoints: []FailoverEndpoint{{Server: primary}, {Server: standby}}})
+	if err != nil {
+		t.Fatalf("new failover client: %v", err)
+	}
+
+	var records []WhoIs
+	server, err := failover.Do(context.Background(), func(ctx context.Context, client *Client) (err error) {
+		records, err = client.LookupIP(ctx, "192.0.2.1")
+		return err
+	})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("lookup = %+v, %v", records, err)
+	}
+	if server.ServerAddressString() != standby.ServerAddressString() {
+		t.Errorf("answered by %s, want standby %s", server.ServerAddressString(), standby.ServerAddressString())
+	}
+	if provenance := server.CacheProvenance(); provenance.Endpoint != standby.ServerAddressString() || provenance.Provider != "pwhois" {
+		t.Errorf("provenance = %+v", provenance)
+	}
+
+	if _, err := failover.LookupIP(context.Background(), "192.0.2.2"); err != nil {
+		t.Fatalf("second lookup: %v", err)
+	}
+	if primary, standby := servedBatches(primaryState), servedBatches(standbyState); primary != 1 || standby != 2 {
+		t.Errorf("primary served %d, standby %d, want the cooling primary skipped", primary, standby)
+	}
+}
+
+func TestFailoverClientStopsOnOtherErrors(t *testing.T) {
+	primary, _ := startIPBatchServer(t, func([]string) string { return "" })
+	standby, standbyState := startIPBatchServer(t, ipRecords)
+	failover, err := NewFailoverClient(FailoverConfig{Endpoints: []FailoverEndpoint{{Server: primary}, {Server: standby}}})
+	if err != nil {
+		t.Fatalf("new failover client: %v", err)
+	}
+
+	_, err = failover.LookupIP(context.Background(), "192.0.2.1")
+	if !errors.Is(err, ErrNoRecords) {
+		t.Fatalf("error = %v, want ErrNoRecords", err)
+	}
+	assertOperationError(t, err, "lookup IP", primary.ServerAddressString())
+	if _, err := failover.LookupIP(context.Background(), "not an address"); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("invalid input error = %v, want ErrInvalidInput", err)
+	}
+	if served := servedBatches(standbyState); served != 0 {
+		t.Errorf("standby served %d queries, want 0", served)
+	}
+}
+
+func TestFailoverClientStopsAtShortCallerDeadlines(t *testing.T) {
+	// The listener completes connections but never answers, so each lookup
+	// runs until its caller's deadline.
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	defer listener.Close()
+	silent := WhoisServer{Server: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port, Timeout: time.Minute}
+	standby, standbyState := startIPBatchServer(t, ipRecords)
+	failover, err := NewFailoverClient(FailoverConfig{Endpoints: []FailoverEndpoint{{Server: silent}, {Server: standby}}})
+	if err != nil {
+		t.Fatalf("new failover client: %v", err)
+	}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		server, err := failover.Do(ctx, func(ctx context.Context, client *Client) error {
+			_, err := client.LookupRegistry(ctx, "64500")
+			return err
+		})
+		cancel()
+		if !errors.Is(err, ErrTimeout) || server.ServerAddressString() != silent.ServerAddressString() {
+			t.Fatalf("lookup %d = %s, %v, want ErrTimeout from the silent endpoint", attempt, server.ServerAddressString(), err)
+		}
+	}
+	failover.mu.Lock()
+	unhealthyUntil := failover.endpoints[0].unhealthyUntil
+	failover.mu.Unlock()
+	if !unhealthyUntil.IsZero() {
+		t.Errorf("silent endpoint cooling down until %s after short caller deadlines", unhealthyUntil)
+	}
+	if served := servedBatches(standbyState); served != 0 {
+		t.Errorf("standby served %d queries, want none", served)
+	}
+}
+
+func TestFailoverClientTriesCoolingEndpointsLast(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	closedPort := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+	down := WhoisServer{Server: "127.0.0.1", Port: closedPort, Timeout: time.Second}
+	limited, limitedState := startIPBatchServer(t, rateLimitedRecords)
+
+	failover, err := NewFailoverClient(FailoverConfig{Endpoints: []FailoverEndpoint{{Server: down}, {Server: limited}}})
+	if err != nil {
+		t.Fatalf("new failover client: %v", err)
+	}
+	for attempt := 1; attempt <= 2; attempt++ {
+		_, err := failover.LookupIP(context.Background(), "192.0.2.1")
+		if !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("attempt %d error = %v, want ErrRateLimited from the last endpoint", attempt, err)
+		}
+		assertOperationError(t, err, "lookup IP", limited.ServerAddressString())
+	}
+	if served := servedBatches(limitedState); served != 2 {
+		t.Errorf("cooling endpoint served %d queries, want 2", served)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := failover.LookupIP(ctx, "192.0.2.1"); !errors.Is(err, ErrCanceled) {
+		t.Errorf("canceled lookup error = %v, want ErrCanceled", err)
+	}
+}
+
+func TestFailoverClientWeightsEndpoints(t *testing.T) {
+	failover, err := NewFailoverClient(FailoverConfig{
+		Strategy:  FailoverWeighted,
+		Endpoints: []FailoverEndpoint{{Server: WhoisServer{Server: "192.0.2.1"}, Weight: 3}, {Server: WhoisServer{Server: "192.0.2.2"}}},
+	})
+	if err != nil {
+		t.Fatalf("new failover client: %v", err)
+	}
+
+	first := 0
+	for draw := 0; draw < 400; draw++ {
+		order := failover.order(time.Now())
+		if len(order) != 2 || order[0] == order[1] {
+			t.Fatalf("order = %v, want both endpoints once", order)
+		}
+		if order[0] == failover.endpoints[0] {
+			first++
+		}
+	}
+	if first < 240 || first > 360 {
+		t.Errorf("weight 3 endpoint first in %d of 400 draws, want about 300", first)
+	}
+
+	failover.markUnhealthy(failover.endpoints[0], time.Now())
+	if order := failover.order(time.Now()); order[0] != failover.endpoints[1] {
+		t.Errorf("cooling endpoint was not ordered last")
+	}
+}
+
+func TestFailoverClientValidation(t *testing.T) {
+	for name, config := range map[string]FailoverConfig{
+		"no endpoints":     {},
+		"unknown strategy": {Endpoints: []FailoverEndpoint{{}}, Strategy: "random"},
+		"negative weight":  {Endpoints: []FailoverEndpoint{{Weight: -1}}},
+		"negative cooling": {Endpoints: []FailoverEndpoint{{}}, Cooldown: -time.Second},
+		"invalid server":   {Endpoints: []FailoverEndpoint{{Server: WhoisServer{Port: 70000}}}},
+	} {
+		if _, err := NewFailoverClient(config); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: error = %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+// Package check parses and validates input for the packages built on pwhois,
+// reporting failures in the pwhois error classes so they read alike.
+package check
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/georgestarcher/pwhois"
+)
+
+// InvalidInput returns an error in the pwhois.ErrInvalidInput class.
+func InvalidInput(description string) error {
+	return fmt.Errorf("%w: %s", pwhois.ErrInvalidInput, description)
+}
+
+// ParseASN accepts a decimal ASN with an optional case-insensitive AS prefix.
+func ParseASN(value string) (uint32, error) {
+	asn := strings.TrimSpace(value)
+	if len(asn) >= 2 && strings.EqualFold(asn[:2], "AS") {
+		asn = asn[2:]
+	}
+	number, err := strconv.ParseUint(asn, 10, 32)
+	if err != nil {
+		return 0, InvalidInput(fmt.Sprintf("ASN %q is not a 32-bit decimal number", value))
+	}
+	return uint32(number), nil
+}
@@ -0,0 +1,32 @@
+package check
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/georgestarcher/pwhois"
+)
+
+func TestParseASN(t *testing.T) {
+	for value, want := range map[string]uint32{
+		"64500":         64500,
+		"AS64500":       64500,
+		" as4294967295": 4294967295,
+	} {
+		if got, err := ParseASN(value); err != nil || got != want {
+			t.Errorf("ParseASN(%q) = %d, %v; want %d", value, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "AS", "AS-64500", "4294967296", "64500.1"} {
+		if _, err := ParseASN(bad); !errors.Is(err, pwhois.ErrInvalidInput) {
+			t.Errorf("ParseASN(%q) error = %v, want ErrInvalidInput", bad, err)
+		}
+	}
+}
+
+func TestInvalidInput(t *testing.T) {
+	err := InvalidInput("table is empty")
+	if !errors.Is(err, pwhois.ErrInvalidInput) || err.Error() != pwhois.ErrInvalidInput.Error()+": table is empty" {
+		t.Errorf("InvalidInput = %v", err)
+	}
+}
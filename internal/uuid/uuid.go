@@ -0,0 +1,57 @@
+// Package uuid derives name-based UUIDs. It is shared by the stix and misp
+// exporters, whose identifiers must be stable across exports.
+package uuid
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+)
+
+// UUID is an RFC 4122 UUID.
+type UUID [16]byte
+
+// URLNamespace is the RFC 4122 namespace for names that are URLs.
+var URLNamespace = MustParse("6ba7b811-9dad-11d1-80b4-00c04fd430c8")
+
+// V5 returns the version 5 UUID of name in namespace.
+func V5(namespace UUID, name string) UUID {
+	hash := sha1.New()
+	hash.Write(namespace[:])
+	hash.Write([]byte(name))
+	var uuid UUID
+	copy(uuid[:], hash.Sum(nil))
+	uuid[6] = uuid[6]&0x0f | 0x50
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return uuid
+}
+
+// Parse parses the hyphenated hexadecimal form of a UUID.
+func Parse(text string) (UUID, bool) {
+	var uuid UUID
+	if len(text) != 36 || text[8] != '-' || text[13] != '-' || text[18] != '-' || text[23] != '-' {
+		return uuid, false
+	}
+	decoded, err := hex.DecodeString(strings.ReplaceAll(text, "-", ""))
+	if err != nil || len(decoded) != len(uuid) {
+		return uuid, false
+	}
+	copy(uuid[:], decoded)
+	return uuid, true
+}
+
+// MustParse is like Parse but panics if text is not a UUID. It is for
+// package-level namespace constants.
+func MustParse(text string) UUID {
+	uuid, ok := Parse(text)
+	if !ok {
+		panic("uuid: invalid UUID " + text)
+	}
+	return uuid
+}
+
+// String returns the lower-case hyphenated form of uuid.
+func (uuid UUID) String() string {
+	text := hex.EncodeToString(uuid[:])
+	return text[:8] + "-" + text[8:12] + "-" + text[12:16] + "-" + text[16:20] + "-" + text[20:]
+}
@@ -0,0 +1,35 @@
+package uuid
+
+import "testing"
+
+func TestV5MatchesRFC4122(t *testing.T) {
+	// Reference values from Python's uuid.uuid5.
+	tests := []struct {
+		namespace UUID
+		name      string
+		want      string
+	}{
+		{namespace: URLNamespace, name: "https://github.com/georgestarcher/pwhois/stix", want: "0d90ed48-627b-59d6-a94d-1f49e47c7a96"},
+		{namespace: MustParse("00abedb4-aa42-466c-9c01-fed23315a9b7"), name: `{"value":"192.0.2.1"}`, want: "8dded90c-40c0-545a-8027-5b212bb37e8e"},
+	}
+	for _, test := range tests {
+		if got := V5(test.namespace, test.name).String(); got != test.want {
+			t.Errorf("V5(%s, %q) = %s, want %s", test.namespace, test.name, got, test.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	const text = "00abedb4-aa42-466c-9c01-fed23315a9b7"
+	if uuid, ok := Parse(text); !ok || uuid.String() != text {
+		t.Errorf("Parse(%q) = %s, %v", text, uuid, ok)
+	}
+	if uuid, ok := Parse("00ABEDB4-AA42-466C-9C01-FED23315A9B7"); !ok || uuid.String() != text {
+		t.Errorf("Parse(upper case) = %s, %v", uuid, ok)
+	}
+	for _, bad := range []string{"", "00abedb4aa42466c9c01fed23315a9b7", "00abedb4-aa42-466c-9c01-fed23315a9bz", "00abedb4-aa42-466c9-c01-fed23315a9b7"} {
+		if _, ok := Parse(bad); ok {
+			t.Errorf("Parse(%q) succeeded", bad)
+		}
+	}
+}
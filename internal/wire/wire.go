@@ -0,0 +1,180 @@
+// Package wire reads PWHOIS requests on the server side of a connection. It
+// is shared by the pwhoistest fake server and the pwhoisd daemon.
+package wire
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+)
+
+// MaxLineBytes bounds one request line, including its newline. The longest
+// valid line, an app line with a long name, is far shorter.
+const MaxLineBytes = 4096
+
+// ErrRequestTooLarge reports a request with a line longer than MaxLineBytes
+// or more addresses than Limits.MaxBatch.
+var ErrRequestTooLarge = errors.New("request too large")
+
+// Kind identifies the kind of a request.
+type Kind string
+
+const (
+	KindIP        Kind = "ip"
+	KindRouteView Kind = "routeview"
+	KindRegistry  Kind = "registry"
+	KindNetblock  Kind = "netblock"
+	// KindUnknown is a request that could not be parsed.
+	KindUnknown Kind = "unknown"
+)
+
+// Request is one parsed request.
+type Request struct {
+	Kind Kind
+	// App is the app="..." value.
+	App string
+	// Addresses lists the addresses of an IP request as sent.
+	Addresses []string
+	// ASN is the source-as value of a routeview, registry, or netblock
+	// request.
+	ASN string
+	// Raw is the complete request text.
+	Raw string
+}
+
+// RespondFunc answers one request by writing to the connection.
+type RespondFunc func(connection net.Conn, request Request)
+
+// Limits bounds what Serve reads from one connection.
+type Limits struct {
+	// Idle is how long Serve waits for each line of a request.
+	Idle time.Duration
+	// MaxBatch is the most addresses a batched IP request may carry. Zero
+	// leaves batches unbounded.
+	MaxBatch int
+}
+
+// Serve reads the one request a connection carries and answers it. A native
+// PWHOIS response ends when the server closes the connection, so the caller
+// closes connection once Serve returns. A client that waits longer than
+// limits.Idle to send a line, or sends a request over the limits, is not
+// answered, which clients see as an empty response.
+func Serve(connection net.Conn, limits Limits, respond RespondFunc) {
+	lines := &lineReader{reader: bufio.NewReader(connection), connection: connection, idle: limits.Idle}
+	first, err := lines.next()
+	if err != nil {
+		return
+	}
+	request, err := readRequest(lines, first, limits.MaxBatch)
+	if err != nil {
+		return
+	}
+	respond(connection, request)
+}
+
+// ReadRequest parses a request whose first line has already been read,
+// reading the rest of an IP request from reader. It returns
+// ErrRequestTooLarge for a line longer than MaxLineBytes or a batch of more
+// than maxBatch addresses; a maxBatch of zero leaves batches unbounded. Any
+// other incomplete request is returned as KindUnknown.
+func ReadRequest(reader *bufio.Reader, first string, maxBatch int) (Request, error) {
+	return readRequest(&lineReader{reader: reader}, first, maxBatch)
+}
+
+func readRequest(lines *lineReader, first string, maxBatch int) (Request, error) {
+	request := Request{Kind: KindUnknown, Raw: first}
+	app, command, ok := parseAppLine(strings.TrimSuffix(first, "\n"))
+	if !ok {
+		return request, nil
+	}
+	request.App = app
+
+	if command == "" {
+		line, err := lines.next()
+		if errors.Is(err, ErrRequestTooLarge) {
+			return Request{}, err
+		}
+		request.Raw += line
+		if err != nil {
+			return request, nil
+		}
+		if line != pwhois.BatchStart {
+			request.Kind = KindIP
+			request.Addresses = []string{strings.TrimSuffix(line, "\n")}
+			return request, nil
+		}
+		for {
+			line, err := lines.next()
+			if errors.Is(err, ErrRequestTooLarge) {
+				return Request{}, err
+			}
+			request.Raw += line
+			if err != nil {
+				return request, nil
+			}
+			if line == pwhois.BatchEnd {
+				request.Kind = KindIP
+				return request, nil
+			}
+			if maxBatch > 0 && len(request.Addresses) == maxBatch {
+				return Request{}, ErrRequestTooLarge
+			}
+			request.Addresses = append(request.Addresses, strings.TrimSuffix(line, "\n"))
+		}
+	}
+
+	kind, asn, ok := strings.Cut(command, " source-as=")
+	if !ok {
+		return request, nil
+	}
+	switch Kind(kind) {
+	case KindRouteView, KindRegistry, KindNetblock:
+		request.Kind = Kind(kind)
+		request.ASN = asn
+	}
+	return request, nil
+}
+
+// lineReader reads request lines of at most MaxLineBytes. When connection is
+// set, each line must arrive within idle.
+type lineReader struct {
+	reader     *bufio.Reader
+	connection net.Conn
+	idle       time.Duration
+}
+
+// next reads one line, including its newline, failing with
+// ErrRequestTooLarge once it passes MaxLineBytes.
+func (lines *lineReader) next() (string, error) {
+	if lines.connection != nil {
+		_ = lines.connection.SetReadDeadline(time.Now().Add(lines.idle))
+	}
+	var line []byte
+	for {
+		chunk, err := lines.reader.ReadSlice('\n')
+		if len(line)+len(chunk) > MaxLineBytes {
+			return "", ErrRequestTooLarge
+		}
+		line = append(line, chunk...)
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return string(line), err
+		}
+	}
+}
+
+// parseAppLine splits `app="name" command` into its name and command.
+func parseAppLine(line string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(line, `app="`)
+	if !ok {
+		return "", "", false
+	}
+	app, command, ok := strings.Cut(rest, `"`)
+	if !ok {
+		return "", "", false
+	}
+	return app, strings.TrimSpace(command), true
+}
@@ -0,0 +1,117 @@
+package wire
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		want    Request
+	}{
+		{
+			name:    "single IP",
+			request: "app=\"GO pwhois Module\"\n192.0.2.1\n",
+			want:    Request{Kind: KindIP, App: "GO pwhois Module", Addresses: []string{"192.0.2.1"}},
+		},
+		{
+			name:    "batch",
+			request: "app=\"GO pwhois Module\"\nbegin\n192.0.2.1\n2001:db8::1\nend\n",
+			want:    Request{Kind: KindIP, App: "GO pwhois Module", Addresses: []string{"192.0.2.1", "2001:db8::1"}},
+		},
+		{
+			name:    "routeview",
+			request: "app=\"tool\" routeview source-as=64500\n",
+			want:    Request{Kind: KindRouteView, App: "tool", ASN: "64500"},
+		},
+		{
+			name:    "netblock",
+			request: "app=\"tool\" netblock source-as=64500\n",
+			want:    Request{Kind: KindNetblock, App: "tool", ASN: "64500"},
+		},
+		{name: "no app", request: "hello\n", want: Request{Kind: KindUnknown}},
+		{name: "unknown command", request: "app=\"x\" whois source-as=1\n", want: Request{Kind: KindUnknown, App: "x"}},
+		{name: "missing ASN", request: "app=\"x\" registry\n", want: Request{Kind: KindUnknown, App: "x"}},
+		{name: "unterminated batch", request: "app=\"x\"\nbegin\n192.0.2.1\n", want: Request{Kind: KindUnknown, App: "x", Addresses: []string{"192.0.2.1"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(test.request))
+			first, _ := reader.ReadString('\n')
+			got, err := ReadRequest(reader, first, 2)
+			if err != nil {
+				t.Fatalf("read request: %v", err)
+			}
+			if got.Raw != test.request {
+				t.Errorf("raw = %q, want %q", got.Raw, test.request)
+			}
+			got.Raw = ""
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("request = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestServeRejectsOversizedRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+	}{
+		{name: "overlong first line", request: "app=\"" + strings.Repeat("x", MaxLineBytes) + "\"\n"},
+		{name: "overlong address line", request: "app=\"x\"\nbegin\n" + strings.Repeat("1", MaxLineBytes) + "\nend\n"},
+		{name: "over-limit batch", request: "app=\"x\"\nbegin\n192.0.2.1\n192.0.2.2\n192.0.2.3\nend\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			go func() {
+				_, _ = io.WriteString(client, test.request)
+			}()
+			answered := false
+			Serve(server, Limits{Idle: time.Second, MaxBatch: 2}, func(net.Conn, Request) { answered = true })
+			_ = server.Close()
+			_ = client.Close()
+			if answered {
+				t.Error("oversized request was answered")
+			}
+
+			reader := bufio.NewReader(strings.NewReader(test.request))
+			first, _ := reader.ReadString('\n')
+			if len(first) > MaxLineBytes {
+				return
+			}
+			if _, err := ReadRequest(reader, first, 2); !errors.Is(err, ErrRequestTooLarge) {
+				t.Errorf("read request error = %v, want ErrRequestTooLarge", err)
+			}
+		})
+	}
+}
+
+func TestServeWaitsIdleForEachLine(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() {
+		for _, line := range []string{"app=\"x\"\n", "begin\n", "192.0.2.1\n", "end\n"} {
+			time.Sleep(30 * time.Millisecond)
+			_, _ = io.WriteString(client, line)
+		}
+	}()
+
+	var got Request
+	Serve(server, Limits{Idle: 50 * time.Millisecond}, func(_ net.Conn, request Request) { got = request })
+	_ = server.Close()
+	if got.Kind != KindIP || len(got.Addresses) != 1 {
+		t.Errorf("request = %+v, want a one-address IP batch", got)
+	}
+}
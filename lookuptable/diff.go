@@ -0,0 +1,109 @@
+package lookuptable
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/georgestarcher/pwhois/internal/check"
+)
+
+// Change is a prefix present in both tables with different values.
+type Change struct {
+	Old Row
+	New Row
+}
+
+// Fields returns the names of the columns that differ, in column order.
+func (change Change) Fields() []string {
+	var fields []string
+	old, current := rowValues(change.Old), rowValues(change.New)
+	for index := range old {
+		if old[index] != current[index] {
+			fields = append(fields, Columns[index+1])
+		}
+	}
+	return fields
+}
+
+// Diff is how a table differs from the one before it. Each list is ordered by
+// prefix.
+type Diff struct {
+	Added     []Row
+	Removed   []Row
+	Changed   []Change
+	Unchanged int
+}
+
+// Compare returns the rows added, removed, and changed from previous to next.
+// Both tables must be ordered by prefix, as Generate and ReadCSV return them.
+func Compare(previous, next Table) Diff {
+	var diff Diff
+	old, current := previous.Rows, next.Rows
+	for len(old) > 0 || len(current) > 0 {
+		switch {
+		case len(current) == 0 || len(old) > 0 && comparePrefixes(old[0].Prefix, current[0].Prefix) < 0:
+			diff.Removed = append(diff.Removed, old[0])
+			old = old[1:]
+		case len(old) == 0 || comparePrefixes(current[0].Prefix, old[0].Prefix) < 0:
+			diff.Added = append(diff.Added, current[0])
+			current = current[1:]
+		default:
+			if old[0] == current[0] {
+				diff.Unchanged++
+			} else {
+				diff.Changed = append(diff.Changed, Change{Old: old[0], New: current[0]})
+			}
+			old, current = old[1:], current[1:]
+		}
+	}
+	return diff
+}
+
+// Empty reports whether the tables have the same rows.
+func (diff Diff) Empty() bool {
+	return len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0
+}
+
+// WriteSummary writes a review summary: a count line, then one line per
+// added (+), removed (-), and changed (~) prefix, in that order. A changed
+// prefix lists only the columns that differ.
+func (diff Diff) WriteSummary(w io.Writer) error {
+	if w == nil {
+		return check.InvalidInput("lookup table summary writer is required")
+	}
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "%d added, %d removed, %d changed, %d unchanged\n", len(diff.Added), len(diff.Removed), len(diff.Changed), diff.Unchanged)
+	for _, row := range diff.Added {
+		fmt.Fprintf(&summary, "+ %s %s\n", row.Prefix, describeRow(row))
+	}
+	for _, row := range diff.Removed {
+		fmt.Fprintf(&summary, "- %s %s\n", row.Prefix, describeRow(row))
+	}
+	for _, change := range diff.Changed {
+		old, current := rowValues(change.Old), rowValues(change.New)
+		var fields []string
+		for index := range old {
+			if old[index] != current[index] {
+				fields = append(fields, fmt.Sprintf("%s %q -> %q", Columns[index+1], old[index], current[index]))
+			}
+		}
+		fmt.Fprintf(&summary, "~ %s %s\n", change.New.Prefix, strings.Join(fields, ", "))
+	}
+	_, err := io.WriteString(w, summary.String())
+	return err
+}
+
+// rowValues returns the values of a row after its prefix, in column order.
+func rowValues(row Row) []string {
+	return []string{row.ASN, row.OrgName, row.NetName, row.Source}
+}
+
+// describeRow renders the values of a row after its prefix.
+func describeRow(row Row) string {
+	var fields []string
+	for index, value := range rowValues(row) {
+		fields = append(fields, fmt.Sprintf("%s=%q", Columns[index+1], value))
+	}
+	return strings.Join(fields, " ")
+}
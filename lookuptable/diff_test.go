@@ -0,0 +1,45 @@
+package lookuptable
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompareSummarizesAddedRemovedAndChangedPrefixes(t *testing.T) {
+	previous := Table{Rows: []Row{
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24"), ASN: "64500", OrgName: "Example Networks", NetName: "EXAMPLE-NET", Source: "netblock"},
+		{Prefix: netip.MustParsePrefix("192.0.2.0/25"), ASN: "64500", OrgName: "Example Networks", Source: "routeview"},
+		{Prefix: netip.MustParsePrefix("198.51.100.0/24"), ASN: "64500", OrgName: "Example Networks", NetName: "LAB-NET", Source: "netblock"},
+	}}
+	next := Table{Rows: []Row{
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24"), ASN: "64500 64501", OrgName: "Example Networks", NetName: "EXAMPLE-NET", Source: "netblock routeview"},
+		{Prefix: netip.MustParsePrefix("198.51.100.0/24"), ASN: "64500", OrgName: "Example Networks", NetName: "LAB-NET", Source: "netblock"},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32"), ASN: "64501", Source: "routeview"},
+	}}
+
+	diff := Compare(previous, next)
+	if diff.Empty() || diff.Unchanged != 1 || len(diff.Added) != 1 || len(diff.Removed) != 1 || len(diff.Changed) != 1 {
+		t.Fatalf("Compare = %+v", diff)
+	}
+	if fields := diff.Changed[0].Fields(); !reflect.DeepEqual(fields, []string{"asn", "source"}) {
+		t.Errorf("changed fields = %v, want [asn source]", fields)
+	}
+
+	var summary strings.Builder
+	if err := diff.WriteSummary(&summary); err != nil {
+		t.Fatalf("WriteSummary: %v", err)
+	}
+	want := "1 added, 1 removed, 1 changed, 1 unchanged\n" +
+		"+ 2001:db8::/32 asn=\"64501\" org_name=\"\" net_name=\"\" source=\"routeview\"\n" +
+		"- 192.0.2.0/25 asn=\"64500\" org_name=\"Example Networks\" net_name=\"\" source=\"routeview\"\n" +
+		"~ 192.0.2.0/24 asn \"64500\" -> \"64500 64501\", source \"netblock\" -> \"netblock routeview\"\n"
+	if summary.String() != want {
+		t.Errorf("summary = %q\nwant %q", summary.String(), want)
+	}
+
+	if same := Compare(next, next); !same.Empty() || same.Unchanged != len(next.Rows) {
+		t.Errorf("Compare with itself = %+v", same)
+	}
+}
@@ -0,0 +1,302 @@
+// Package lookuptable builds CIDR lookup tables that map prefixes to the ASN,
+// organization, and network name holding them, for SIEM lookups keyed by
+// address.
+//
+// Generate runs netblock and routeview lookups for a list of ASNs, converts
+// each netblock's address range into its minimal set of CIDR prefixes, and
+// merges those with the announced routes into one row per prefix. A Table is
+// written and read as CSV with the header in Columns, and Compare summarizes
+// how a new table differs from the one deployed before it so the change can
+// be reviewed first.
+//
+// Failures use the pwhois error classes: a table file that cannot be read is
+// pwhois.ErrInvalidInput, and lookup failures keep the class the client
+// reported. An ASN with no netblocks or routes is not an error; it is listed
+// in Table.Missing.
+package lookuptable
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/internal/check"
+)
+
+// Sources of a row, as written in its source column.
+const (
+	SourceNetblock  = "netblock"
+	SourceRouteView = "routeview"
+)
+
+// Columns is the CSV header of a table.
+var Columns = []string{"prefix", "asn", "org_name", "net_name", "source"}
+
+// Source is the lookup client a table is generated from. *pwhois.Client and
+// *pwhois.FailoverClient implement it.
+type Source interface {
+	LookupNetblock(ctx context.Context, asn string) (pwhois.NetblockRecord, error)
+	LookupRouteView(ctx context.Context, asn string) (pwhois.BGPRoutes, error)
+}
+
+// Row maps one prefix to the network holding it.
+type Row struct {
+	Prefix netip.Prefix
+	// ASN is the ASN holding the prefix. When several of the generated ASNs
+	// claim the prefix, it lists them all, in ascending order, separated by
+	// spaces.
+	ASN string
+	// OrgName is the organization name of the lowest ASN that has one.
+	OrgName string
+	// NetName is the name of the registered netblock the prefix was taken
+	// from, or empty for a prefix seen only in routes.
+	NetName string
+	// Source lists the lookups the prefix was found in, SourceNetblock and
+	// SourceRouteView, separated by spaces.
+	Source string
+}
+
+// Table is a lookup table with one row per prefix, ordered by prefix.
+type Table struct {
+	Rows []Row
+	// Missing lists the ASNs Generate found neither netblocks nor routes
+	// for. It is not written to CSV.
+	Missing []string
+}
+
+// candidate is what one lookup contributed to a prefix.
+type candidate struct {
+	asn     uint32
+	orgName string
+	netName string
+	source  string
+}
+
+// Generate looks up the netblocks and routes of each ASN and merges them into
+// a table. Netblock ranges become their minimal CIDR cover and routes keep
+// their announced prefix, so a route and a netblock meet in one row only when
+// they name the same prefix. Lookups run one at a time, in order; the first
+// failure other than pwhois.ErrNoRecords stops generation, since a partial
+// table would read as removed prefixes.
+func Generate(ctx context.Context, source Source, asns []string) (Table, error) {
+	if ctx == nil {
+		return Table{}, check.InvalidInput("lookup table context is required")
+	}
+	if source == nil {
+		return Table{}, check.InvalidInput("lookup table source is required")
+	}
+	if len(asns) == 0 {
+		return Table{}, check.InvalidInput("lookup table needs at least one ASN")
+	}
+
+	var (
+		candidates = make(map[netip.Prefix][]candidate)
+		table      Table
+		missing    = make(map[string]bool)
+	)
+	for _, asn := range asns {
+		netblocks, err := source.LookupNetblock(ctx, asn)
+		if err != nil && !errors.Is(err, pwhois.ErrNoRecords) {
+			return Table{}, fmt.Errorf("generate lookup table for ASN %s: %w", asn, err)
+		}
+		routes, routeErr := source.LookupRouteView(ctx, asn)
+		if routeErr != nil && !errors.Is(routeErr, pwhois.ErrNoRecords) {
+			return Table{}, fmt.Errorf("generate lookup table for ASN %s: %w", asn, routeErr)
+		}
+		if err != nil && routeErr != nil {
+			if !missing[asn] {
+				missing[asn] = true
+				table.Missing = append(table.Missing, asn)
+			}
+			continue
+		}
+
+		if err == nil {
+			number, err := responseASN(netblocks.Asn)
+			if err != nil {
+				return Table{}, fmt.Errorf("generate lookup table for ASN %s: %w", asn, err)
+			}
+			for _, block := range netblocks.Netblocks {
+				prefixes := block.Prefixes()
+				if len(prefixes) == 0 {
+					return Table{}, fmt.Errorf("generate lookup table for ASN %s: %w: netblock %s range %q has no CIDR cover", asn, pwhois.ErrMalformedResponse, block.Name, block.Range)
+				}
+				for _, prefix := range prefixes {
+					candidates[prefix] = append(candidates[prefix], candidate{asn: number, orgName: netblocks.OrgName, netName: block.Name, source: SourceNetblock})
+				}
+			}
+		}
+		if routeErr == nil {
+			number, err := responseASN(routes.Asn)
+			if err != nil {
+				return Table{}, fmt.Errorf("generate lookup table for ASN %s: %w", asn, err)
+			}
+			for _, route := range routes.Routes {
+				prefix, err := routePrefix(route)
+				if err != nil {
+					return Table{}, fmt.Errorf("generate lookup table for ASN %s: %w", asn, err)
+				}
+				candidates[prefix] = append(candidates[prefix], candidate{asn: number, orgName: netblocks.OrgName, source: SourceRouteView})
+			}
+		}
+	}
+
+	for prefix, claims := range candidates {
+		table.Rows = append(table.Rows, mergeCandidates(prefix, claims))
+	}
+	sortRows(table.Rows)
+	return table, nil
+}
+
+// mergeCandidates builds the row for prefix from every lookup that claimed it.
+func mergeCandidates(prefix netip.Prefix, claims []candidate) Row {
+	sort.SliceStable(claims, func(i, j int) bool {
+		if claims[i].asn != claims[j].asn {
+			return claims[i].asn < claims[j].asn
+		}
+		return claims[i].netName < claims[j].netName
+	})
+
+	row := Row{Prefix: prefix}
+	var asns []string
+	sources := make(map[string]bool)
+	for _, claim := range claims {
+		asn := strconv.FormatUint(uint64(claim.asn), 10)
+		if len(asns) == 0 || asns[len(asns)-1] != asn {
+			asns = append(asns, asn)
+		}
+		if row.OrgName == "" {
+			row.OrgName = claim.orgName
+		}
+		if row.NetName == "" {
+			row.NetName = claim.netName
+		}
+		sources[claim.source] = true
+	}
+	row.ASN = strings.Join(asns, " ")
+
+	var names []string
+	for _, name := range []string{SourceNetblock, SourceRouteView} {
+		if sources[name] {
+			names = append(names, name)
+		}
+	}
+	row.Source = strings.Join(names, " ")
+	return row
+}
+
+// routePrefix returns the masked prefix of a route.
+func routePrefix(route pwhois.BGPRoute) (netip.Prefix, error) {
+	prefix := route.PrefixNet
+	if !prefix.IsValid() {
+		var err error
+		if prefix, err = netip.ParsePrefix(route.Prefix); err != nil {
+			return netip.Prefix{}, fmt.Errorf("%w: route prefix %q is not a CIDR prefix", pwhois.ErrMalformedResponse, route.Prefix)
+		}
+	}
+	return prefix.Masked(), nil
+}
+
+// responseASN parses the ASN of a lookup result. One that does not parse is
+// a fault in the response, not in the caller's input.
+func responseASN(asn string) (uint32, error) {
+	number, err := check.ParseASN(asn)
+	if err != nil {
+		return 0, fmt.Errorf("%w: ASN %q is not a decimal number", pwhois.ErrMalformedResponse, asn)
+	}
+	return number, nil
+}
+
+// sortRows orders rows by prefix address, then by prefix length.
+func sortRows(rows []Row) {
+	sort.Slice(rows, func(i, j int) bool {
+		return comparePrefixes(rows[i].Prefix, rows[j].Prefix) < 0
+	})
+}
+
+func comparePrefixes(a, b netip.Prefix) int {
+	if order := a.Addr().Compare(b.Addr()); order != 0 {
+		return order
+	}
+	return a.Bits() - b.Bits()
+}
+
+// Lookup returns the row for prefix and whether the table has one.
+func (table Table) Lookup(prefix netip.Prefix) (Row, bool) {
+	index := sort.Search(len(table.Rows), func(index int) bool {
+		return comparePrefixes(table.Rows[index].Prefix, prefix) >= 0
+	})
+	if index < len(table.Rows) && table.Rows[index].Prefix == prefix {
+		return table.Rows[index], true
+	}
+	return Row{}, false
+}
+
+// WriteCSV writes the header in Columns and one record per row.
+func (table Table) WriteCSV(w io.Writer) error {
+	if w == nil {
+		return check.InvalidInput("lookup table writer is required")
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.Write(Columns); err != nil {
+		return err
+	}
+	for _, row := range table.Rows {
+		if err := writer.Write(append([]string{row.Prefix.String()}, rowValues(row)...)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadCSV reads a table written by WriteCSV. The header must match Columns,
+// every prefix must be a masked CIDR prefix, and no prefix may repeat.
+func ReadCSV(r io.Reader) (Table, error) {
+	if r == nil {
+		return Table{}, check.InvalidInput("lookup table reader is required")
+	}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(Columns)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return Table{}, check.InvalidInput("lookup table is empty")
+	}
+	if err != nil {
+		return Table{}, check.InvalidInput(err.Error())
+	}
+	if strings.Join(header, ",") != strings.Join(Columns, ",") {
+		return Table{}, check.InvalidInput(fmt.Sprintf("lookup table header is %q, want %q", strings.Join(header, ","), strings.Join(Columns, ",")))
+	}
+
+	var table Table
+	seen := make(map[netip.Prefix]bool)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Table{}, check.InvalidInput(err.Error())
+		}
+		line, _ := reader.FieldPos(0)
+		prefix, err := netip.ParsePrefix(record[0])
+		if err != nil || prefix != prefix.Masked() {
+			return Table{}, check.InvalidInput(fmt.Sprintf("lookup table line %d: %q is not a masked CIDR prefix", line, record[0]))
+		}
+		if seen[prefix] {
+			return Table{}, check.InvalidInput(fmt.Sprintf("lookup table line %d: prefix %s repeats", line, prefix))
+		}
+		seen[prefix] = true
+		table.Rows = append(table.Rows, Row{Prefix: prefix, ASN: record[1], OrgName: record[2], NetName: record[3], Source: record[4]})
+	}
+	sortRows(table.Rows)
+	return table, nil
+}
@@ -0,0 +1,118 @@
+package lookuptable
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/pwhoistest"
+)
+
+var testFixtures = pwhoistest.Fixtures{
+	RouteView: map[string]string{
+		"64500": "*> 192.0.2.0/24 | Jul 18 2026 00:00:04 | Jul 18 2026 00:00:04 | May 28 2026 06:56:01 | 192.0.2.254 | 64502 64500\n" +
+			"*> 203.0.113.0/24 | Jul 18 2026 00:00:04 | Jul 18 2026 00:00:04 | May 28 2026 06:56:01 | 192.0.2.254 | 64502 64500",
+		"64501": "*> 192.0.2.0/24 | Jul 18 2026 00:00:04 | Jul 18 2026 00:00:04 | May 28 2026 06:56:01 | 192.0.2.254 | 64502 64501",
+	},
+	Netblock: map[string]string{
+		"64500": "Origin-AS: 64500\nOrg-Name: Example Networks\n" +
+			"*> 192.0.2.0 - 192.0.2.255 | EXAMPLE-NET | reassignment | 2019-05-25 | 2019-09-25 | Jun 28 2019 16:53:01 | Jul 18 2026 03:19:32 | TEST\n" +
+			"*> 198.51.100.0 - 198.51.100.191 | LAB-NET | reassignment | 2019-05-25 | 2019-09-25 | Jun 28 2019 16:53:01 | Jul 18 2026 03:19:32 | TEST",
+	},
+}
+
+func newTestClient(t *testing.T, server *pwhoistest.Server) *pwhois.Client {
+	t.Helper()
+	client, err := pwhois.NewClient(pwhois.ClientConfig{Server: server.WhoisServer()})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+func TestGenerateMergesNetblocksAndRoutesByPrefix(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+
+	table, err := Generate(context.Background(), newTestClient(t, server), []string{"AS64500", "64501", "64999", "64500"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	want := []Row{
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24"), ASN: "64500 64501", OrgName: "Example Networks", NetName: "EXAMPLE-NET", Source: "netblock routeview"},
+		{Prefix: netip.MustParsePrefix("198.51.100.0/25"), ASN: "64500", OrgName: "Example Networks", NetName: "LAB-NET", Source: "netblock"},
+		{Prefix: netip.MustParsePrefix("198.51.100.128/26"), ASN: "64500", OrgName: "Example Networks", NetName: "LAB-NET", Source: "netblock"},
+		{Prefix: netip.MustParsePrefix("203.0.113.0/24"), ASN: "64500", OrgName: "Example Networks", Source: "routeview"},
+	}
+	if !reflect.DeepEqual(table.Rows, want) {
+		t.Errorf("rows = %+v\nwant %+v", table.Rows, want)
+	}
+	if !reflect.DeepEqual(table.Missing, []string{"64999"}) {
+		t.Errorf("missing = %v, want [64999]", table.Missing)
+	}
+
+	if row, ok := table.Lookup(netip.MustParsePrefix("198.51.100.128/26")); !ok || row.NetName != "LAB-NET" {
+		t.Errorf("Lookup = %+v, %v", row, ok)
+	}
+	if _, ok := table.Lookup(netip.MustParsePrefix("198.51.100.0/24")); ok {
+		t.Errorf("Lookup found a prefix the table does not have")
+	}
+}
+
+func TestGenerateStopsAtLookupFailures(t *testing.T) {
+	server := pwhoistest.NewServer(testFixtures)
+	defer server.Close()
+	server.SetFaults(func(query pwhoistest.Query) pwhoistest.Fault {
+		return pwhoistest.Fault{RateLimit: query.Kind == pwhoistest.QueryRouteView}
+	})
+
+	client := newTestClient(t, server)
+	if _, err := Generate(context.Background(), client, []string{"64500"}); !errors.Is(err, pwhois.ErrRateLimited) {
+		t.Errorf("Generate error = %v, want ErrRateLimited", err)
+	}
+	if _, err := Generate(context.Background(), client, []string{"AS-x"}); !errors.Is(err, pwhois.ErrInvalidInput) {
+		t.Errorf("Generate bad ASN error = %v, want ErrInvalidInput", err)
+	}
+	if _, err := Generate(context.Background(), client, nil); !errors.Is(err, pwhois.ErrInvalidInput) {
+		t.Errorf("Generate without ASNs error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestTableCSVRoundTrip(t *testing.T) {
+	table := Table{Rows: []Row{
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24"), ASN: "64500", OrgName: "Example, Inc.", NetName: "EXAMPLE-NET", Source: SourceNetblock},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32"), ASN: "64501", Source: SourceRouteView},
+	}}
+	var buffer bytes.Buffer
+	if err := table.WriteCSV(&buffer); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	want := "prefix,asn,org_name,net_name,source\n" +
+		"192.0.2.0/24,64500,\"Example, Inc.\",EXAMPLE-NET,netblock\n" +
+		"2001:db8::/32,64501,,,routeview\n"
+	if buffer.String() != want {
+		t.Errorf("CSV = %q, want %q", buffer.String(), want)
+	}
+
+	read, err := ReadCSV(&buffer)
+	if err != nil || !reflect.DeepEqual(read.Rows, table.Rows) {
+		t.Errorf("ReadCSV = %+v, %v", read.Rows, err)
+	}
+
+	for name, input := range map[string]string{
+		"empty":     "",
+		"header":    "cidr,asn,org_name,net_name,source\n",
+		"fields":    "prefix,asn,org_name,net_name,source\n192.0.2.0/24,64500\n",
+		"unmasked":  "prefix,asn,org_name,net_name,source\n192.0.2.1/24,64500,,,netblock\n",
+		"duplicate": "prefix,asn,org_name,net_name,source\n192.0.2.0/24,64500,,,netblock\n192.0.2.0/24,64501,,,routeview\n",
+	} {
+		if _, err := ReadCSV(strings.NewReader(input)); !errors.Is(err, pwhois.ErrInvalidInput) {
+			t.Errorf("ReadCSV %s error = %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
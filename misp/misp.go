@@ -0,0 +1,322 @@
+// Package misp exports pwhois IP lookup results as a MISP event.
+//
+// NewEvent turns WhoIs records into a Document in the MISP event JSON format
+// that MISP imports and its API accepts. Each IP becomes an ip-dst or ip-src
+// attribute, and each origin ASN becomes an object of the native asn object
+// template carrying the ASN, its announced prefixes, its organization name,
+// and its country, with a reference to each IP it originates. Every UUID is
+// derived from the event's content, so the export is deterministic and needs
+// no MISP instance to test.
+package misp
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/internal/check"
+	"github.com/georgestarcher/pwhois/internal/uuid"
+)
+
+// IP attribute types.
+const (
+	AttributeIPDst = "ip-dst"
+	AttributeIPSrc = "ip-src"
+)
+
+// ASNTemplateUUID is the UUID of the misp-objects asn template.
+const ASNTemplateUUID = "4ec55cc6-9e49-4c64-b794-03c25c1a6587"
+
+// RelationshipIncludes relates an asn object to the IP attributes it
+// originates.
+const RelationshipIncludes = "includes"
+
+// networkActivity is the MISP category of every exported attribute.
+const networkActivity = "Network activity"
+
+// Event distribution levels.
+const (
+	DistributionOrganization = 0
+	DistributionCommunity    = 1
+	DistributionConnected    = 2
+	DistributionAll          = 3
+)
+
+// inheritDistribution makes attributes and objects follow their event.
+const inheritDistribution = "5"
+
+// eventNamespace names event UUIDs that are not configured.
+var eventNamespace = uuid.V5(uuid.URLNamespace, "https://github.com/georgestarcher/pwhois/misp")
+
+// Document is the JSON document MISP imports: an event under the "Event"
+// key.
+type Document struct {
+	Event Event `json:"Event"`
+}
+
+// Event is a MISP event. Numeric fields are strings, as MISP writes them.
+type Event struct {
+	UUID          string      `json:"uuid"`
+	Info          string      `json:"info"`
+	Date          string      `json:"date"`
+	Timestamp     string      `json:"timestamp"`
+	Published     bool        `json:"published"`
+	Analysis      string      `json:"analysis"`
+	ThreatLevelID string      `json:"threat_level_id"`
+	Distribution  string      `json:"distribution"`
+	Attributes    []Attribute `json:"Attribute,omitempty"`
+	Objects       []Object    `json:"Object,omitempty"`
+}
+
+// Attribute is a MISP attribute of an event or, with an ObjectRelation, of
+// an object.
+type Attribute struct {
+	UUID           string `json:"uuid"`
+	Type           string `json:"type"`
+	Category       string `json:"category"`
+	Value          string `json:"value"`
+	ObjectRelation string `json:"object_relation,omitempty"`
+	ToIDS          bool   `json:"to_ids"`
+	Distribution   string `json:"distribution"`
+	Timestamp      string `json:"timestamp"`
+	Comment        string `json:"comment,omitempty"`
+}
+
+// Object is a MISP object built from an object template.
+type Object struct {
+	UUID         string      `json:"uuid"`
+	Name         string      `json:"name"`
+	MetaCategory string      `json:"meta-category"`
+	TemplateUUID string      `json:"template_uuid"`
+	Distribution string      `json:"distribution"`
+	Timestamp    string      `json:"timestamp"`
+	Comment      string      `json:"comment,omitempty"`
+	Attributes   []Attribute `json:"Attribute"`
+	References   []Reference `json:"ObjectReference,omitempty"`
+}
+
+// Reference is a MISP object reference to another attribute or object.
+type Reference struct {
+	UUID             string `json:"uuid"`
+	ReferencedUUID   string `json:"referenced_uuid"`
+	RelationshipType string `json:"relationship_type"`
+	Timestamp        string `json:"timestamp"`
+}
+
+// Config configures an exported event.
+type Config struct {
+	// Info is the event title. Empty uses "pwhois IP lookups".
+	Info string
+	// UUID is the event UUID. Empty derives one from Info, Time, and the
+	// addresses, so re-exporting the same lookups updates the same event.
+	UUID string
+	// Time dates the event and its attributes. Zero uses the latest
+	// CacheDate of the records; NewEvent fails with pwhois.ErrInvalidInput
+	// when neither is set.
+	Time time.Time
+	// IPType is the type of the IP attributes, AttributeIPDst or
+	// AttributeIPSrc. Empty uses AttributeIPDst.
+	IPType string
+	// ToIDS flags the IP attributes for detection.
+	ToIDS bool
+	// Distribution is the event distribution level. The zero value is
+	// DistributionOrganization.
+	Distribution int
+}
+
+// originAS collects the records an ASN originates.
+type originAS struct {
+	description string
+	country     string
+	prefixes    map[netip.Prefix]bool
+	addresses   []netip.Addr
+}
+
+// NewEvent builds a MISP event from records. Records with the same IP are
+// exported once. The IP attributes are ordered by address and the asn
+// objects by ASN; an object's description and country are the first
+// AsnOrgName and CountryCode in address order.
+func NewEvent(config Config, records []pwhois.WhoIs) (Document, error) {
+	ipType := config.IPType
+	switch ipType {
+	case "":
+		ipType = AttributeIPDst
+	case AttributeIPDst, AttributeIPSrc:
+	default:
+		return Document{}, check.InvalidInput(fmt.Sprintf("IP attribute type %q is not ip-dst or ip-src", config.IPType))
+	}
+	if config.Distribution < DistributionOrganization || config.Distribution > DistributionAll {
+		return Document{}, check.InvalidInput(fmt.Sprintf("distribution %d is not between 0 and 3", config.Distribution))
+	}
+
+	byAddr := make(map[netip.Addr]pwhois.WhoIs, len(records))
+	eventTime := config.Time
+	for index, record := range records {
+		addr := record.IPAddr
+		if record.IP != "" {
+			parsed, err := netip.ParseAddr(record.IP)
+			if err != nil {
+				return Document{}, fmt.Errorf("export IP record %d: %w", index+1, check.InvalidInput(fmt.Sprintf("IP %q is not an address", record.IP)))
+			}
+			addr = parsed
+		}
+		if !addr.IsValid() {
+			return Document{}, fmt.Errorf("export IP record %d: %w", index+1, check.InvalidInput("IP record has no IP"))
+		}
+		addr = addr.Unmap()
+		if _, ok := byAddr[addr]; !ok {
+			byAddr[addr] = record
+		}
+		if config.Time.IsZero() && record.CacheDate.After(eventTime) {
+			eventTime = record.CacheDate
+		}
+	}
+	if eventTime.IsZero() {
+		return Document{}, check.InvalidInput("records have no Cache-Date and no Config.Time is set")
+	}
+	addresses := make([]netip.Addr, 0, len(byAddr))
+	for addr := range byAddr {
+		addresses = append(addresses, addr)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i].Less(addresses[j]) })
+
+	info := config.Info
+	if info == "" {
+		info = "pwhois IP lookups"
+	}
+	eventUUID := config.UUID
+	if eventUUID == "" {
+		parts := []string{info, eventTime.UTC().Format(time.RFC3339)}
+		for _, addr := range addresses {
+			parts = append(parts, addr.String())
+		}
+		eventUUID = uuid.V5(eventNamespace, strings.Join(parts, "\x00")).String()
+	}
+	namespace, ok := uuid.Parse(eventUUID)
+	if !ok {
+		return Document{}, check.InvalidInput(fmt.Sprintf("event UUID %q is not a UUID", config.UUID))
+	}
+	timestamp := strconv.FormatInt(eventTime.Unix(), 10)
+	newUUID := func(parts ...string) string {
+		return uuid.V5(namespace, strings.Join(parts, "\x00")).String()
+	}
+
+	event := Event{
+		UUID:          namespace.String(),
+		Info:          info,
+		Date:          eventTime.UTC().Format("2006-01-02"),
+		Timestamp:     timestamp,
+		Analysis:      "2",
+		ThreatLevelID: "4",
+		Distribution:  strconv.Itoa(config.Distribution),
+	}
+
+	origins := make(map[uint32]*originAS)
+	attributeUUIDs := make(map[netip.Addr]string, len(addresses))
+	for _, addr := range addresses {
+		record := byAddr[addr]
+		attribute := Attribute{
+			UUID:         newUUID("attribute", ipType, addr.String()),
+			Type:         ipType,
+			Category:     networkActivity,
+			Value:        addr.String(),
+			ToIDS:        config.ToIDS,
+			Distribution: inheritDistribution,
+			Timestamp:    timestamp,
+		}
+		event.Attributes = append(event.Attributes, attribute)
+		attributeUUIDs[addr] = attribute.UUID
+
+		prefix := record.PrefixNet
+		if record.Prefix != "" {
+			parsed, err := netip.ParsePrefix(record.Prefix)
+			if err != nil {
+				return Document{}, fmt.Errorf("export IP record %s: %w", addr, check.InvalidInput(fmt.Sprintf("prefix %q is not a CIDR prefix", record.Prefix)))
+			}
+			prefix = parsed
+		}
+		for _, field := range strings.Fields(record.OriginAS) {
+			number, err := check.ParseASN(field)
+			if err != nil {
+				return Document{}, fmt.Errorf("export IP record %s: %w", addr, err)
+			}
+			origin := origins[number]
+			if origin == nil {
+				origin = &originAS{prefixes: make(map[netip.Prefix]bool)}
+				origins[number] = origin
+			}
+			if origin.description == "" {
+				origin.description = record.AsnOrgName
+			}
+			if origin.country == "" {
+				origin.country = record.CountryCode
+			}
+			if prefix.IsValid() {
+				origin.prefixes[prefix.Masked()] = true
+			}
+			origin.addresses = append(origin.addresses, addr)
+		}
+	}
+
+	numbers := make([]uint32, 0, len(origins))
+	for number := range origins {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	for _, number := range numbers {
+		origin := origins[number]
+		asn := strconv.FormatUint(uint64(number), 10)
+		object := Object{
+			UUID:         newUUID("object", "asn", asn),
+			Name:         "asn",
+			MetaCategory: "network",
+			TemplateUUID: ASNTemplateUUID,
+			Distribution: inheritDistribution,
+			Timestamp:    timestamp,
+		}
+		attribute := func(relation, kind, value string) {
+			if value == "" {
+				return
+			}
+			object.Attributes = append(object.Attributes, Attribute{
+				UUID:           newUUID("object-attribute", asn, relation, value),
+				Type:           kind,
+				Category:       networkActivity,
+				Value:          value,
+				ObjectRelation: relation,
+				Distribution:   inheritDistribution,
+				Timestamp:      timestamp,
+			})
+		}
+		attribute("asn", "AS", asn)
+		attribute("description", "text", origin.description)
+		prefixes := make([]netip.Prefix, 0, len(origin.prefixes))
+		for prefix := range origin.prefixes {
+			prefixes = append(prefixes, prefix)
+		}
+		sort.Slice(prefixes, func(i, j int) bool {
+			if prefixes[i].Addr() != prefixes[j].Addr() {
+				return prefixes[i].Addr().Less(prefixes[j].Addr())
+			}
+			return prefixes[i].Bits() < prefixes[j].Bits()
+		})
+		for _, prefix := range prefixes {
+			attribute("subnet-announced", "ip-src", prefix.String())
+		}
+		attribute("country", "text", origin.country)
+		for _, addr := range origin.addresses {
+			object.References = append(object.References, Reference{
+				UUID:             newUUID("reference", asn, addr.String()),
+				ReferencedUUID:   attributeUUIDs[addr],
+				RelationshipType: RelationshipIncludes,
+				Timestamp:        timestamp,
+			})
+		}
+		event.Objects = append(event.Objects, object)
+	}
+	return Document{Event: event}, nil
+}
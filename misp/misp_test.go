@@ -0,0 +1,136 @@
+package misp
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+)
+
+var testRecords = []pwhois.WhoIs{
+	{IP: "2001:db8::1", OriginAS: "64501", Prefix: "2001:db8::/32", AsnOrgName: "Example Six", CountryCode: "NL",
+		CacheDate: time.Date(2026, 7, 18, 0, 0, 4, 0, time.UTC)},
+	{IP: "192.0.2.9", OriginAS: "64500", Prefix: "192.0.2.0/24", AsnOrgName: "Example AS", CountryCode: "US",
+		CacheDate: time.Date(2026, 7, 17, 0, 0, 0, 0, time.UTC)},
+	{IP: "192.0.2.1", OriginAS: "AS64500", Prefix: "192.0.2.0/24", AsnOrgName: "Example AS", CountryCode: "US"},
+	{IP: "::ffff:192.0.2.1", OriginAS: "64500"},
+	{IP: "198.51.100.7"},
+}
+
+func TestNewEventBuildsASNObjects(t *testing.T) {
+	document, err := NewEvent(Config{Info: "Suspicious logins", IPType: AttributeIPSrc}, testRecords)
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	event := document.Event
+	if event.Info != "Suspicious logins" || event.Date != "2026-07-18" || event.Timestamp != "1784332804" ||
+		event.Distribution != "0" || event.Published {
+		t.Errorf("event = %+v", event)
+	}
+
+	var values []string
+	for _, attribute := range event.Attributes {
+		if attribute.Type != AttributeIPSrc || attribute.Category != "Network activity" || attribute.ToIDS {
+			t.Errorf("IP attribute = %+v", attribute)
+		}
+		values = append(values, attribute.Value)
+	}
+	if want := []string{"192.0.2.1", "192.0.2.9", "198.51.100.7", "2001:db8::1"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("IP attributes = %q, want %q", values, want)
+	}
+
+	if len(event.Objects) != 2 {
+		t.Fatalf("objects = %+v, want one per origin ASN", event.Objects)
+	}
+	object := event.Objects[0]
+	if object.Name != "asn" || object.MetaCategory != "network" || object.TemplateUUID != ASNTemplateUUID {
+		t.Errorf("asn object = %+v", object)
+	}
+	relations := make(map[string][]string)
+	for _, attribute := range object.Attributes {
+		relations[attribute.ObjectRelation+" "+attribute.Type] = append(relations[attribute.ObjectRelation+" "+attribute.Type], attribute.Value)
+	}
+	want := map[string][]string{
+		"asn AS":                  {"64500"},
+		"description text":        {"Example AS"},
+		"subnet-announced ip-src": {"192.0.2.0/24"},
+		"country text":            {"US"},
+	}
+	if !reflect.DeepEqual(relations, want) {
+		t.Errorf("asn object attributes = %v, want %v", relations, want)
+	}
+	if len(object.References) != 2 || object.References[0].ReferencedUUID != event.Attributes[0].UUID ||
+		object.References[1].ReferencedUUID != event.Attributes[1].UUID || object.References[0].RelationshipType != RelationshipIncludes {
+		t.Errorf("asn object references = %+v", object.References)
+	}
+
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var shape struct {
+		Event struct {
+			Attribute []map[string]any
+			Object    []struct {
+				Attribute       []map[string]any
+				ObjectReference []map[string]any
+			}
+		}
+	}
+	if err := json.Unmarshal(encoded, &shape); err != nil || len(shape.Event.Attribute) != 4 || len(shape.Event.Object[1].ObjectReference) != 1 {
+		t.Errorf("event JSON = %s, %v", encoded, err)
+	}
+}
+
+func TestNewEventIsDeterministic(t *testing.T) {
+	reordered := []pwhois.WhoIs{testRecords[4], testRecords[2], testRecords[0], testRecords[1], testRecords[3]}
+	first, err := NewEvent(Config{}, testRecords)
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	second, err := NewEvent(Config{}, reordered)
+	if err != nil {
+		t.Fatalf("NewEvent reordered: %v", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("events differ by record order:\n%+v\n%+v", first, second)
+	}
+
+	const eventUUID = "5a2f0c2e-1c55-4b3b-8f2b-0d0c3b1f4a10"
+	configured, err := NewEvent(Config{UUID: eventUUID, Time: time.Date(2026, 7, 19, 0, 0, 0, 0, time.UTC)}, testRecords)
+	if err != nil {
+		t.Fatalf("NewEvent with UUID: %v", err)
+	}
+	if configured.Event.UUID != eventUUID || configured.Event.Date != "2026-07-19" ||
+		configured.Event.Attributes[0].UUID == first.Event.Attributes[0].UUID {
+		t.Errorf("configured event = %+v", configured.Event)
+	}
+}
+
+func TestNewEventRejectsBadInput(t *testing.T) {
+	dated := time.Date(2026, 7, 18, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		config  Config
+		records []pwhois.WhoIs
+	}{
+		{name: "IP type", config: Config{Time: dated, IPType: "domain"}},
+		{name: "distribution", config: Config{Time: dated, Distribution: 5}},
+		{name: "UUID", config: Config{Time: dated, UUID: "not-a-uuid"}},
+		{name: "undated", records: []pwhois.WhoIs{{IP: "192.0.2.1"}}},
+		{name: "no IP", config: Config{Time: dated}, records: []pwhois.WhoIs{{OriginAS: "64500"}}},
+		{name: "bad IP", config: Config{Time: dated}, records: []pwhois.WhoIs{{IP: "192.0.2"}}},
+		{name: "bad origin", config: Config{Time: dated}, records: []pwhois.WhoIs{{IP: "192.0.2.1", OriginAS: "AS-X"}}},
+		{name: "bad prefix", config: Config{Time: dated}, records: []pwhois.WhoIs{{IP: "192.0.2.1", Prefix: "192.0.2.0"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewEvent(test.config, test.records); !errors.Is(err, pwhois.ErrInvalidInput) {
+				t.Errorf("error = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package pwhois
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultPoolMaxConnections is the connection limit used when
+// PoolConfig.MaxConnections is zero.
+const DefaultPoolMaxConnections int = 8
+
+// PoolConfig configures a Pool.
+type PoolConfig struct {
+	// Servers lists the endpoints queries are spread across. Each is
+	// prepared like ClientConfig.Server. At least one is required, and all
+	// must share one BatchMaxSize, since a query is formatted before its
+	// endpoint is chosen.
+	Servers []WhoisServer
+	// MaxConnections bounds connections open at once across all endpoints,
+	// which is also the number of queries in flight. Zero uses
+	// DefaultPoolMaxConnections.
+	MaxConnections int
+	// MaxPerEndpoint bounds queries in flight to one endpoint. Zero uses
+	// MaxConnections.
+	MaxPerEndpoint int
+	// HealthCheckInterval, when positive, probes every endpoint this often
+	// by opening a connection within the endpoint's Timeout and closing it
+	// without sending a query. Zero disables health checks.
+	HealthCheckInterval time.Duration
+	// RateLimiter, when set, paces every query as ClientConfig.RateLimiter
+	// does, per endpoint. A query waits for budget after its endpoint is
+	// chosen, holding its place under MaxPerEndpoint.
+	RateLimiter *RateLimiter
+	// CircuitBreaker, when set, guards every query as
+	// ClientConfig.CircuitBreaker does, per endpoint. A query sent to an
+	// endpoint whose circuit is open fails fast; use FailoverClient to route
+	// around it instead.
+	CircuitBreaker *CircuitBreaker
+}
+
+// PoolStats reports pool occupancy.
+type PoolStats struct {
+	// InUse counts queries in flight, each holding one connection.
+	InUse int
+	// Unhealthy counts endpoints whose last health check failed.
+	Unhealthy int
+}
+
+// Pool runs lookups from many goroutines against one or more endpoints
+// under connection limits. Each query goes to the endpoint with the fewest
+// queries in flight, waiting while the limits are reached, and gets a new
+// connection of its own.
+//
+// With HealthCheckInterval set, endpoints whose last probe failed receive no
+// queries while any endpoint passed its probe, and return to service once a
+// probe succeeds. While every endpoint is failing, queries are spread across
+// all of them rather than refused.
+//
+// Connections are never kept idle for reuse: a native PWHOIS response ends
+// when the server closes the connection, so a finished connection cannot
+// carry another query. Callers never handle a net.Conn; errors are reported
+// exactly as by Client.
+type Pool struct {
+	// format formats every query. It carries the endpoints' shared query
+	// settings and names no endpoint, so pool-level failures are not
+	// attributed to one.
+	format         WhoisServer
+	maxConnections int
+	maxPerEndpoint int
+	guard          queryGuard
+
+	stopChecks context.CancelFunc
+	checks     sync.WaitGroup
+
+	mu        sync.Mutex
+	endpoints []*poolEndpoint
+	inUse     int
+	changed   chan struct{}
+	closed    bool
+}
+
+type poolEndpoint struct {
+	server    WhoisServer
+	inUse     int
+	unhealthy bool
+}
+
+// NewPool validates configuration and returns a Pool. No connection is made
+// until the first lookup or health check.
+func NewPool(config PoolConfig) (*Pool, error) {
+	if len(config.Servers) == 0 {
+		return nil, invalidInputError("pool requires at least one server")
+	}
+	if config.MaxConnections < 0 || config.MaxPerEndpoint < 0 {
+		return nil, invalidInputError("pool limits cannot be negative")
+	}
+	if config.HealthCheckInterval < 0 {
+		return nil, invalidInputError("pool health check interval cannot be negative")
+	}
+
+	pool := &Pool{
+		maxConnections: config.MaxConnections,
+		maxPerEndpoint: config.MaxPerEndpoint,
+		guard:          queryGuard{limiter: config.RateLimiter, breaker: config.CircuitBreaker},
+		changed:        make(chan struct{}),
+	}
+	if pool.maxConnections == 0 {
+		pool.maxConnections = DefaultPoolMaxConnections
+	}
+	if pool.maxPerEndpoint == 0 || pool.maxPerEndpoint > pool.maxConnections {
+		pool.maxPerEndpoint = pool.maxConnections
+	}
+	for _, configured := range config.Servers {
+		server, err := prepareServerConfig(configured)
+		if err != nil {
+			return nil, err
+		}
+		if len(pool.endpoints) > 0 && server.BatchMaxSize != pool.format.BatchMaxSize {
+			return nil, invalidInputError("pool servers must share one batch maximum size")
+		}
+		pool.format.BatchMaxSize = server.BatchMaxSize
+		pool.endpoints = append(pool.endpoints, &poolEndpoint{server: server})
+	}
+
+	if config.HealthCheckInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		pool.stopChecks = cancel
+		pool.checks.Add(1)
+		go pool.checkHealth(ctx, config.HealthCheckInterval)
+	}
+	return pool, nil
+}
+
+// Close stops the pool and its health checks. Queries in flight finish
+// normally; lookups after Close fail with ErrConnection, and lookups waiting
+// for a connection are woken to fail the same way.
+func (pool *Pool) Close() error {
+	pool.mu.Lock()
+	if !pool.closed {
+		pool.closed = true
+		pool.notifyLocked()
+	}
+	pool.mu.Unlock()
+
+	if pool.stopChecks != nil {
+		pool.stopChecks()
+		pool.checks.Wait()
+	}
+	return nil
+}
+
+// Stats returns current occupancy.
+func (pool *Pool) Stats() PoolStats {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	stats := PoolStats{InUse: pool.inUse}
+	for _, endpoint := range pool.endpoints {
+		if endpoint.unhealthy {
+			stats.Unhealthy++
+		}
+	}
+	return stats
+}
+
+// LookupIP looks up one or more IP addresses in a single query.
+func (pool *Pool) LookupIP(ctx context.Context, ips ...string) ([]WhoIs, error) {
+	return lookupIP(ctx, pool.format, pool.query, ips)
+}
+
+// LookupIPBatch is Client.LookupIPBatch on a pooled connection.
+func (pool *Pool) LookupIPBatch(ctx context.Context, ips ...string) (IPBatchResult, error) {
+	return lookupIPBatch(ctx, pool.format, pool.query, ips)
+}
+
+// LookupRouteView returns the routes originated by asn.
+func (pool *Pool) LookupRouteView(ctx context.Context, asn string) (BGPRoutes, error) {
+	return lookupRouteView(ctx, pool.format, pool.query, asn)
+}
+
+// LookupRegistry returns registry data for asn.
+func (pool *Pool) LookupRegistry(ctx context.Context, asn string) (RegistryRecord, error) {
+	return lookupRegistry(ctx, pool.format, pool.query, asn)
+}
+
+// LookupNetblock returns the netblocks announced by asn.
+func (pool *Pool) LookupNetblock(ctx context.Context, asn string) (NetblockRecord, error) {
+	return lookupNetblock(ctx, pool.format, pool.query, asn)
+}
+
+func (pool *Pool) query(ctx context.Context, operation, query string) (string, WhoisServer, error) {
+	if ctx == nil {
+		return "", pool.format, invalidInputError("lookup context is required")
+	}
+	endpoint, err := pool.acquire(ctx)
+	if err != nil {
+		return "", pool.format, pool.format.operationError(operation, err)
+	}
+	defer pool.release(endpoint)
+
+	server := endpoint.server
+	probe, err := pool.guard.admit(ctx, server, operation, query)
+	if err != nil {
+		return "", server, err
+	}
+	response, err := server.dialQueryContext(ctx, operation, query)
+	pool.guard.settle(ctx, server, probe, err)
+	return response, server, err
+}
+
+// acquire waits until an endpoint is below its limit and the pool below its
+// connection limit, then reserves a slot on the endpoint with the fewest
+// queries in flight. Unhealthy endpoints are passed over while any endpoint
+// is healthy.
+func (pool *Pool) acquire(ctx context.Context) (*poolEndpoint, error) {
+	for {
+		pool.mu.Lock()
+		if pool.closed {
+			pool.mu.Unlock()
+			return nil, fmt.Errorf("%w: pool closed", ErrConnection)
+		}
+		var chosen *poolEndpoint
+		if pool.inUse < pool.maxConnections {
+			anyHealthy := false
+			for _, endpoint := range pool.endpoints {
+				anyHealthy = anyHealthy || !endpoint.unhealthy
+			}
+			for _, endpoint := range pool.endpoints {
+				if endpoint.inUse >= pool.maxPerEndpoint || (endpoint.unhealthy && anyHealthy) {
+					continue
+				}
+				if chosen == nil || endpoint.inUse < chosen.inUse {
+					chosen = endpoint
+				}
+			}
+		}
+		if chosen != nil {
+			chosen.inUse++
+			pool.inUse++
+			pool.mu.Unlock()
+			return chosen, nil
+		}
+		changed := pool.changed
+		pool.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, classifyTransportError(ctx.Err())
+		}
+	}
+}
+
+// release frees the slot a query reserved on endpoint.
+func (pool *Pool) release(endpoint *poolEndpoint) {
+	pool.mu.Lock()
+	endpoint.inUse--
+	pool.inUse--
+	pool.notifyLocked()
+	pool.mu.Unlock()
+}
+
+// notifyLocked wakes every goroutine waiting in acquire.
+func (pool *Pool) notifyLocked() {
+	close(pool.changed)
+	pool.changed = make(chan struct{})
+}
+
+// checkHealth probes every endpoint each interval until ctx ends.
+func (pool *Pool) checkHealth(ctx context.Context, interval time.Duration) {
+	defer pool.checks.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var probes sync.WaitGroup
+		for _, endpoint := range pool.endpoints {
+			probes.Add(1)
+			go func(endpoint *poolEndpoint) {
+				defer probes.Done()
+				unhealthy := endpoint.server.probe(ctx) != nil
+				if ctx.Err() != nil {
+					return
+				}
+				pool.mu.Lock()
+				if endpoint.unhealthy != unhealthy {
+					endpoint.unhealthy = unhealthy
+					pool.notifyLocked()
+				}
+				pool.mu.Unlock()
+			}(endpoint)
+		}
+		probes.Wait()
+	}
+}
+
+// probe opens a connection to server and closes it without sending a query.
+func (server WhoisServer) probe(ctx context.Context) error {
+	if err := server.ConnectContext(ctx); err != nil {
+		return err
+	}
+	return server.Connection.Close()
+}
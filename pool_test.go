@@ -0,0 +1,167 @@
+package pwhois
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolBoundsConnectionsAcrossGoroutines(t *testing.T) {
+	server, state := startIPBatchServer(t, ipRecords)
+	pool, err := NewPool(PoolConfig{Servers: []WhoisServer{server}, MaxConnections: 2})
+	if err != nil {
+		t.Fatalf("new pool: %v", err)
+	}
+	defer pool.Close()
+
+	var wait sync.WaitGroup
+	errs := make(chan error, 12)
+	for index := 1; index <= 12; index++ {
+		wait.Add(1)
+		go func(ip string) {
+			defer wait.Done()
+			records, err := pool.LookupIP(context.Background(), ip)
+			if err == nil && records[0].IP != ip {
+				err = fmt.Errorf("record = %+v, want %s", records[0], ip)
+			}
+			errs <- err
+		}(fmt.Sprintf("192.0.2.%d", index))
+	}
+	wait.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("pooled lookup: %v", err)
+		}
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if len(state.batches) != 12 || state.maxActive != 2 {
+		t.Errorf("served %d queries with peak %d connections, want 12 at peak 2", len(state.batches), state.maxActive)
+	}
+	if stats := pool.Stats(); stats.InUse != 0 {
+		t.Errorf("stats = %+v, want none in use", stats)
+	}
+}
+
+func TestPoolCapsQueriesPerEndpoint(t *testing.T) {
+	first, firstState := startIPBatchServer(t, ipRecords)
+	second, secondState := startIPBatchServer(t, ipRecords)
+	pool, err := NewPool(PoolConfig{Servers: []WhoisServer{first, second}, MaxConnections: 4, MaxPerEndpoint: 1})
+	if err != nil {
+		t.Fatalf("new pool: %v", err)
+	}
+	defer pool.Close()
+
+	var wait sync.WaitGroup
+	for index := 1; index <= 6; index++ {
+		wait.Add(1)
+		go func(ip string) {
+			defer wait.Done()
+			if _, err := pool.LookupIP(context.Background(), ip); err != nil {
+				t.Errorf("lookup %s: %v", ip, err)
+			}
+		}(fmt.Sprintf("192.0.2.%d", index))
+	}
+	wait.Wait()
+
+	for name, state := range map[string]*ipBatchServer{"first": firstState, "second": secondState} {
+		state.mu.Lock()
+		if state.maxActive != 1 || len(state.batches) == 0 {
+			t.Errorf("%s endpoint served %d queries with peak %d, want some at peak 1", name, len(state.batches), state.maxActive)
+		}
+		state.mu.Unlock()
+	}
+}
+
+func TestPoolRoutesAroundEndpointsFailingHealthChecks(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	closedPort := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+	down := WhoisServer{Server: "127.0.0.1", Port: closedPort, Timeout: time.Second}
+	up, state := startIPBatchServer(t, ipRecords)
+
+	pool, err := NewPool(PoolConfig{Servers: []WhoisServer{down, up}, HealthCheckInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("new pool: %v", err)
+	}
+	defer pool.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for pool.Stats().Unhealthy != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("stats = %+v, want the closed endpoint unhealthy", pool.Stats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for index := 1; index <= 3; index++ {
+		if _, err := pool.LookupIP(context.Background(), fmt.Sprintf("192.0.2.%d", index)); err != nil {
+			t.Fatalf("lookup with an unhealthy endpoint: %v", err)
+		}
+	}
+	state.mu.Lock()
+	served := len(state.batches)
+	state.mu.Unlock()
+	if served != 3 {
+		t.Errorf("healthy endpoint served %d queries, want 3", served)
+	}
+}
+
+func TestPoolLifecycleErrors(t *testing.T) {
+	for name, config := range map[string]PoolConfig{
+		"no servers":        {},
+		"negative limit":    {Servers: []WhoisServer{{}}, MaxConnections: -1},
+		"invalid server":    {Servers: []WhoisServer{{Port: 70000}}},
+		"negative per-host": {Servers: []WhoisServer{{}}, MaxPerEndpoint: -1},
+		"mixed batch sizes": {Servers: []WhoisServer{{}, {BatchMaxSize: 100}}},
+		"negative interval": {Servers: []WhoisServer{{}}, HealthCheckInterval: -time.Second},
+	} {
+		if _, err := NewPool(config); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: error = %v, want ErrInvalidInput", name, err)
+		}
+	}
+
+	server, _ := startIPBatchServer(t, ipRecords)
+	pool, err := NewPool(PoolConfig{Servers: []WhoisServer{server}})
+	if err != nil {
+		t.Fatalf("new pool: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.LookupIP(ctx, "192.0.2.1"); !errors.Is(err, ErrCanceled) {
+		t.Errorf("canceled lookup error = %v, want ErrCanceled", err)
+	}
+
+	// A lookup canceled while waiting for a connection fails at the pool, not
+	// at an endpoint.
+	held := make([]*poolEndpoint, 0, DefaultPoolMaxConnections)
+	for len(held) < DefaultPoolMaxConnections {
+		endpoint, err := pool.acquire(context.Background())
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		held = append(held, endpoint)
+	}
+	_, err = pool.LookupIP(ctx, "192.0.2.1")
+	var operationError *OperationError
+	if !errors.Is(err, ErrCanceled) || !errors.As(err, &operationError) || operationError.Server != "" {
+		t.Errorf("lookup canceled waiting = %v, want ErrCanceled naming no endpoint", err)
+	}
+	for _, endpoint := range held {
+		pool.release(endpoint)
+	}
+	if err := pool.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := pool.LookupIP(context.Background(), "192.0.2.1"); !errors.Is(err, ErrConnection) {
+		t.Errorf("lookup after close error = %v, want ErrConnection", err)
+	}
+}
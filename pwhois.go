@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -48,6 +49,7 @@ var (
 	ErrResponseTooLarge  = errors.New("pwhois response exceeds maximum size")
 	ErrMalformedResponse = errors.New("pwhois malformed response")
 	ErrNoRecords         = errors.New("pwhois no records returned")
+	ErrCircuitOpen       = errors.New("pwhois circuit open")
 )
 
 // OperationError adds lookup operation and server context while preserving the
@@ -120,19 +122,6 @@ func invalidResponseValue(field string, err error) error {
 	return &responseValueError{field: field, err: err}
 }
 
-// Utility function to deduplicate values
-func removeDuplicate[T string | int](sliceList []T) []T {
-	allKeys := make(map[T]bool)
-	list := []T{}
-	for _, item := range sliceList {
-		if _, value := allKeys[item]; !value {
-			allKeys[item] = true
-			list = append(list, item)
-		}
-	}
-	return list
-}
-
 // Utiliy function to check string is only digits
 func isOnlyDigits(s string) bool {
 	return regexp.MustCompile(`^\d+$`).MatchString(s)
@@ -211,6 +200,42 @@ func parseResponseFloat64(field, value string) (float64, error) {
 	return parsed, nil
 }
 
+// parseResponseAddr parses an address field. IPv4-mapped IPv6 addresses are
+// unmapped so typed addresses compare equal to canonical query inputs.
+func parseResponseAddr(field, value string) (netip.Addr, error) {
+	if value == "" {
+		return netip.Addr{}, nil
+	}
+
+	parsed, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}, invalidResponseValue(field, err)
+	}
+	if parsed.Zone() != "" {
+		return netip.Addr{}, invalidResponseValue(field, fmt.Errorf("unexpected IPv6 zone"))
+	}
+	return canonicalIP(parsed), nil
+}
+
+func parseResponsePrefix(field, value string) (netip.Prefix, error) {
+	if value == "" {
+		return netip.Prefix{}, nil
+	}
+
+	parsed, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, invalidResponseValue(field, err)
+	}
+	return parsed, nil
+}
+
+// Dialer establishes the transport used for PWHOIS queries. *net.Dialer and
+// SOCKS5Dialer satisfy it; tests can supply one that returns net.Pipe
+// connections.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
 // Whois server object
 type WhoisServer struct {
 	Server       string `default:"whois.pwhois.org"`
@@ -220,9 +245,17 @@ type WhoisServer struct {
 	// exchange. A zero value uses SocketTimeout.
 	Timeout time.Duration
 	// MaxResponseBytes bounds response data read before parsing. A value less
-	// than or equal to zero uses DefaultMaxResponseBytes.
+	// than or equal to zero uses DefaultMaxResponseBytes. Streaming lookups
+	// enforce it on the whole response too, even though they do not buffer it.
 	MaxResponseBytes int64
-	Connection       net.Conn
+	// MaxRecordBytes bounds each record or line a streaming lookup holds in
+	// memory. A value less than or equal to zero uses the MaxResponseBytes
+	// limit.
+	MaxRecordBytes int64
+	// Dialer establishes connections for Connect, ConnectContext, and Client.
+	// A nil value dials TCP directly with SocketKeepAlive.
+	Dialer     Dialer
+	Connection net.Conn
 }
 
 // Return full DNS server socket Aadress
@@ -278,9 +311,13 @@ func (server WhoisServer) timeout() time.Duration {
 // setLookupDeadline bounds both the request write and response read. PWHOIS
 // lookups use a connection per request, so the deadline covers the complete
 // exchange rather than allowing a server that stops responding to block
-// indefinitely.
-func (server WhoisServer) setLookupDeadline() error {
-	return server.Connection.SetDeadline(time.Now().Add(server.timeout()))
+// indefinitely. An earlier context deadline takes precedence.
+func (server WhoisServer) setLookupDeadline(ctx context.Context) error {
+	deadline := time.Now().Add(server.timeout())
+	if contextDeadline, ok := ctx.Deadline(); ok && contextDeadline.Before(deadline) {
+		deadline = contextDeadline
+	}
+	return server.Connection.SetDeadline(deadline)
 }
 
 func (server WhoisServer) operationError(operation string, err error) error {
@@ -307,6 +344,27 @@ func classifyTransportError(err error) error {
 	return fmt.Errorf("%w: %w", ErrConnection, err)
 }
 
+// classifyContextTransportError attributes a transport failure to the
+// caller's context when that context ended, because an expired context
+// interrupts I/O by moving the connection deadline into the past.
+func classifyContextTransportError(ctx context.Context, err error) error {
+	if contextErr := ctx.Err(); contextErr != nil && !errors.Is(err, contextErr) {
+		return classifyTransportError(errors.Join(contextErr, err))
+	}
+	return classifyTransportError(err)
+}
+
+// callerExpired reports whether the caller's context has ended. A context
+// whose deadline has passed counts even before its timer marks it done,
+// because the connection deadline set from it can fire first.
+func callerExpired(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
 func isRateLimitedResponse(response string) bool {
 	return strings.Contains(strings.ToLower(response), "query limit exceeded")
 }
@@ -314,22 +372,25 @@ func isRateLimitedResponse(response string) bool {
 // executeQuery applies one consistent connection, deadline, transport, and
 // rate-limit error contract to every native PWHOIS lookup.
 func (server WhoisServer) executeQuery(operation, query string) (string, error) {
-	if server.Connection == nil {
-		return "", server.operationError(operation, ErrConnection)
-	}
-	if err := server.setLookupDeadline(); err != nil {
-		return "", server.operationError(operation, classifyTransportError(err))
-	}
-	if _, err := server.Connection.Write([]byte(query)); err != nil {
-		return "", server.operationError(operation, classifyTransportError(err))
+	return server.executeQueryContext(context.Background(), operation, query)
+}
+
+// executeQueryContext is executeQuery bounded by ctx. Cancellation interrupts
+// a blocked write or read by expiring the connection deadline, so the
+// connection must not be reused after a canceled lookup.
+func (server WhoisServer) executeQueryContext(ctx context.Context, operation, query string) (string, error) {
+	stop, err := server.writeQueryContext(ctx, operation, query)
+	if err != nil {
+		return "", err
 	}
+	defer stop()
 
 	response, err := server.readLookupResponse()
 	if err != nil {
 		if errors.Is(err, ErrResponseTooLarge) {
 			return "", server.operationError(operation, err)
 		}
-		return "", server.operationError(operation, classifyTransportError(err))
+		return "", server.operationError(operation, classifyContextTransportError(ctx, err))
 	}
 	if isRateLimitedResponse(response) {
 		return "", server.operationError(operation, ErrRateLimited)
@@ -338,6 +399,30 @@ func (server WhoisServer) executeQuery(operation, query string) (string, error)
 	return response, nil
 }
 
+// writeQueryContext applies the lookup deadline, arranges for ctx to
+// interrupt blocked I/O, and writes query. The caller reads the response and
+// then calls stop.
+func (server WhoisServer) writeQueryContext(ctx context.Context, operation, query string) (func() bool, error) {
+	if server.Connection == nil {
+		return nil, server.operationError(operation, ErrConnection)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, server.operationError(operation, classifyTransportError(err))
+	}
+	if err := server.setLookupDeadline(ctx); err != nil {
+		return nil, server.operationError(operation, classifyTransportError(err))
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = server.Connection.SetDeadline(time.Unix(1, 0))
+	})
+
+	if _, err := server.Connection.Write([]byte(query)); err != nil {
+		stop()
+		return nil, server.operationError(operation, classifyContextTransportError(ctx, err))
+	}
+	return stop, nil
+}
+
 func (server WhoisServer) responseSizeLimit() int64 {
 	if server.MaxResponseBytes > 0 {
 		return server.MaxResponseBytes
@@ -346,6 +431,14 @@ func (server WhoisServer) responseSizeLimit() int64 {
 	return DefaultMaxResponseBytes
 }
 
+func (server WhoisServer) recordSizeLimit() int64 {
+	if server.MaxRecordBytes > 0 {
+		return min(server.MaxRecordBytes, server.responseSizeLimit())
+	}
+
+	return server.responseSizeLimit()
+}
+
 // readLookupResponse reads remote input into a strictly capacity-controlled
 // raw-response buffer. An over-limit connection is closed because its unread
 // response cannot be safely reused.
@@ -408,16 +501,33 @@ func readBoundedResponse(reader io.Reader, limit int64) ([]byte, error) {
 
 // Establish connection to the pwhois server
 func (server *WhoisServer) Connect() error {
+	return server.ConnectContext(context.Background())
+}
 
-	whoisDialer := &net.Dialer{
-		Timeout:   server.timeout(),
-		KeepAlive: time.Second * time.Duration(SocketKeepAlive),
-	}
+// ConnectContext establishes a connection to the pwhois server through
+// Dialer. ctx can cancel or shorten connection establishment; Timeout still
+// bounds it.
+func (server *WhoisServer) ConnectContext(ctx context.Context) error {
+
+	dialContext, cancel := context.WithTimeout(ctx, server.timeout())
+	defer cancel()
 
 	var err error
-	server.Connection, err = whoisDialer.Dial("tcp", server.ServerAddressString())
+	server.Connection, err = server.dialer().DialContext(dialContext, "tcp", server.ServerAddressString())
 	if err != nil {
-		return server.operationError("connect", classifyTransportError(err))
+		server.Connection = nil
+		return server.operationError("connect", classifyContextTransportError(dialContext, err))
 	}
 	return nil
 }
+
+func (server WhoisServer) dialer() Dialer {
+	if server.Dialer != nil {
+		return server.Dialer
+	}
+
+	return &net.Dialer{
+		Timeout:   server.timeout(),
+		KeepAlive: time.Second * time.Duration(SocketKeepAlive),
+	}
+}
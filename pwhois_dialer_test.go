@@ -0,0 +1,59 @@
+package pwhois
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+)
+
+type pipeDialer struct {
+	network string
+	address string
+	serve   func(net.Conn)
+}
+
+func (dialer *pipeDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer.network = network
+	dialer.address = address
+	client, server := net.Pipe()
+	go dialer.serve(server)
+	return client, nil
+}
+
+func TestConnectUsesConfiguredDialer(t *testing.T) {
+	const query = "app=\"GO pwhois Module\"\n192.0.2.1\n"
+	served := make(chan string, 1)
+	dialer := &pipeDialer{serve: func(connection net.Conn) {
+		defer connection.Close()
+		request := make([]byte, len(query))
+		if _, err := io.ReadFull(connection, request); err != nil {
+			served <- err.Error()
+			return
+		}
+		served <- string(request)
+		_, _ = io.WriteString(connection, "IP: 192.0.2.1\nOrigin-AS: 64500\n")
+	}}
+
+	server := WhoisServer{Server: "pwhois.example", Port: 4343, Dialer: dialer}
+	if err := server.Connect(); err != nil {
+		t.Fatalf("connect through pipe dialer: %v", err)
+	}
+	defer server.Connection.Close()
+	if dialer.network != "tcp" || dialer.address != "pwhois.example:4343" {
+		t.Fatalf("dialed %s %s, want tcp pwhois.example:4343", dialer.network, dialer.address)
+	}
+
+	responses := make(chan IpLookupResponse, 1)
+	server.LookupIP(query, responses)
+	response := <-responses
+	if response.Error != nil {
+		t.Fatalf("lookup over pipe: %v", response.Error)
+	}
+	if got := <-served; got != query {
+		t.Fatalf("served request = %q, want %q", got, query)
+	}
+	if len(response.Response) != 1 || response.Response[0].OriginAS != "64500" {
+		t.Fatalf("IP response = %+v", response.Response)
+	}
+}
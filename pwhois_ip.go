@@ -2,7 +2,8 @@ package pwhois
 
 import (
 	"fmt"
-	"net"
+	"net/netip"
+	"slices"
 	"strings"
 	"time"
 )
@@ -25,6 +26,13 @@ type WhoIs struct {
 	CountryCode         string    `json:"country_code"`
 	RouteOriginatedDate time.Time `json:"route_originated_date"`
 	RouteOriginatedTS   int64     `json:"route_originated_ts"`
+
+	// IPAddr is IP parsed and canonicalized; zero when IP is empty.
+	IPAddr netip.Addr `json:"-"`
+	// PrefixNet is Prefix parsed; zero when Prefix is empty.
+	PrefixNet netip.Prefix `json:"-"`
+	// ASPath is AsnPath parsed; nil when AsnPath is empty or does not parse.
+	ASPath ASPath `json:"-"`
 }
 
 // Channel return object for ip query response
@@ -33,6 +41,138 @@ type IpLookupResponse struct {
 	Error    error
 }
 
+// IPInputReason explains why an IP query input was not sent to the server.
+type IPInputReason string
+
+const (
+	// IPInputUnparseable marks a value that is not an IPv4 or IPv6 address.
+	IPInputUnparseable IPInputReason = "unparseable"
+	// IPInputZoned marks an IPv6 address with a zone such as %eth0, which has
+	// no meaning to a remote PWHOIS server.
+	IPInputZoned IPInputReason = "zoned_ipv6"
+	// IPInputDuplicate marks an input whose canonical address repeats an
+	// earlier accepted input.
+	IPInputDuplicate IPInputReason = "duplicate"
+)
+
+// IPInput identifies one caller-supplied IP query value by its position.
+type IPInput struct {
+	// Index is the position of Value in the caller's slice.
+	Index int
+	// Value is the input exactly as supplied.
+	Value string
+	// Addr is the canonical address for accepted and duplicate inputs and the
+	// zero Addr for rejected inputs.
+	Addr netip.Addr
+	// Reason is empty for accepted inputs.
+	Reason IPInputReason
+	// DuplicateOf is the Index of the accepted input that a duplicate
+	// repeats. It is only meaningful when Reason is IPInputDuplicate.
+	DuplicateOf int
+}
+
+// IPValidation accounts for every IP query input. Each input index appears in
+// exactly one of Accepted, Duplicates, or Rejected.
+type IPValidation struct {
+	// Accepted inputs are sent to the server in this order.
+	Accepted []IPInput
+	// Duplicates repeat an accepted input and are not sent again.
+	Duplicates []IPInput
+	// Rejected inputs are not valid IP query values.
+	Rejected []IPInput
+}
+
+// Addresses returns the canonical text of the accepted addresses in query
+// order. These are the values sent to the server.
+func (validation IPValidation) Addresses() []string {
+	addresses := make([]string, 0, len(validation.Accepted))
+	for _, input := range validation.Accepted {
+		addresses = append(addresses, input.Addr.String())
+	}
+	return addresses
+}
+
+// Spellings maps each canonical address to the distinct input spellings that
+// produced it, in input order.
+func (validation IPValidation) Spellings() map[netip.Addr][]string {
+	spellings := make(map[netip.Addr][]string, len(validation.Accepted))
+	for _, input := range validation.Accepted {
+		spellings[input.Addr] = []string{input.Value}
+	}
+	for _, input := range validation.Duplicates {
+		if !slices.Contains(spellings[input.Addr], input.Value) {
+			spellings[input.Addr] = append(spellings[input.Addr], input.Value)
+		}
+	}
+	return spellings
+}
+
+// canonicalIP is the form used to deduplicate, send, and correlate
+// addresses: IPv4-mapped IPv6 addresses become IPv4, and String renders IPv6
+// in lowercase with zeros compressed.
+func canonicalIP(address netip.Addr) netip.Addr {
+	return address.Unmap()
+}
+
+// IPValidationError reports inputs rejected by a strict IP query. It matches
+// ErrInvalidInput with errors.Is.
+type IPValidationError struct {
+	Rejected []IPInput
+}
+
+func (err *IPValidationError) Error() string {
+	if len(err.Rejected) == 1 {
+		return fmt.Sprintf("%s: IP input %d is %s", ErrInvalidInput, err.Rejected[0].Index, err.Rejected[0].Reason)
+	}
+	return fmt.Sprintf("%s: %d IP inputs rejected", ErrInvalidInput, len(err.Rejected))
+}
+
+func (err *IPValidationError) Unwrap() error {
+	return ErrInvalidInput
+}
+
+// IPQueryOptions controls FormatIpQueryWithValidation.
+type IPQueryOptions struct {
+	// Strict fails the whole query with *IPValidationError when any input is
+	// rejected. Duplicates never fail a query.
+	Strict bool
+}
+
+// ValidateIPs classifies every value as accepted, duplicate, or rejected
+// without building a query. Values are compared by canonical address, so
+// ::ffff:192.0.2.1 duplicates 192.0.2.1 and 2001:DB8::1 duplicates
+// 2001:db8::1.
+func ValidateIPs(values []string) IPValidation {
+	var validation IPValidation
+	firstIndex := make(map[netip.Addr]int, len(values))
+
+	for index, value := range values {
+		input := IPInput{Index: index, Value: value}
+		address, err := netip.ParseAddr(value)
+		switch {
+		case err != nil:
+			input.Reason = IPInputUnparseable
+			validation.Rejected = append(validation.Rejected, input)
+			continue
+		case address.Zone() != "":
+			input.Reason = IPInputZoned
+			validation.Rejected = append(validation.Rejected, input)
+			continue
+		}
+
+		input.Addr = canonicalIP(address)
+		if first, seen := firstIndex[input.Addr]; seen {
+			input.Reason = IPInputDuplicate
+			input.DuplicateOf = first
+			validation.Duplicates = append(validation.Duplicates, input)
+			continue
+		}
+		firstIndex[input.Addr] = index
+		validation.Accepted = append(validation.Accepted, input)
+	}
+	return validation
+}
+
 /*
 	Returns string formatted IP(s) query.
 
@@ -41,37 +181,128 @@ args:
 >values: slice of strings of IP address(es)
 */
 func (server *WhoisServer) FormatIpQuery(values []string) (string, error) {
+	query, _, err := server.FormatIpQueryWithValidation(values, IPQueryOptions{})
+	return query, err
+}
 
-	queryString := fmt.Sprintf("app=\"%s\"\n", AppName)
-	var checkedValues []string
+// FormatIpQueryWithValidation returns the IP query for values together with
+// an account of every input. Invalid inputs are skipped unless options.Strict
+// is set; the validation is returned even when an error is.
+func (server *WhoisServer) FormatIpQueryWithValidation(values []string, options IPQueryOptions) (string, IPValidation, error) {
 
-	// build slice of valid IP values from provided values
-	for _, value := range values {
-		if net.ParseIP(value) == nil {
-			continue
-		}
-		checkedValues = append(checkedValues, value)
+	validation := ValidateIPs(values)
+	if options.Strict && len(validation.Rejected) > 0 {
+		return "", validation, &IPValidationError{Rejected: validation.Rejected}
 	}
 
-	deduplicatedValues := removeDuplicate(checkedValues)
+	query, err := server.formatIpQuery(validation.Addresses())
+	return query, validation, err
+}
+
+// formatIpQuery builds the wire query for already validated, deduplicated
+// addresses.
+func (server *WhoisServer) formatIpQuery(addresses []string) (string, error) {
+
+	queryString := fmt.Sprintf("app=\"%s\"\n", AppName)
 
 	// check slice sizes and build query string
-	if len(deduplicatedValues) == 0 {
+	if len(addresses) == 0 {
 		return "", invalidInputError("at least one valid IP address is required")
-	} else if len(deduplicatedValues) > server.BatchMaxSize {
+	} else if len(addresses) > server.BatchMaxSize {
 		return "", invalidInputError(fmt.Sprintf("IP batch exceeds maximum of %d addresses", server.BatchMaxSize))
-	} else if len(deduplicatedValues) == 1 {
-		queryString = queryString + fmt.Sprintf("%s\n", deduplicatedValues[0])
+	} else if len(addresses) == 1 {
+		queryString = queryString + fmt.Sprintf("%s\n", addresses[0])
 		return queryString, nil
 	}
 	queryString = queryString + BatchStart
-	for _, value := range deduplicatedValues {
+	for _, value := range addresses {
 		queryString = queryString + fmt.Sprintf("%s\n", value)
 	}
 	queryString = queryString + BatchEnd
 	return queryString, nil
 }
 
+// IPLookupStatus describes the outcome for one requested address.
+type IPLookupStatus string
+
+const (
+	// IPLookupAnswered means the server returned a record for the address.
+	IPLookupAnswered IPLookupStatus = "answered"
+	// IPLookupNoAnswer means the query succeeded but the server returned no
+	// record for the address.
+	IPLookupNoAnswer IPLookupStatus = "no_answer"
+	// IPLookupFailed means the address was invalid or its query failed. Err
+	// holds the classified reason.
+	IPLookupFailed IPLookupStatus = "failed"
+)
+
+// IPLookupResult reports the outcome for one requested address.
+type IPLookupResult struct {
+	// Input is the address as the caller supplied it. When several spellings
+	// share a canonical address, IPBatchResult uses the first one.
+	Input string
+	// Addr is the canonical form of Input. It is the zero Addr for inputs
+	// that could not be parsed.
+	Addr   netip.Addr
+	Status IPLookupStatus
+	Record WhoIs
+	// Err is set when Status is IPLookupFailed and uses the stable error
+	// classes returned by LookupIP.
+	Err error
+}
+
+// IPBatchResult correlates the records returned for one IP query with the
+// addresses it requested.
+type IPBatchResult struct {
+	// Validation accounts for every caller input, including those that were
+	// not sent.
+	Validation IPValidation
+	// Requested lists the canonical addresses in query order.
+	Requested []netip.Addr
+	// Results has exactly one entry for every address in Requested.
+	Results map[netip.Addr]IPLookupResult
+	// Unrequested holds records whose IP is not a requested address.
+	Unrequested []WhoIs
+}
+
+// correlateIPRecords matches records to requested addresses by canonical
+// address, so textual differences such as IPv6 case, zero compression, or
+// IPv4 mapping do not hide an answer. The first record for an address wins.
+func correlateIPRecords(validation IPValidation, records []WhoIs) IPBatchResult {
+	batch := IPBatchResult{
+		Validation: validation,
+		Requested:  make([]netip.Addr, 0, len(validation.Accepted)),
+		Results:    make(map[netip.Addr]IPLookupResult, len(validation.Accepted)),
+	}
+	for _, input := range validation.Accepted {
+		batch.Requested = append(batch.Requested, input.Addr)
+		batch.Results[input.Addr] = IPLookupResult{Input: input.Value, Addr: input.Addr, Status: IPLookupNoAnswer}
+	}
+
+	for _, record := range records {
+		address := record.IPAddr
+		if !address.IsValid() {
+			// Records not produced by the parser may carry only IP.
+			parsed, err := netip.ParseAddr(record.IP)
+			if err == nil {
+				address = canonicalIP(parsed)
+			}
+		}
+		result, requested := batch.Results[address]
+		if !requested {
+			batch.Unrequested = append(batch.Unrequested, record)
+			continue
+		}
+		if result.Status == IPLookupAnswered {
+			continue
+		}
+		result.Status = IPLookupAnswered
+		result.Record = record
+		batch.Results[address] = result
+	}
+	return batch
+}
+
 // Parse response string into slice of WhoIs records.
 func parseIpResponse(response string) ([]WhoIs, error) {
 	records, err := parseIPResponseData(response)
@@ -95,65 +326,92 @@ func parseIPResponseData(response string) ([]WhoIs, error) {
 		if len(record) == 0 {
 			continue
 		}
-		responseMap := make(map[string]string)
-		lines := strings.Split(record, "\n")
-		for lineIndex, line := range lines {
-			if len(line) == 0 {
-				continue
-			}
-			key, value, err := parseResponseLine(line)
-			if err != nil {
-				return nil, fmt.Errorf("parse IP response record %d line %d: %w", recordIndex+1, lineIndex+1, err)
-			}
-			responseMap[key] = value
-		}
-		if len(responseMap) == 0 {
-			continue
-		}
-
-		latitudeFloat, err := parseResponseFloat64("Latitude", responseMap["Latitude"])
+		whoIsParsedStruct, ok, err := parseIPRecord(recordIndex+1, strings.Split(record, "\n"))
 		if err != nil {
-			return nil, fmt.Errorf("parse IP response record %d: %w", recordIndex+1, err)
+			return nil, err
 		}
-		longitudeFloat, err := parseResponseFloat64("Longitude", responseMap["Longitude"])
-		if err != nil {
-			return nil, fmt.Errorf("parse IP response record %d: %w", recordIndex+1, err)
-		}
-		cacheDate, err := parseResponseTime("Cache-Date", responseMap["Cache-Date"], "Jan 02 2006 15:04:05")
-		if err != nil {
-			return nil, fmt.Errorf("parse IP response record %d: %w", recordIndex+1, err)
+		if ok {
+			responseWhoIs = append(responseWhoIs, whoIsParsedStruct)
 		}
-		routeOriginatedDate, err := parseResponseTime("Route-Originated-Date", responseMap["Route-Originated-Date"], "Jan 02 2006 15:04:05")
-		if err != nil {
-			return nil, fmt.Errorf("parse IP response record %d: %w", recordIndex+1, err)
+	}
+	if len(responseWhoIs) == 0 {
+		return nil, noRecordsError("IP lookup")
+	}
+	return responseWhoIs, nil
+}
+
+// parseIPRecord parses the lines of one blank-line separated IP record. It
+// reports false for a record with no fields. The batch and streaming parsers
+// share it so both accept and reject the same records.
+func parseIPRecord(recordNumber int, lines []string) (WhoIs, bool, error) {
+	responseMap := make(map[string]string)
+	for lineIndex, line := range lines {
+		if len(line) == 0 {
+			continue
 		}
-		routeOriginatedTS, err := parseResponseInt64("Route-Originated-TS", responseMap["Route-Originated-TS"])
+		key, value, err := parseResponseLine(line)
 		if err != nil {
-			return nil, fmt.Errorf("parse IP response record %d: %w", recordIndex+1, err)
+			return WhoIs{}, false, fmt.Errorf("parse IP response record %d line %d: %w", recordNumber, lineIndex+1, err)
 		}
+		responseMap[key] = value
+	}
+	if len(responseMap) == 0 {
+		return WhoIs{}, false, nil
+	}
 
-		var whoIsParsedStruct WhoIs
-		whoIsParsedStruct.IP = responseMap["IP"]
-		whoIsParsedStruct.OriginAS = responseMap["Origin-AS"]
-		whoIsParsedStruct.AsnPath = responseMap["AS-Path"]
-		whoIsParsedStruct.AsnOrgName = responseMap["AS-Org-Name"]
-		whoIsParsedStruct.OrgName = responseMap["Org-Name"]
-		whoIsParsedStruct.NetworkName = responseMap["Net-Name"]
-		whoIsParsedStruct.CacheDate = cacheDate
-		whoIsParsedStruct.Latitude = latitudeFloat
-		whoIsParsedStruct.Longitude = longitudeFloat
-		whoIsParsedStruct.City = responseMap["City"]
-		whoIsParsedStruct.Region = responseMap["Region"]
-		whoIsParsedStruct.Country = responseMap["Country"]
-		whoIsParsedStruct.CountryCode = responseMap["Country-Code"]
-		whoIsParsedStruct.RouteOriginatedDate = routeOriginatedDate
-		whoIsParsedStruct.RouteOriginatedTS = routeOriginatedTS
-		responseWhoIs = append(responseWhoIs, whoIsParsedStruct)
+	latitudeFloat, err := parseResponseFloat64("Latitude", responseMap["Latitude"])
+	if err != nil {
+		return WhoIs{}, false, fmt.Errorf("parse IP response record %d: %w", recordNumber, err)
 	}
-	if len(responseWhoIs) == 0 {
-		return nil, noRecordsError("IP lookup")
+	longitudeFloat, err := parseResponseFloat64("Longitude", responseMap["Longitude"])
+	if err != nil {
+		return WhoIs{}, false, fmt.Errorf("parse IP response record %d: %w", recordNumber, err)
 	}
-	return responseWhoIs, nil
+	cacheDate, err := parseResponseTime("Cache-Date", responseMap["Cache-Date"], "Jan 02 2006 15:04:05")
+	if err != nil {
+		return WhoIs{}, false, fmt.Errorf("parse IP response record %d: %w", recordNumber, err)
+	}
+	routeOriginatedDate, err := parseResponseTime("Route-Originated-Date", responseMap["Route-Originated-Date"], "Jan 02 2006 15:04:05")
+	if err != nil {
+		return WhoIs{}, false, fmt.Errorf("parse IP response record %d: %w", recordNumber, err)
+	}
+	routeOriginatedTS, err := parseResponseInt64("Route-Originated-TS", responseMap["Route-Originated-TS"])
+	if err != nil {
+		return WhoIs{}, false, fmt.Errorf("parse IP response record %d: %w", recordNumber, err)
+	}
+	ipAddr, err := parseResponseAddr("IP", responseMap["IP"])
+	if err != nil {
+		return WhoIs{}, false, fmt.Errorf("parse IP response record %d: %w", recordNumber, err)
+	}
+	prefixNet, err := parseResponsePrefix("Prefix", responseMap["Prefix"])
+	if err != nil {
+		return WhoIs{}, false, fmt.Errorf("parse IP response record %d: %w", recordNumber, err)
+	}
+	// An AS-Path the strict parser rejects still leaves the record usable, so
+	// it keeps AsnPath and leaves ASPath nil rather than failing the lookup.
+	asPath, _ := parseASPathSegments(responseMap["AS-Path"])
+
+	var whoIsParsedStruct WhoIs
+	whoIsParsedStruct.IP = responseMap["IP"]
+	whoIsParsedStruct.IPAddr = ipAddr
+	whoIsParsedStruct.OriginAS = responseMap["Origin-AS"]
+	whoIsParsedStruct.Prefix = responseMap["Prefix"]
+	whoIsParsedStruct.PrefixNet = prefixNet
+	whoIsParsedStruct.AsnPath = responseMap["AS-Path"]
+	whoIsParsedStruct.ASPath = asPath
+	whoIsParsedStruct.AsnOrgName = responseMap["AS-Org-Name"]
+	whoIsParsedStruct.OrgName = responseMap["Org-Name"]
+	whoIsParsedStruct.NetworkName = responseMap["Net-Name"]
+	whoIsParsedStruct.CacheDate = cacheDate
+	whoIsParsedStruct.Latitude = latitudeFloat
+	whoIsParsedStruct.Longitude = longitudeFloat
+	whoIsParsedStruct.City = responseMap["City"]
+	whoIsParsedStruct.Region = responseMap["Region"]
+	whoIsParsedStruct.Country = responseMap["Country"]
+	whoIsParsedStruct.CountryCode = responseMap["Country-Code"]
+	whoIsParsedStruct.RouteOriginatedDate = routeOriginatedDate
+	whoIsParsedStruct.RouteOriginatedTS = routeOriginatedTS
+	return whoIsParsedStruct, true, nil
 }
 
 /*
@@ -167,19 +425,23 @@ args:
 */
 func (server WhoisServer) LookupIP(query string, c chan IpLookupResponse) {
 
-	var Answer []WhoIs
-
 	response, err := server.executeQuery("lookup IP", query)
 	if err != nil {
-		c <- IpLookupResponse{Answer, err}
+		c <- IpLookupResponse{nil, err}
 		return
 	}
 
 	// Parse the query response into our response and return
-	Answer, err = parseIpResponse(response)
+	Answer, err := server.parseIPLookup(response)
+	c <- IpLookupResponse{Answer, err}
+}
+
+// parseIPLookup parses a complete IP lookup response and attributes parser
+// failures to server.
+func (server WhoisServer) parseIPLookup(response string) ([]WhoIs, error) {
+	records, err := parseIpResponse(response)
 	if err != nil {
-		c <- IpLookupResponse{Answer, server.operationError("lookup IP", err)}
-		return
+		return nil, server.operationError("lookup IP", err)
 	}
-	c <- IpLookupResponse{Answer, nil}
+	return records, nil
 }
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"math/rand"
 	"net"
+	"net/netip"
+	"reflect"
 	"testing"
 )
 
@@ -79,3 +81,132 @@ func TestFormatIpQuery(t *testing.T) {
 		})
 	}
 }
+
+// Test that every IP input is accounted for
+func TestValidateIPsAccountsForEveryInput(t *testing.T) {
+	values := []string{"192.0.2.1", "dolly.bean", "fe80::1%eth0", "2001:db8::1", "192.0.2.1", "", "2001:db8::1"}
+	validation := ValidateIPs(values)
+
+	ipv4 := netip.MustParseAddr("192.0.2.1")
+	ipv6 := netip.MustParseAddr("2001:db8::1")
+	wantAccepted := []IPInput{
+		{Index: 0, Value: "192.0.2.1", Addr: ipv4},
+		{Index: 3, Value: "2001:db8::1", Addr: ipv6},
+	}
+	wantDuplicates := []IPInput{
+		{Index: 4, Value: "192.0.2.1", Addr: ipv4, Reason: IPInputDuplicate, DuplicateOf: 0},
+		{Index: 6, Value: "2001:db8::1", Addr: ipv6, Reason: IPInputDuplicate, DuplicateOf: 3},
+	}
+	wantRejected := []IPInput{
+		{Index: 1, Value: "dolly.bean", Reason: IPInputUnparseable},
+		{Index: 2, Value: "fe80::1%eth0", Reason: IPInputZoned},
+		{Index: 5, Value: "", Reason: IPInputUnparseable},
+	}
+	if !reflect.DeepEqual(validation.Accepted, wantAccepted) {
+		t.Errorf("accepted = %+v, want %+v", validation.Accepted, wantAccepted)
+	}
+	if !reflect.DeepEqual(validation.Duplicates, wantDuplicates) {
+		t.Errorf("duplicates = %+v, want %+v", validation.Duplicates, wantDuplicates)
+	}
+	if !reflect.DeepEqual(validation.Rejected, wantRejected) {
+		t.Errorf("rejected = %+v, want %+v", validation.Rejected, wantRejected)
+	}
+	if got := len(validation.Accepted) + len(validation.Duplicates) + len(validation.Rejected); got != len(values) {
+		t.Errorf("accounted inputs = %d, want %d", got, len(values))
+	}
+}
+
+// Test that equivalent spellings collapse to one canonical address
+func TestValidateIPsCanonicalizesSpellings(t *testing.T) {
+	values := []string{"::ffff:192.0.2.1", "2001:DB8:0:0:0:0:0:1", "192.0.2.1", "2001:db8::1", "::FFFF:192.0.2.1"}
+	validation := ValidateIPs(values)
+
+	if got, want := validation.Addresses(), []string{"192.0.2.1", "2001:db8::1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("addresses = %v, want %v", got, want)
+	}
+	if len(validation.Duplicates) != 3 || validation.Duplicates[0].DuplicateOf != 0 || validation.Duplicates[1].DuplicateOf != 1 {
+		t.Fatalf("duplicates = %+v, want three repeats of inputs 0 and 1", validation.Duplicates)
+	}
+	wantSpellings := map[netip.Addr][]string{
+		netip.MustParseAddr("192.0.2.1"):   {"::ffff:192.0.2.1", "192.0.2.1", "::FFFF:192.0.2.1"},
+		netip.MustParseAddr("2001:db8::1"): {"2001:DB8:0:0:0:0:0:1", "2001:db8::1"},
+	}
+	if got := validation.Spellings(); !reflect.DeepEqual(got, wantSpellings) {
+		t.Errorf("spellings = %v, want %v", got, wantSpellings)
+	}
+
+	server := new(WhoisServer)
+	server.SetDefaultValues()
+	query, err := server.FormatIpQuery(values)
+	if err != nil {
+		t.Fatalf("format query: %v", err)
+	}
+	if want := "app=\"GO pwhois Module\"\nbegin\n192.0.2.1\n2001:db8::1\nend\n"; query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+}
+
+// Test strict and lenient IP query validation
+func TestFormatIpQueryWithValidation(t *testing.T) {
+	server := new(WhoisServer)
+	server.SetDefaultValues()
+	values := []string{"8.8.8.8", "8.8.8.8.8", "1.1.1.1"}
+
+	query, validation, err := server.FormatIpQueryWithValidation(values, IPQueryOptions{})
+	if err != nil {
+		t.Fatalf("lenient query: %v", err)
+	}
+	if want := "app=\"GO pwhois Module\"\nbegin\n8.8.8.8\n1.1.1.1\nend\n"; query != want {
+		t.Errorf("lenient query = %q, want %q", query, want)
+	}
+	if len(validation.Rejected) != 1 || validation.Rejected[0].Index != 1 {
+		t.Errorf("lenient rejected = %+v, want input 1", validation.Rejected)
+	}
+
+	query, validation, err = server.FormatIpQueryWithValidation(values, IPQueryOptions{Strict: true})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("strict error = %v, want ErrInvalidInput", err)
+	}
+	var validationError *IPValidationError
+	if !errors.As(err, &validationError) || len(validationError.Rejected) != 1 || validationError.Rejected[0].Reason != IPInputUnparseable {
+		t.Fatalf("strict error = %#v, want one unparseable rejection", err)
+	}
+	if query != "" || len(validation.Accepted) != 2 {
+		t.Errorf("strict query = %q, accepted = %+v", query, validation.Accepted)
+	}
+
+	if _, _, err := server.FormatIpQueryWithValidation([]string{"8.8.8.8", "8.8.8.8"}, IPQueryOptions{Strict: true}); err != nil {
+		t.Errorf("strict query with duplicates: %v", err)
+	}
+}
+
+// Test correlating batch records to requested addresses
+func TestCorrelateIPRecordsMatchesTextualForms(t *testing.T) {
+	validation := ValidateIPs([]string{"2001:DB8::1", "192.0.2.1", "192.0.2.2", "2001:db8::1"})
+	records := []WhoIs{
+		{IP: "2001:db8:0:0:0:0:0:1", OriginAS: "64500"},
+		{IP: "192.0.2.1", OriginAS: "64501"},
+		{IP: "192.0.2.1", OriginAS: "64599"},
+		{IP: "198.51.100.7", OriginAS: "64502"},
+		{IP: "not an address"},
+	}
+
+	batch := correlateIPRecords(validation, records)
+	ipv6 := netip.MustParseAddr("2001:db8::1")
+	wantRequested := []netip.Addr{ipv6, netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")}
+	if !reflect.DeepEqual(batch.Requested, wantRequested) {
+		t.Fatalf("requested = %v, want %v", batch.Requested, wantRequested)
+	}
+	if result := batch.Results[ipv6]; result.Status != IPLookupAnswered || result.Input != "2001:DB8::1" || result.Record.OriginAS != "64500" {
+		t.Errorf("IPv6 result = %+v, want answered by compressed form", result)
+	}
+	if result := batch.Results[netip.MustParseAddr("192.0.2.1")]; result.Record.OriginAS != "64501" {
+		t.Errorf("duplicate record replaced first answer: %+v", result)
+	}
+	if result := batch.Results[netip.MustParseAddr("192.0.2.2")]; result.Status != IPLookupNoAnswer {
+		t.Errorf("unanswered result = %+v, want no answer", result)
+	}
+	if len(batch.Unrequested) != 2 {
+		t.Errorf("unrequested = %+v, want two records", batch.Unrequested)
+	}
+}
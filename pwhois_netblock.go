@@ -2,6 +2,7 @@ package pwhois
 
 import (
 	"fmt"
+	"net/netip"
 	"strings"
 	"time"
 )
@@ -35,6 +36,10 @@ type Netblock struct {
 	CreateDate   time.Time `json:"create_date"`
 	ModifyDate   time.Time `json:"modify_date"`
 	Source       string    `json:"source"`
+
+	// RangeStart and RangeEnd are the parsed, canonicalized bounds of Range.
+	RangeStart netip.Addr `json:"-"`
+	RangeEnd   netip.Addr `json:"-"`
 }
 
 /*
@@ -71,13 +76,14 @@ func getNetblockSections(response string) ([]string, []string, error) {
 		if len(line) == 0 {
 			continue
 		}
-		if strings.Contains(line, ": ") {
-			// If header type line add to header
-			header = append(header, line)
-		} else if strings.HasPrefix(line, "*>") {
-			// If block type line add to blocks
+		if strings.HasPrefix(line, "*>") {
+			// If block type line add to blocks. Checked first because IPv6
+			// ranges such as "2001:db8:: - ..." also contain ": ".
 			temp := strings.TrimPrefix(line, "*>")
 			blocks = append(blocks, temp)
+		} else if strings.Contains(line, ": ") {
+			// If header type line add to header
+			header = append(header, line)
 		}
 	}
 
@@ -103,7 +109,6 @@ func parseNetblockResponse(asn string, response string) ([]NetblockRecord, error
 
 func parseNetblockResponseData(asn string, response string) ([]NetblockRecord, error) {
 	var responseNetblockRecords []NetblockRecord
-	var responseRecord NetblockRecord
 	var blocks []Netblock
 	responseMap := make(map[string]string)
 
@@ -136,13 +141,41 @@ func parseNetblockResponseData(asn string, response string) ([]NetblockRecord, e
 		responseMap[key] = value
 	}
 
+	responseRecord, err := parseNetblockHeader(asn, responseMap)
+	if err != nil {
+		return nil, err
+	}
+
+	// Process blockStrings
+
+	for blockIndex, line := range blockStrings {
+		if len(line) == 0 {
+			continue
+		}
+		block, err := parseNetblockBlock(blockIndex+1, line)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	responseRecord.Netblocks = blocks
+	responseNetblockRecords = append(responseNetblockRecords, responseRecord)
+
+	return responseNetblockRecords, nil
+}
+
+// parseNetblockHeader builds the netblock record, without blocks, from its
+// "Key: value" header fields.
+func parseNetblockHeader(asn string, responseMap map[string]string) (NetblockRecord, error) {
+	var responseRecord NetblockRecord
+
 	asValue, err := parseResponseInt64("AS", responseMap["AS"])
 	if err != nil {
-		return nil, fmt.Errorf("parse netblock header: %w", err)
+		return NetblockRecord{}, fmt.Errorf("parse netblock header: %w", err)
 	}
 	orgValue, err := parseResponseInt64("Org", responseMap["Org"])
 	if err != nil {
-		return nil, fmt.Errorf("parse netblock header: %w", err)
+		return NetblockRecord{}, fmt.Errorf("parse netblock header: %w", err)
 	}
 	responseRecord.Asn = asn
 	responseRecord.OriginAs = responseMap["AS"]
@@ -153,54 +186,123 @@ func parseNetblockResponseData(asn string, response string) ([]NetblockRecord, e
 	responseRecord.OrgName = responseMap["Org-Name"]
 	responseRecord.OrgSource = responseMap["Org-Source"]
 	responseRecord.OriginAs = responseMap["Origin-AS"]
+	return responseRecord, nil
+}
 
-	// Process blockStrings
+// parseNetblockBlock parses one block line with its "*>" marker removed.
+func parseNetblockBlock(blockNumber int, line string) (Netblock, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 23 {
+		return Netblock{}, fmt.Errorf("parse netblock record %d: expected at least 23 fields", blockNumber)
+	}
+	networkRange := fmt.Sprintf("%v-%v", fields[0], fields[2])
+	rangeStart, rangeEnd, err := parseNetblockRange(fields[0], fields[2])
+	if err != nil {
+		return Netblock{}, fmt.Errorf("parse netblock record %d: %w", blockNumber, err)
+	}
+	networkName := fields[4]
+	networkType := fields[6]
+	registerDate, err := parseResponseTime("Register-Date", fields[8], "2006-01-02")
+	if err != nil {
+		return Netblock{}, fmt.Errorf("parse netblock record %d: %w", blockNumber, err)
+	}
+	updateDate, err := parseResponseTime("Update-Date", fields[10], "2006-01-02")
+	if err != nil {
+		return Netblock{}, fmt.Errorf("parse netblock record %d: %w", blockNumber, err)
+	}
+	createDate, err := parseResponseTime("Create-Date", fmt.Sprintf("%s %s %s %s", fields[12], fields[13], fields[14], fields[15]), "Jan 02 2006 15:04:05")
+	if err != nil {
+		return Netblock{}, fmt.Errorf("parse netblock record %d: %w", blockNumber, err)
+	}
+	modifyDate, err := parseResponseTime("Modify-Date", fmt.Sprintf("%s %s %s %s", fields[17], fields[18], fields[19], fields[20]), "Jan 02 2006 15:04:05")
+	if err != nil {
+		return Netblock{}, fmt.Errorf("parse netblock record %d: %w", blockNumber, err)
+	}
+	source := fields[22]
+
+	block := Netblock{
+		Name:         networkName,
+		Type:         networkType,
+		Range:        networkRange,
+		RegisterDate: registerDate,
+		UpdateDate:   updateDate,
+		CreateDate:   createDate,
+		ModifyDate:   modifyDate,
+		Source:       source,
+		RangeStart:   rangeStart,
+		RangeEnd:     rangeEnd,
+	}
+	return block, nil
+}
 
-	for blockIndex, line := range blockStrings {
-		if len(line) == 0 {
-			continue
-		}
-		fields := strings.Fields(line)
-		if len(fields) < 23 {
-			return nil, fmt.Errorf("parse netblock record %d: expected at least 23 fields", blockIndex+1)
-		}
-		networkRange := fmt.Sprintf("%v-%v", fields[0], fields[2])
-		networkName := fields[4]
-		networkType := fields[6]
-		registerDate, err := parseResponseTime("Register-Date", fields[8], "2006-01-02")
-		if err != nil {
-			return nil, fmt.Errorf("parse netblock record %d: %w", blockIndex+1, err)
-		}
-		updateDate, err := parseResponseTime("Update-Date", fields[10], "2006-01-02")
-		if err != nil {
-			return nil, fmt.Errorf("parse netblock record %d: %w", blockIndex+1, err)
+// parseNetblockRange parses the bounds of a netblock range. Both bounds must
+// be the same address family and in ascending order.
+func parseNetblockRange(start, end string) (netip.Addr, netip.Addr, error) {
+	rangeStart, err := parseResponseAddr("Range-Start", start)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, err
+	}
+	rangeEnd, err := parseResponseAddr("Range-End", end)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, err
+	}
+	if rangeStart.BitLen() != rangeEnd.BitLen() || rangeEnd.Less(rangeStart) {
+		return netip.Addr{}, netip.Addr{}, invalidResponseValue("Range", fmt.Errorf("range %s - %s is not ascending within one address family", start, end))
+	}
+	return rangeStart, rangeEnd, nil
+}
+
+// Prefixes returns the smallest set of CIDR prefixes that exactly covers the
+// block from RangeStart to RangeEnd, in ascending order. When RangeStart is
+// unset, as in a block decoded from JSON, the bounds are parsed from Range.
+// It returns nil when the bounds are not valid addresses of one family in
+// ascending order.
+func (block Netblock) Prefixes() []netip.Prefix {
+	start, end := block.RangeStart, block.RangeEnd
+	if !start.IsValid() {
+		textStart, textEnd, ok := strings.Cut(block.Range, "-")
+		if !ok {
+			return nil
 		}
-		createDate, err := parseResponseTime("Create-Date", fmt.Sprintf("%s %s %s %s", fields[12], fields[13], fields[14], fields[15]), "Jan 02 2006 15:04:05")
-		if err != nil {
-			return nil, fmt.Errorf("parse netblock record %d: %w", blockIndex+1, err)
+		var err error
+		if start, end, err = parseNetblockRange(strings.TrimSpace(textStart), strings.TrimSpace(textEnd)); err != nil {
+			return nil
 		}
-		modifyDate, err := parseResponseTime("Modify-Date", fmt.Sprintf("%s %s %s %s", fields[17], fields[18], fields[19], fields[20]), "Jan 02 2006 15:04:05")
-		if err != nil {
-			return nil, fmt.Errorf("parse netblock record %d: %w", blockIndex+1, err)
+	}
+	start, end = start.Unmap(), end.Unmap()
+	if !start.IsValid() || start.BitLen() != end.BitLen() || end.Less(start) {
+		return nil
+	}
+
+	var prefixes []netip.Prefix
+	for {
+		// Widen the prefix at start while it stays aligned and within end.
+		bits := start.BitLen()
+		for bits > 0 {
+			wider := netip.PrefixFrom(start, bits-1).Masked()
+			if wider.Addr() != start || lastPrefixAddr(wider).Compare(end) > 0 {
+				break
+			}
+			bits--
 		}
-		source := fields[22]
-
-		block := Netblock{
-			Name:         networkName,
-			Type:         networkType,
-			Range:        networkRange,
-			RegisterDate: registerDate,
-			UpdateDate:   updateDate,
-			CreateDate:   createDate,
-			ModifyDate:   modifyDate,
-			Source:       source,
+		prefix := netip.PrefixFrom(start, bits)
+		prefixes = append(prefixes, prefix)
+		last := lastPrefixAddr(prefix)
+		if last == end {
+			return prefixes
 		}
-		blocks = append(blocks, block)
+		start = last.Next()
 	}
-	responseRecord.Netblocks = blocks
-	responseNetblockRecords = append(responseNetblockRecords, responseRecord)
+}
 
-	return responseNetblockRecords, nil
+// lastPrefixAddr returns the highest address of a masked prefix.
+func lastPrefixAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(bytes)*8; bit++ {
+		bytes[bit/8] |= 0x80 >> (bit % 8)
+	}
+	last, _ := netip.AddrFromSlice(bytes)
+	return last
 }
 
 /*
@@ -216,24 +318,27 @@ args:
 */
 func (server WhoisServer) LookupNetblock(asn string, query string, c chan NetblockLookupResponse) {
 
-	var Answer NetblockRecord
-
 	response, err := server.executeQuery("lookup netblock", query)
 	if err != nil {
-		c <- NetblockLookupResponse{Answer, err}
+		c <- NetblockLookupResponse{NetblockRecord{}, err}
 		return
 	}
 
 	// Parse respose string and return results
+	Answer, err := server.parseNetblockLookup(asn, response)
+	c <- NetblockLookupResponse{Answer, err}
+}
+
+// parseNetblockLookup parses a complete netblock response into its single
+// netblock record.
+func (server WhoisServer) parseNetblockLookup(asn string, response string) (NetblockRecord, error) {
 	netblock, err := parseNetblockResponse(asn, response)
 	if err != nil {
-		c <- NetblockLookupResponse{Answer, server.operationError("lookup netblock", err)}
-		return
+		return NetblockRecord{}, server.operationError("lookup netblock", err)
 	}
 	if len(netblock) == 0 {
-		c <- NetblockLookupResponse{Answer, server.operationError("lookup netblock", noRecordsError("netblock lookup"))}
-		return
+		return NetblockRecord{}, server.operationError("lookup netblock", noRecordsError("netblock lookup"))
 	}
 
-	c <- NetblockLookupResponse{netblock[0], nil}
+	return netblock[0], nil
 }
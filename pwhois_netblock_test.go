@@ -2,6 +2,8 @@ package pwhois
 
 import (
 	"errors"
+	"net/netip"
+	"reflect"
 	"testing"
 )
 
@@ -52,3 +54,45 @@ func TestFormatNetblockQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestNetblockPrefixes(t *testing.T) {
+	cases := []struct {
+		start, end string
+		want       []string
+	}{
+		{start: "192.0.2.0", end: "192.0.2.255", want: []string{"192.0.2.0/24"}},
+		{start: "192.0.2.5", end: "192.0.2.5", want: []string{"192.0.2.5/32"}},
+		{start: "192.0.2.1", end: "192.0.2.10", want: []string{"192.0.2.1/32", "192.0.2.2/31", "192.0.2.4/30", "192.0.2.8/31", "192.0.2.10/32"}},
+		{start: "198.51.100.0", end: "198.51.101.127", want: []string{"198.51.100.0/24", "198.51.101.0/25"}},
+		{start: "0.0.0.0", end: "255.255.255.255", want: []string{"0.0.0.0/0"}},
+		{start: "255.255.255.254", end: "255.255.255.255", want: []string{"255.255.255.254/31"}},
+		{start: "2001:db8::", end: "2001:db8::ffff", want: []string{"2001:db8::/112"}},
+		{start: "2001:db8::1", end: "2001:db8::2", want: []string{"2001:db8::1/128", "2001:db8::2/128"}},
+		{start: "192.0.2.10", end: "192.0.2.1"},
+		{start: "192.0.2.0", end: "2001:db8::"},
+	}
+
+	for _, c := range cases {
+		block := Netblock{RangeStart: netip.MustParseAddr(c.start), RangeEnd: netip.MustParseAddr(c.end)}
+		var got []string
+		for _, prefix := range block.Prefixes() {
+			got = append(got, prefix.String())
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Prefixes(%s - %s) = %q, want %q", c.start, c.end, got, c.want)
+		}
+	}
+	if prefixes := (Netblock{}).Prefixes(); prefixes != nil {
+		t.Errorf("Prefixes of an empty netblock = %v, want nil", prefixes)
+	}
+
+	for value, want := range map[string][]netip.Prefix{
+		"192.0.2.0 - 192.0.2.255": {netip.MustParsePrefix("192.0.2.0/24")},
+		"192.0.2.255-192.0.2.0":   nil,
+		"192.0.2.0":               nil,
+	} {
+		if got := (Netblock{Range: value}).Prefixes(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Prefixes of Range %q = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package pwhois
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net"
+	"net/netip"
 	"os"
 	"strings"
 	"testing"
@@ -232,6 +234,88 @@ func TestParseNetblockResponseRejectsMalformedBlocks(t *testing.T) {
 	}
 }
 
+func TestParsersFillTypedAddressFields(t *testing.T) {
+	records, err := parseIpResponse("IP: ::ffff:192.0.2.1\nPrefix: 192.0.2.0/24\n\nIP: 2001:DB8::1\n")
+	if err != nil {
+		t.Fatalf("parse IP response: %v", err)
+	}
+	if records[0].IPAddr != netip.MustParseAddr("192.0.2.1") || records[0].PrefixNet != netip.MustParsePrefix("192.0.2.0/24") {
+		t.Errorf("first record typed fields = %v %v", records[0].IPAddr, records[0].PrefixNet)
+	}
+	if records[0].IP != "::ffff:192.0.2.1" || records[0].Prefix != "192.0.2.0/24" {
+		t.Errorf("first record text fields = %q %q", records[0].IP, records[0].Prefix)
+	}
+	if records[1].IPAddr != netip.MustParseAddr("2001:db8::1") || records[1].PrefixNet.IsValid() {
+		t.Errorf("second record typed fields = %v %v", records[1].IPAddr, records[1].PrefixNet)
+	}
+
+	routes, err := parseBgpResponse("*> 4.0.0.0/9 | Jul 18 2026 00:00:04 | Jul 18 2026 00:00:04 | May 28 2026 06:56:01 | 208.115.137.35 | 8220 1299 3356")
+	if err != nil {
+		t.Fatalf("parse route response: %v", err)
+	}
+	if routes[0].PrefixNet != netip.MustParsePrefix("4.0.0.0/9") || routes[0].NextHopAddr != netip.MustParseAddr("208.115.137.35") {
+		t.Errorf("route typed fields = %v %v", routes[0].PrefixNet, routes[0].NextHopAddr)
+	}
+
+	netblocks, err := parseNetblockResponse("64500", "Origin-AS: 64500\n*> 2001:db8:: - 2001:db8::ffff | EXAMPLE-NET | reassignment | 2019-05-25 | 2019-09-25 | Jun 28 2019 16:53:01 | Jul 18 2026 03:19:32 | TEST")
+	if err != nil {
+		t.Fatalf("parse netblock response: %v", err)
+	}
+	block := netblocks[0].Netblocks[0]
+	if block.RangeStart != netip.MustParseAddr("2001:db8::") || block.RangeEnd != netip.MustParseAddr("2001:db8::ffff") {
+		t.Errorf("netblock range = %v - %v", block.RangeStart, block.RangeEnd)
+	}
+
+	encoded, err := json.Marshal(block)
+	if err != nil {
+		t.Fatalf("marshal netblock: %v", err)
+	}
+	if !strings.Contains(string(encoded), `"net_range":"2001:db8::-2001:db8::ffff"`) {
+		t.Errorf("netblock JSON = %s, want unchanged net_range text", encoded)
+	}
+}
+
+func TestParsersRejectMalformedAddresses(t *testing.T) {
+	route := "*> 4.0.0.0/9 | Jul 18 2026 00:00:04 | Jul 18 2026 00:00:04 | May 28 2026 06:56:01 | 208.115.137.35 | 8220 1299 3356"
+	block := "Origin-AS: 64500\n*> 192.0.2.0 - 192.0.2.255 | EXAMPLE-NET | reassignment | 2019-05-25 | 2019-09-25 | Jun 28 2019 16:53:01 | Jul 18 2026 03:19:32 | TEST"
+	tests := []struct {
+		name  string
+		parse func() error
+	}{
+		{name: "IP", parse: func() error { _, err := parseIpResponse("IP: 192.0.2.300"); return err }},
+		{name: "zoned IP", parse: func() error { _, err := parseIpResponse("IP: fe80::1%eth0"); return err }},
+		{name: "IP prefix", parse: func() error { _, err := parseIpResponse("IP: 192.0.2.1\nPrefix: 192.0.2.0/33"); return err }},
+		{name: "route prefix", parse: func() error {
+			_, err := parseBgpResponse(strings.Replace(route, "4.0.0.0/9", "4.0.0.0", 1))
+			return err
+		}},
+		{name: "next hop", parse: func() error {
+			_, err := parseBgpResponse(strings.Replace(route, "208.115.137.35", "next-hop", 1))
+			return err
+		}},
+		{name: "range bound", parse: func() error {
+			_, err := parseNetblockResponse("64500", strings.Replace(block, "192.0.2.255", "192.0.2.256", 1))
+			return err
+		}},
+		{name: "descending range", parse: func() error {
+			_, err := parseNetblockResponse("64500", strings.Replace(block, "192.0.2.0 ", "192.0.3.0 ", 1))
+			return err
+		}},
+		{name: "mixed family range", parse: func() error {
+			_, err := parseNetblockResponse("64500", strings.Replace(block, "192.0.2.255", "2001:db8::ff", 1))
+			return err
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.parse(); !errors.Is(err, ErrMalformedResponse) {
+				t.Fatalf("error = %v, want ErrMalformedResponse", err)
+			}
+		})
+	}
+}
+
 func TestLookupRegistryDoesNotWriteStdout(t *testing.T) {
 	clientConnection, serverConnection := net.Pipe()
 	defer clientConnection.Close()
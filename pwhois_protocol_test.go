@@ -22,12 +22,12 @@ type loopbackProtocolResult struct {
 	err          error
 }
 
-// connectLoopbackProtocolServer starts a one-connection, IPv4 loopback-only
-// PWHOIS test server and connects through WhoisServer.Connect. The server
-// reads the exact scripted request, optionally returns response chunks, sends
-// orderly EOF with CloseWrite, and then verifies that the caller closes its
-// side of the connection.
-func connectLoopbackProtocolServer(t *testing.T, script loopbackProtocolScript) (*WhoisServer, <-chan loopbackProtocolResult) {
+// startLoopbackProtocolServer starts a one-connection, IPv4 loopback-only
+// PWHOIS test server and returns an unconnected WhoisServer configured for
+// it. The server reads the exact scripted request, optionally returns
+// response chunks, sends orderly EOF with CloseWrite, and then verifies that
+// the caller closes its side of the connection.
+func startLoopbackProtocolServer(t *testing.T, script loopbackProtocolScript) (WhoisServer, <-chan loopbackProtocolResult) {
 	t.Helper()
 
 	listener, err := net.Listen("tcp4", "127.0.0.1:0")
@@ -93,16 +93,29 @@ func connectLoopbackProtocolServer(t *testing.T, script loopbackProtocolScript)
 		result.clientClosed = true
 	}()
 
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
 	address := listener.Addr().(*net.TCPAddr)
-	server := &WhoisServer{
+	server := WhoisServer{
 		Server:           address.IP.String(),
 		Port:             address.Port,
 		BatchMaxSize:     500,
 		Timeout:          2 * time.Second,
 		MaxResponseBytes: DefaultMaxResponseBytes,
 	}
+	return server, results
+}
+
+// connectLoopbackProtocolServer starts a scripted loopback server with
+// startLoopbackProtocolServer and connects through WhoisServer.Connect.
+func connectLoopbackProtocolServer(t *testing.T, script loopbackProtocolScript) (*WhoisServer, <-chan loopbackProtocolResult) {
+	t.Helper()
+
+	configured, results := startLoopbackProtocolServer(t, script)
+	server := &configured
 	if err := server.Connect(); err != nil {
-		listener.Close()
 		t.Fatalf("connect to loopback server: %v", err)
 	}
 
@@ -110,17 +123,15 @@ func connectLoopbackProtocolServer(t *testing.T, script loopbackProtocolScript)
 		if server.Connection != nil {
 			_ = server.Connection.Close()
 		}
-		_ = listener.Close()
 	})
 	return server, results
 }
 
-func closeAndVerifyLoopbackProtocol(t *testing.T, server *WhoisServer, results <-chan loopbackProtocolResult, expectedRequest string) {
+// waitForLoopbackProtocol verifies the scripted server result after the
+// caller has already closed its connection.
+func waitForLoopbackProtocol(t *testing.T, results <-chan loopbackProtocolResult, expectedRequest string) {
 	t.Helper()
 
-	if err := server.Connection.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
-		t.Errorf("close loopback client: %v", err)
-	}
 	select {
 	case result := <-results:
 		if result.err != nil {
@@ -137,6 +148,15 @@ func closeAndVerifyLoopbackProtocol(t *testing.T, server *WhoisServer, results <
 	}
 }
 
+func closeAndVerifyLoopbackProtocol(t *testing.T, server *WhoisServer, results <-chan loopbackProtocolResult, expectedRequest string) {
+	t.Helper()
+
+	if err := server.Connection.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		t.Errorf("close loopback client: %v", err)
+	}
+	waitForLoopbackProtocol(t, results, expectedRequest)
+}
+
 func TestLoopbackProtocolSuccessfulLookups(t *testing.T) {
 	const (
 		ipSingleRequest = "app=\"GO pwhois Module\"\n192.0.2.1\n"
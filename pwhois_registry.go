@@ -176,26 +176,26 @@ args:
 */
 func (server WhoisServer) LookupRegistry(asn string, query string, c chan RegistryLookupResponse) {
 
-	var Answer RegistryRecord
-
 	response, err := server.executeQuery("lookup registry", query)
 	if err != nil {
-		c <- RegistryLookupResponse{Answer, err}
+		c <- RegistryLookupResponse{RegistryRecord{}, err}
 		return
 	}
 
+	Answer, err := server.parseRegistryLookup(asn, response)
+	c <- RegistryLookupResponse{Answer, err}
+}
+
+// parseRegistryLookup parses a complete registry response into the first
+// registry record.
+func (server WhoisServer) parseRegistryLookup(asn string, response string) (RegistryRecord, error) {
 	registry, err := parseRegistryResponse(response)
 	if err != nil {
-		c <- RegistryLookupResponse{Answer, server.operationError("lookup registry", err)}
-		return
+		return RegistryRecord{}, server.operationError("lookup registry", err)
 	}
 	if len(registry) == 0 {
-		c <- RegistryLookupResponse{Answer, server.operationError("lookup registry", noRecordsError("registry lookup"))}
-		return
+		return RegistryRecord{}, server.operationError("lookup registry", noRecordsError("registry lookup"))
 	}
 
-	Answer.Asn = asn
-	Answer.Registry = registry[0]
-
-	c <- RegistryLookupResponse{Answer, nil}
+	return RegistryRecord{Asn: asn, Registry: registry[0]}, nil
 }
@@ -2,6 +2,7 @@ package pwhois
 
 import (
 	"fmt"
+	"net/netip"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +16,11 @@ type BGPRoute struct {
 	OriginatedDate time.Time `json:"originated_date"`
 	NextHop        string    `json:"next_hop"`
 	ASPath         []int     `json:"as_path"`
+
+	// PrefixNet is Prefix parsed.
+	PrefixNet netip.Prefix `json:"-"`
+	// NextHopAddr is NextHop parsed and canonicalized.
+	NextHopAddr netip.Addr `json:"-"`
 }
 
 // BGP routeview object
@@ -75,45 +81,66 @@ func parseBGPData(data string) ([]BGPRoute, error) {
 
 	lines := strings.Split(data, "\n")
 	for lineIndex, line := range lines {
-		if !strings.HasPrefix(strings.TrimSpace(line), "*>") {
-			continue
-		}
-		fields := strings.Fields(line)
-		if len(fields) < 21 {
-			return nil, fmt.Errorf("parse route record at line %d: expected at least 21 fields", lineIndex+1)
-		}
-
-		prefix := fields[1]
-		createDate, err := parseResponseTime("Create-Date", fmt.Sprintf("%s %s %s %s", fields[3], fields[4], fields[5], fields[6]), "Jan 02 2006 15:04:05")
+		route, ok, err := parseBGPRouteLine(lineIndex+1, line)
 		if err != nil {
-			return nil, fmt.Errorf("parse route record at line %d: %w", lineIndex+1, err)
+			return nil, err
 		}
-		modifyDate, err := parseResponseTime("Modify-Date", fmt.Sprintf("%s %s %s %s", fields[8], fields[9], fields[10], fields[11]), "Jan 02 2006 15:04:05")
-		if err != nil {
-			return nil, fmt.Errorf("parse route record at line %d: %w", lineIndex+1, err)
-		}
-		originatedDate, err := parseResponseTime("Originated-Date", fmt.Sprintf("%s %s %s %s", fields[13], fields[14], fields[15], fields[16]), "Jan 02 2006 15:04:05")
-		if err != nil {
-			return nil, fmt.Errorf("parse route record at line %d: %w", lineIndex+1, err)
-		}
-		nextHop := fields[18]
-		asPath, err := parseASPath(fields[20:])
-		if err != nil {
-			return nil, fmt.Errorf("parse route record at line %d: %w", lineIndex+1, err)
+		if ok {
+			routes = append(routes, route)
 		}
+	}
+	return routes, nil
+}
 
-		route := BGPRoute{
-			Prefix:         prefix,
-			CreateDate:     createDate,
-			ModifyDate:     modifyDate,
-			OriginatedDate: originatedDate,
-			NextHop:        nextHop,
-			ASPath:         asPath,
-		}
+// parseBGPRouteLine parses one RouteView line. It reports false for lines
+// that are not "*>" route records.
+func parseBGPRouteLine(lineNumber int, line string) (BGPRoute, bool, error) {
+	if !strings.HasPrefix(strings.TrimSpace(line), "*>") {
+		return BGPRoute{}, false, nil
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 21 {
+		return BGPRoute{}, false, fmt.Errorf("parse route record at line %d: expected at least 21 fields", lineNumber)
+	}
 
-		routes = append(routes, route)
+	prefix := fields[1]
+	prefixNet, err := parseResponsePrefix("Prefix", prefix)
+	if err != nil {
+		return BGPRoute{}, false, fmt.Errorf("parse route record at line %d: %w", lineNumber, err)
 	}
-	return routes, nil
+	createDate, err := parseResponseTime("Create-Date", fmt.Sprintf("%s %s %s %s", fields[3], fields[4], fields[5], fields[6]), "Jan 02 2006 15:04:05")
+	if err != nil {
+		return BGPRoute{}, false, fmt.Errorf("parse route record at line %d: %w", lineNumber, err)
+	}
+	modifyDate, err := parseResponseTime("Modify-Date", fmt.Sprintf("%s %s %s %s", fields[8], fields[9], fields[10], fields[11]), "Jan 02 2006 15:04:05")
+	if err != nil {
+		return BGPRoute{}, false, fmt.Errorf("parse route record at line %d: %w", lineNumber, err)
+	}
+	originatedDate, err := parseResponseTime("Originated-Date", fmt.Sprintf("%s %s %s %s", fields[13], fields[14], fields[15], fields[16]), "Jan 02 2006 15:04:05")
+	if err != nil {
+		return BGPRoute{}, false, fmt.Errorf("parse route record at line %d: %w", lineNumber, err)
+	}
+	nextHop := fields[18]
+	nextHopAddr, err := parseResponseAddr("Next-Hop", nextHop)
+	if err != nil {
+		return BGPRoute{}, false, fmt.Errorf("parse route record at line %d: %w", lineNumber, err)
+	}
+	asPath, err := parseASPath(fields[20:])
+	if err != nil {
+		return BGPRoute{}, false, fmt.Errorf("parse route record at line %d: %w", lineNumber, err)
+	}
+
+	route := BGPRoute{
+		Prefix:         prefix,
+		CreateDate:     createDate,
+		ModifyDate:     modifyDate,
+		OriginatedDate: originatedDate,
+		NextHop:        nextHop,
+		ASPath:         asPath,
+		PrefixNet:      prefixNet,
+		NextHopAddr:    nextHopAddr,
+	}
+	return route, true, nil
 }
 
 // Parse AS number path
@@ -142,22 +169,23 @@ args:
 */
 func (server WhoisServer) LookupRouteView(asn string, query string, c chan BGPLookupResponse) {
 
-	var Answer BGPRoutes
-
 	response, err := server.executeQuery("lookup RouteView", query)
 	if err != nil {
-		c <- BGPLookupResponse{Answer, err}
+		c <- BGPLookupResponse{BGPRoutes{}, err}
 		return
 	}
 
-	routes, err := parseBgpResponse(response)
-	Answer.Asn = asn
-	Answer.Routes = routes
+	Answer, err := server.parseRouteViewLookup(asn, response)
+	c <- BGPLookupResponse{Answer, err}
+}
 
+// parseRouteViewLookup parses a complete RouteView response. The ASN is set
+// even when parsing fails so callers can attribute the failure.
+func (server WhoisServer) parseRouteViewLookup(asn string, response string) (BGPRoutes, error) {
+	routes, err := parseBgpResponse(response)
+	answer := BGPRoutes{Asn: asn, Routes: routes}
 	if err != nil {
-		c <- BGPLookupResponse{Answer, server.operationError("lookup RouteView", err)}
-		return
+		return answer, server.operationError("lookup RouteView", err)
 	}
-
-	c <- BGPLookupResponse{Answer, nil}
+	return answer, nil
 }
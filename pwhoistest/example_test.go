@@ -0,0 +1,34 @@
+package pwhoistest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/pwhoistest"
+)
+
+func ExampleNewServer() {
+	server := pwhoistest.NewServer(pwhoistest.Fixtures{
+		Registry: map[string]string{"AS64500": "Org-ID: EXAMPLE\nOrg-Name: Example Registry Organization"},
+	})
+	defer server.Close()
+
+	client, err := pwhois.NewClient(pwhois.ClientConfig{Server: server.WhoisServer()})
+	if err != nil {
+		panic(err)
+	}
+	record, err := client.LookupRegistry(context.Background(), "64500")
+	fmt.Println(record.Registry.OrgName, err)
+
+	server.SetFaults(func(pwhoistest.Query) pwhoistest.Fault {
+		return pwhoistest.Fault{RateLimit: true}
+	})
+	_, err = client.LookupRegistry(context.Background(), "64500")
+	fmt.Println(errors.Is(err, pwhois.ErrRateLimited))
+
+	// Output:
+	// Example Registry Organization <nil>
+	// true
+}
@@ -0,0 +1,343 @@
+// Package pwhoistest provides a loopback fake PWHOIS server for testing code
+// built on the pwhois package.
+//
+// A Server answers IP, batch, routeview, registry, and netblock queries from
+// in-memory Fixtures, holding each answer in the native response text so that
+// tests exercise the real parsers. Like a PWHOIS server, it answers one query
+// per connection and ends the response by closing the connection. A FaultFunc
+// can inject rate-limit replies, slow or chunked responses, oversized
+// responses, and malformed lines per query.
+package pwhoistest
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/internal/check"
+	"github.com/georgestarcher/pwhois/internal/wire"
+)
+
+// RateLimitReply is the reply sent for a query whose Fault sets RateLimit.
+// The pwhois package reports it as ErrRateLimited.
+const RateLimitReply string = "Error: query limit exceeded\n"
+
+// idleTimeout closes connections that wait this long to send a request
+// line.
+const idleTimeout = 10 * time.Second
+
+// QueryKind identifies the kind of a received query.
+type QueryKind string
+
+const (
+	QueryIP        = QueryKind(wire.KindIP)
+	QueryRouteView = QueryKind(wire.KindRouteView)
+	QueryRegistry  = QueryKind(wire.KindRegistry)
+	QueryNetblock  = QueryKind(wire.KindNetblock)
+	// QueryUnknown is a request the server could not parse. It is answered
+	// with an empty response.
+	QueryUnknown = QueryKind(wire.KindUnknown)
+)
+
+// Query is one request as the server received it.
+type Query struct {
+	Kind QueryKind
+	// App is the app="..." value the client sent.
+	App string
+	// Addresses lists the requested addresses of an IP query, in order.
+	Addresses []string
+	// ASN is the source-as value of a routeview, registry, or netblock
+	// query.
+	ASN string
+	// Raw is the complete request text.
+	Raw string
+}
+
+// Fixtures holds the response text the server answers with. Each value is
+// written exactly as a PWHOIS server would send it, such as "IP: ...\nOrigin-AS:
+// ...". IP keys are addresses and are compared in canonical form; ASN keys
+// may carry an "AS" prefix. A missing key is answered with an empty response,
+// and an IP batch includes only the addresses that have fixtures.
+type Fixtures struct {
+	// IP maps an address to its record.
+	IP map[string]string
+	// RouteView maps an ASN to its routeview response.
+	RouteView map[string]string
+	// Registry maps an ASN to its registry response.
+	Registry map[string]string
+	// Netblock maps an ASN to its netblock response.
+	Netblock map[string]string
+}
+
+// Fault alters how one response is sent. The zero Fault sends the fixture
+// response unchanged.
+type Fault struct {
+	// RateLimit replaces the response with RateLimitReply.
+	RateLimit bool
+	// MalformedLine, when set, is appended to the response as an extra line.
+	MalformedLine string
+	// OversizeBytes, when positive, appends at least this many bytes of
+	// well-formed filler lines.
+	OversizeBytes int
+	// Delay is waited before the first byte of the response.
+	Delay time.Duration
+	// ChunkSize, when positive, writes the response in pieces of this many
+	// bytes.
+	ChunkSize int
+	// ChunkDelay is waited between chunks.
+	ChunkDelay time.Duration
+}
+
+// FaultFunc chooses the Fault for each query.
+type FaultFunc func(Query) Fault
+
+// Server is a fake PWHOIS server listening on an IPv4 loopback port. It is
+// safe for concurrent use and serves any number of connections.
+type Server struct {
+	listener net.Listener
+	fixtures Fixtures
+	done     chan struct{}
+	serving  sync.WaitGroup
+
+	mu          sync.Mutex
+	faults      FaultFunc
+	queries     []Query
+	connections map[net.Conn]struct{}
+	closed      bool
+}
+
+// NewServer starts a Server answering from fixtures, which it copies. Like
+// httptest.NewServer, it panics if it cannot listen. Call Close when done.
+func NewServer(fixtures Fixtures) *Server {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("pwhoistest: listen on loopback: %v", err))
+	}
+	server := &Server{
+		listener: listener,
+		fixtures: Fixtures{
+			IP:        copyFixtures(fixtures.IP, canonicalAddress),
+			RouteView: copyFixtures(fixtures.RouteView, canonicalASN),
+			Registry:  copyFixtures(fixtures.Registry, canonicalASN),
+			Netblock:  copyFixtures(fixtures.Netblock, canonicalASN),
+		},
+		done:        make(chan struct{}),
+		connections: make(map[net.Conn]struct{}),
+	}
+	server.serving.Add(1)
+	go server.accept()
+	return server
+}
+
+// Close stops the listener, closes every open connection, and waits for
+// their handlers to return.
+func (server *Server) Close() {
+	server.mu.Lock()
+	if server.closed {
+		server.mu.Unlock()
+		return
+	}
+	server.closed = true
+	close(server.done)
+	for connection := range server.connections {
+		_ = connection.Close()
+	}
+	server.mu.Unlock()
+
+	_ = server.listener.Close()
+	server.serving.Wait()
+}
+
+// Addr returns the "host:port" address the server listens on.
+func (server *Server) Addr() string {
+	return server.listener.Addr().String()
+}
+
+// WhoisServer returns a configuration for the server with defaults filled
+// by SetDefaultValues.
+func (server *Server) WhoisServer() pwhois.WhoisServer {
+	address := server.listener.Addr().(*net.TCPAddr)
+	configured := pwhois.WhoisServer{Server: address.IP.String(), Port: address.Port}
+	configured.SetDefaultValues()
+	return configured
+}
+
+// SetFaults sets the function that chooses each query's Fault. A nil
+// function, the default, sends every response unchanged.
+func (server *Server) SetFaults(faults FaultFunc) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.faults = faults
+}
+
+// Queries returns every query received so far, in arrival order.
+func (server *Server) Queries() []Query {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return append([]Query(nil), server.queries...)
+}
+
+func (server *Server) accept() {
+	defer server.serving.Done()
+	for {
+		connection, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		server.mu.Lock()
+		if server.closed {
+			server.mu.Unlock()
+			_ = connection.Close()
+			return
+		}
+		server.connections[connection] = struct{}{}
+		server.serving.Add(1)
+		server.mu.Unlock()
+
+		go func() {
+			defer server.serving.Done()
+			defer server.forget(connection)
+			server.serve(connection)
+		}()
+	}
+}
+
+func (server *Server) forget(connection net.Conn) {
+	server.mu.Lock()
+	delete(server.connections, connection)
+	server.mu.Unlock()
+	_ = connection.Close()
+}
+
+// serve answers the connection's one query. The caller closes the
+// connection.
+func (server *Server) serve(connection net.Conn) {
+	wire.Serve(connection, wire.Limits{Idle: idleTimeout}, func(connection net.Conn, request wire.Request) {
+		server.respond(connection, Query{
+			Kind:      QueryKind(request.Kind),
+			App:       request.App,
+			Addresses: request.Addresses,
+			ASN:       request.ASN,
+			Raw:       request.Raw,
+		})
+	})
+}
+
+// respond records query and sends its response, stopping early if the server
+// closes or the client goes away.
+func (server *Server) respond(connection net.Conn, query Query) {
+	server.mu.Lock()
+	server.queries = append(server.queries, query)
+	faults := server.faults
+	server.mu.Unlock()
+
+	var fault Fault
+	if faults != nil {
+		fault = faults(query)
+	}
+	response := applyFault(server.answer(query), fault)
+
+	if !server.sleep(fault.Delay) {
+		return
+	}
+	chunk := len(response)
+	if fault.ChunkSize > 0 {
+		chunk = fault.ChunkSize
+	}
+	for written := 0; written < len(response); written += chunk {
+		if written > 0 && !server.sleep(fault.ChunkDelay) {
+			return
+		}
+		if _, err := io.WriteString(connection, response[written:min(written+chunk, len(response))]); err != nil {
+			return
+		}
+	}
+}
+
+// answer returns the fixture response for query.
+func (server *Server) answer(query Query) string {
+	var fixtures map[string]string
+	switch query.Kind {
+	case QueryIP:
+		var records []string
+		for _, address := range query.Addresses {
+			if record, ok := server.fixtures.IP[canonicalAddress(address)]; ok {
+				records = append(records, record)
+			}
+		}
+		if len(records) == 0 {
+			return ""
+		}
+		return strings.Join(records, "\n\n") + "\n"
+	case QueryRouteView:
+		fixtures = server.fixtures.RouteView
+	case QueryRegistry:
+		fixtures = server.fixtures.Registry
+	case QueryNetblock:
+		fixtures = server.fixtures.Netblock
+	default:
+		return ""
+	}
+	if response, ok := fixtures[canonicalASN(query.ASN)]; ok {
+		return response + "\n"
+	}
+	return ""
+}
+
+func applyFault(response string, fault Fault) string {
+	if fault.RateLimit {
+		return RateLimitReply
+	}
+	if fault.MalformedLine != "" {
+		response += fault.MalformedLine + "\n"
+	}
+	if fault.OversizeBytes > 0 {
+		const filler = "Comment: pwhoistest filler padding the response beyond its limit\n"
+		response += strings.Repeat(filler, fault.OversizeBytes/len(filler)+1)
+	}
+	return response
+}
+
+// sleep waits for delay and reports false if the server closed first.
+func (server *Server) sleep(delay time.Duration) bool {
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-server.done:
+		return false
+	}
+}
+
+func copyFixtures(fixtures map[string]string, canonical func(string) string) map[string]string {
+	copied := make(map[string]string, len(fixtures))
+	for key, value := range fixtures {
+		copied[canonical(key)] = strings.TrimRight(value, "\n")
+	}
+	return copied
+}
+
+func canonicalAddress(value string) string {
+	address, err := netip.ParseAddr(strings.TrimSpace(value))
+	if err != nil {
+		return value
+	}
+	return address.Unmap().String()
+}
+
+func canonicalASN(value string) string {
+	if number, err := check.ParseASN(value); err == nil {
+		return strconv.FormatUint(uint64(number), 10)
+	}
+	return value
+}
@@ -0,0 +1,134 @@
+package pwhoistest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+)
+
+var testFixtures = Fixtures{
+	IP: map[string]string{
+		"192.0.2.1":   "IP: 192.0.2.1\nOrigin-AS: 64500\nPrefix: 192.0.2.0/24\nOrg-Name: Example Networks",
+		"2001:DB8::1": "IP: 2001:db8::1\nOrigin-AS: 64501\nPrefix: 2001:db8::/32\nOrg-Name: Example Six",
+	},
+	RouteView: map[string]string{
+		"AS64500": "*> 192.0.2.0/24 | Jul 18 2026 00:00:04 | Jul 18 2026 00:00:04 | May 28 2026 06:56:01 | 192.0.2.254 | 64501 64500",
+	},
+	Registry: map[string]string{
+		"64500": "Org-ID: EXAMPLE\nOrg-Name: Example Registry Organization",
+	},
+	Netblock: map[string]string{
+		"64500": "Origin-AS: 64500\nOrg-Name: Example Networks\n*> 192.0.2.0 - 192.0.2.255 | EXAMPLE-NET | reassignment | 2019-05-25 | 2019-09-25 | Jun 28 2019 16:53:01 | Jul 18 2026 03:19:32 | TEST",
+	},
+}
+
+func newTestClient(t *testing.T, server *Server) *pwhois.Client {
+	t.Helper()
+	client, err := pwhois.NewClient(pwhois.ClientConfig{Server: server.WhoisServer()})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return client
+}
+
+func TestServerAnswersFromFixtures(t *testing.T) {
+	server := NewServer(testFixtures)
+	defer server.Close()
+	client := newTestClient(t, server)
+	ctx := context.Background()
+
+	records, err := client.LookupIP(ctx, "192.0.2.1")
+	if err != nil || len(records) != 1 || records[0].OriginAS != "64500" {
+		t.Fatalf("IP lookup = %+v, %v", records, err)
+	}
+	batch, err := client.LookupIPBatch(ctx, "192.0.2.1", "2001:db8::1", "198.51.100.7")
+	if err != nil {
+		t.Fatalf("batch lookup: %v", err)
+	}
+	answered := 0
+	for _, result := range batch.Results {
+		if result.Status == pwhois.IPLookupAnswered {
+			answered++
+		}
+	}
+	if len(batch.Results) != 3 || answered != 2 {
+		t.Errorf("batch = %+v, want 2 of 3 answered", batch.Results)
+	}
+
+	routes, err := client.LookupRouteView(ctx, "64500")
+	if err != nil || len(routes.Routes) != 1 || routes.Routes[0].Prefix != "192.0.2.0/24" {
+		t.Fatalf("routeview = %+v, %v", routes, err)
+	}
+	registry, err := client.LookupRegistry(ctx, "AS64500")
+	if err != nil || registry.Registry.OrgID != "EXAMPLE" {
+		t.Fatalf("registry = %+v, %v", registry, err)
+	}
+	netblock, err := client.LookupNetblock(ctx, "64500")
+	if err != nil || len(netblock.Netblocks) != 1 || netblock.OrgName != "Example Networks" {
+		t.Fatalf("netblock = %+v, %v", netblock, err)
+	}
+	if _, err := client.LookupRegistry(ctx, "64999"); !errors.Is(err, pwhois.ErrNoRecords) {
+		t.Errorf("missing fixture error = %v, want ErrNoRecords", err)
+	}
+
+	queries := server.Queries()
+	if len(queries) != 6 {
+		t.Fatalf("queries = %d, want 6", len(queries))
+	}
+	wantBatch := Query{Kind: QueryIP, App: pwhois.AppName, Addresses: []string{"192.0.2.1", "2001:db8::1", "198.51.100.7"}}
+	got := queries[1]
+	got.Raw = ""
+	if !reflect.DeepEqual(got, wantBatch) {
+		t.Errorf("batch query = %+v, want %+v", got, wantBatch)
+	}
+	if queries[2].Kind != QueryRouteView || queries[3].ASN != "64500" || queries[4].Kind != QueryNetblock {
+		t.Errorf("ASN queries = %+v", queries[2:5])
+	}
+}
+
+func TestServerInjectsFaults(t *testing.T) {
+	server := NewServer(testFixtures)
+	defer server.Close()
+	client := newTestClient(t, server)
+	ctx := context.Background()
+
+	server.SetFaults(func(Query) Fault { return Fault{RateLimit: true} })
+	if _, err := client.LookupRegistry(ctx, "64500"); !errors.Is(err, pwhois.ErrRateLimited) {
+		t.Errorf("rate limit error = %v, want ErrRateLimited", err)
+	}
+
+	server.SetFaults(func(Query) Fault { return Fault{MalformedLine: "not a field"} })
+	if _, err := client.LookupRegistry(ctx, "64500"); !errors.Is(err, pwhois.ErrMalformedResponse) {
+		t.Errorf("malformed error = %v, want ErrMalformedResponse", err)
+	}
+
+	server.SetFaults(func(Query) Fault { return Fault{OversizeBytes: 4096} })
+	limited := server.WhoisServer()
+	limited.MaxResponseBytes = 1024
+	limitedClient, err := pwhois.NewClient(pwhois.ClientConfig{Server: limited})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := limitedClient.LookupRegistry(ctx, "64500"); !errors.Is(err, pwhois.ErrResponseTooLarge) {
+		t.Errorf("oversize error = %v, want ErrResponseTooLarge", err)
+	}
+
+	server.SetFaults(func(query Query) Fault {
+		if query.Kind == QueryNetblock {
+			return Fault{ChunkSize: 7, ChunkDelay: time.Millisecond}
+		}
+		return Fault{Delay: time.Second}
+	})
+	if record, err := client.LookupNetblock(ctx, "64500"); err != nil || len(record.Netblocks) != 1 {
+		t.Errorf("chunked netblock = %+v, %v", record, err)
+	}
+	slowCtx, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer cancel()
+	if _, err := client.LookupRegistry(slowCtx, "64500"); !errors.Is(err, pwhois.ErrTimeout) {
+		t.Errorf("slow response error = %v, want ErrTimeout", err)
+	}
+}
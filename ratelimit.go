@@ -0,0 +1,282 @@
+package pwhois
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRateLimitCooldown is the pause used when RateLimiterConfig.Cooldown
+// is zero.
+const DefaultRateLimitCooldown time.Duration = 10 * time.Second
+
+// maxRateLimitPenalty bounds the adaptive slow-down. At the limit refill
+// rates are 32 times slower and the cool-down 16 times longer than
+// configured.
+const maxRateLimitPenalty = 5
+
+// RateLimiterConfig configures a RateLimiter. Rates are per endpoint.
+type RateLimiterConfig struct {
+	// QueriesPerSecond is the steady query rate. Zero leaves queries
+	// unlimited.
+	QueriesPerSecond float64
+	// QueryBurst is the number of queries that may be sent at once after an
+	// idle period. Zero uses the rate rounded up, and at least 1.
+	QueryBurst int
+	// AddressesPerSecond is the steady rate of IP addresses sent, counting
+	// every address in a batch. Zero leaves addresses unlimited.
+	AddressesPerSecond float64
+	// AddressBurst is the number of addresses that may be sent at once after
+	// an idle period. Zero uses the rate rounded up, and at least 1. A batch
+	// larger than the burst waits for a full bucket and then overdraws it.
+	AddressBurst int
+	// Cooldown is the pause after the server reports ErrRateLimited. Zero
+	// uses DefaultRateLimitCooldown.
+	Cooldown time.Duration
+}
+
+// RateLimitBudget reports what an endpoint may send now.
+type RateLimitBudget struct {
+	// Queries is the number of queries available without waiting, or -1
+	// when queries are unlimited.
+	Queries int
+	// Addresses is the number of IP addresses available without waiting, or
+	// -1 when addresses are unlimited.
+	Addresses int
+	// CooldownUntil is when the pause after a rate-limited response ends. It
+	// is zero when no pause is in effect.
+	CooldownUntil time.Time
+	// Slowdown is the factor the refill rates are currently divided by: 1
+	// normally, doubling with each rate-limited response.
+	Slowdown int
+}
+
+// RateLimiter paces queries to public PWHOIS servers with a token bucket per
+// endpoint, counted both in queries and in IP addresses, so large jobs pace
+// themselves before the server cuts them off. One RateLimiter is safe for
+// concurrent use and is meant to be shared by every Client and Pool that
+// talks to the same servers.
+//
+// When a lookup reports ErrRateLimited the endpoint pauses for Cooldown and
+// its refill rates halve. Each further rate-limited response doubles the
+// pause and halves the rates again. Successful lookups restore one step of
+// the rates per Cooldown without a rate-limited response.
+type RateLimiter struct {
+	config RateLimiterConfig
+
+	mu        sync.Mutex
+	endpoints map[string]*rateLimitEndpoint
+}
+
+type rateLimitEndpoint struct {
+	queries       float64
+	addresses     float64
+	updated       time.Time
+	penalty       int
+	limitedAt     time.Time
+	cooldownUntil time.Time
+}
+
+// NewRateLimiter validates configuration and returns a RateLimiter. Each
+// endpoint starts with full buckets.
+func NewRateLimiter(config RateLimiterConfig) (*RateLimiter, error) {
+	if config.QueriesPerSecond < 0 || config.AddressesPerSecond < 0 || config.QueryBurst < 0 || config.AddressBurst < 0 || config.Cooldown < 0 {
+		return nil, invalidInputError("rate limiter values cannot be negative")
+	}
+	if math.IsInf(config.QueriesPerSecond, 0) || math.IsNaN(config.QueriesPerSecond) || math.IsInf(config.AddressesPerSecond, 0) || math.IsNaN(config.AddressesPerSecond) {
+		return nil, invalidInputError("rate limiter rates must be finite")
+	}
+	if config.QueryBurst == 0 {
+		config.QueryBurst = defaultBurst(config.QueriesPerSecond)
+	}
+	if config.AddressBurst == 0 {
+		config.AddressBurst = defaultBurst(config.AddressesPerSecond)
+	}
+	if config.Cooldown == 0 {
+		config.Cooldown = DefaultRateLimitCooldown
+	}
+	return &RateLimiter{config: config, endpoints: make(map[string]*rateLimitEndpoint)}, nil
+}
+
+func defaultBurst(rate float64) int {
+	return max(1, int(math.Ceil(rate)))
+}
+
+// Wait blocks until endpoint may send one query carrying addresses IP
+// addresses, then spends that budget. Use zero addresses for ASN queries.
+// The endpoint is the "host:port" string returned by
+// WhoisServer.ServerAddressString. If ctx ends first, Wait returns
+// ErrCanceled or ErrTimeout and spends nothing.
+//
+// Client and Pool call Wait and Observe themselves when configured
+// with a RateLimiter; call them directly only around the channel-based
+// WhoisServer lookups.
+func (limiter *RateLimiter) Wait(ctx context.Context, endpoint string, addresses int) error {
+	if ctx == nil {
+		return invalidInputError("rate limiter context is required")
+	}
+	if addresses < 0 {
+		return invalidInputError("address count cannot be negative")
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return classifyTransportError(err)
+		}
+		wait := limiter.reserve(endpoint, addresses, time.Now())
+		if wait <= 0 {
+			return nil
+		}
+		if err := waitUntil(ctx, time.Now().Add(wait)); err != nil {
+			return classifyTransportError(err)
+		}
+	}
+}
+
+// Observe records the outcome of a query to endpoint. ErrRateLimited starts
+// an adaptive cool-down; success lets a cool-down wear off. Other errors are
+// ignored.
+func (limiter *RateLimiter) Observe(endpoint string, err error) {
+	now := time.Now()
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	state := limiter.endpointLocked(endpoint, now)
+	switch ClassifyProviderError(err) {
+	case ProviderErrorRateLimited:
+		state.refill(limiter.config, now)
+		state.penalty = min(state.penalty+1, maxRateLimitPenalty)
+		state.limitedAt = now
+		state.cooldownUntil = now.Add(limiter.config.Cooldown << (state.penalty - 1))
+		state.queries = min(state.queries, 0)
+		state.addresses = min(state.addresses, 0)
+	case ProviderErrorNone:
+		if state.penalty > 0 && now.Sub(state.limitedAt) >= limiter.config.Cooldown {
+			state.refill(limiter.config, now)
+			state.penalty--
+			state.limitedAt = now
+		}
+	}
+}
+
+// Remaining reports the budget endpoint may spend without waiting.
+func (limiter *RateLimiter) Remaining(endpoint string) RateLimitBudget {
+	now := time.Now()
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	state := limiter.endpointLocked(endpoint, now)
+	state.refill(limiter.config, now)
+	budget := RateLimitBudget{Queries: -1, Addresses: -1, Slowdown: 1 << state.penalty}
+	if limiter.config.QueriesPerSecond > 0 {
+		budget.Queries = max(0, int(math.Floor(state.queries)))
+	}
+	if limiter.config.AddressesPerSecond > 0 {
+		budget.Addresses = max(0, int(math.Floor(state.addresses)))
+	}
+	if now.Before(state.cooldownUntil) {
+		budget.CooldownUntil = state.cooldownUntil
+		budget.Queries = min(budget.Queries, 0)
+		budget.Addresses = min(budget.Addresses, 0)
+	}
+	return budget
+}
+
+// reserve spends one query and addresses from endpoint and returns zero, or
+// returns how long to wait before trying again without spending anything.
+func (limiter *RateLimiter) reserve(endpoint string, addresses int, now time.Time) time.Duration {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	state := limiter.endpointLocked(endpoint, now)
+	if now.Before(state.cooldownUntil) {
+		return state.cooldownUntil.Sub(now)
+	}
+	state.refill(limiter.config, now)
+
+	slowdown := float64(int(1) << state.penalty)
+	var wait time.Duration
+	if rate := limiter.config.QueriesPerSecond / slowdown; rate > 0 && state.queries < 1 {
+		wait = max(wait, refillTime(1-state.queries, rate))
+	}
+	needed := float64(min(addresses, limiter.config.AddressBurst))
+	if rate := limiter.config.AddressesPerSecond / slowdown; rate > 0 && state.addresses < needed {
+		wait = max(wait, refillTime(needed-state.addresses, rate))
+	}
+	if wait > 0 {
+		return wait
+	}
+	state.queries--
+	state.addresses -= float64(addresses)
+	return 0
+}
+
+func (limiter *RateLimiter) endpointLocked(endpoint string, now time.Time) *rateLimitEndpoint {
+	state, ok := limiter.endpoints[endpoint]
+	if !ok {
+		state = &rateLimitEndpoint{
+			queries:   float64(limiter.config.QueryBurst),
+			addresses: float64(limiter.config.AddressBurst),
+			updated:   now,
+		}
+		limiter.endpoints[endpoint] = state
+	}
+	return state
+}
+
+// refill adds the tokens earned since the last update at the current,
+// possibly slowed, rates. Nothing is earned during a cool-down.
+func (state *rateLimitEndpoint) refill(config RateLimiterConfig, now time.Time) {
+	from := state.updated
+	if from.Before(state.cooldownUntil) {
+		from = state.cooldownUntil
+	}
+	if now.After(from) {
+		elapsed := now.Sub(from).Seconds() / float64(int(1)<<state.penalty)
+		state.queries = min(float64(config.QueryBurst), state.queries+elapsed*config.QueriesPerSecond)
+		state.addresses = min(float64(config.AddressBurst), state.addresses+elapsed*config.AddressesPerSecond)
+	}
+	if now.After(state.updated) {
+		state.updated = now
+	}
+}
+
+func refillTime(tokens, rate float64) time.Duration {
+	return max(time.Millisecond, time.Duration(math.Ceil(tokens/rate*float64(time.Second))))
+}
+
+// waitForQuery waits for budget to send query to server. A nil limiter does
+// not wait.
+func (limiter *RateLimiter) waitForQuery(ctx context.Context, server WhoisServer, operation, query string) error {
+	if limiter == nil {
+		return nil
+	}
+	if err := limiter.Wait(ctx, server.ServerAddressString(), queryAddressCount(query)); err != nil {
+		return server.operationError(operation, err)
+	}
+	return nil
+}
+
+// observeQuery records a query outcome. A nil limiter ignores it.
+func (limiter *RateLimiter) observeQuery(server WhoisServer, err error) {
+	if limiter == nil {
+		return
+	}
+	limiter.Observe(server.ServerAddressString(), err)
+}
+
+// queryAddressCount counts the IP addresses in a formatted query: every line
+// after the app line except the batch markers. ASN queries are one line and
+// count zero.
+func queryAddressCount(query string) int {
+	lines := strings.Split(strings.TrimSuffix(query, "\n"), "\n")
+	count := 0
+	for _, line := range lines[1:] {
+		if line+"\n" == BatchStart || line+"\n" == BatchEnd || line == "" {
+			continue
+		}
+		count++
+	}
+	return count
+}
@@ -0,0 +1,190 @@
+package pwhois
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterPacesQueriesAndAddresses(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimiterConfig{QueriesPerSecond: 50, QueryBurst: 2, AddressesPerSecond: 200, AddressBurst: 10})
+	if err != nil {
+		t.Fatalf("new rate limiter: %v", err)
+	}
+	ctx := context.Background()
+	const endpoint = "192.0.2.1:43"
+
+	started := time.Now()
+	for index := 0; index < 2; index++ {
+		if err := limiter.Wait(ctx, endpoint, 0); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed > 15*time.Millisecond {
+		t.Errorf("burst waited %s", elapsed)
+	}
+	if budget := limiter.Remaining(endpoint); budget.Queries != 0 || budget.Addresses != 10 || budget.Slowdown != 1 {
+		t.Errorf("budget after burst = %+v", budget)
+	}
+	if err := limiter.Wait(ctx, endpoint, 0); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 15*time.Millisecond {
+		t.Errorf("third query waited %s, want about 20ms", elapsed)
+	}
+
+	if err := limiter.Wait(ctx, "192.0.2.2:43", 10); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	started = time.Now()
+	if err := limiter.Wait(ctx, "192.0.2.2:43", 40); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 40*time.Millisecond {
+		t.Errorf("oversized batch waited %s, want a full address bucket", elapsed)
+	}
+	if budget := limiter.Remaining("192.0.2.2:43"); budget.Addresses != 0 {
+		t.Errorf("addresses after overdraw = %d, want 0", budget.Addresses)
+	}
+}
+
+func TestRateLimiterIsSharedAcrossGoroutines(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimiterConfig{QueriesPerSecond: 100, QueryBurst: 1})
+	if err != nil {
+		t.Fatalf("new rate limiter: %v", err)
+	}
+
+	started := time.Now()
+	var wait sync.WaitGroup
+	for index := 0; index < 6; index++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			if err := limiter.Wait(context.Background(), "192.0.2.1:43", 0); err != nil {
+				t.Errorf("wait: %v", err)
+			}
+		}()
+	}
+	wait.Wait()
+	if elapsed := time.Since(started); elapsed < 45*time.Millisecond {
+		t.Errorf("6 queries at 100/s took %s, want at least 50ms", elapsed)
+	}
+}
+
+func TestRateLimiterBacksOffAfterRateLimit(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimiterConfig{QueriesPerSecond: 1000, Cooldown: 30 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("new rate limiter: %v", err)
+	}
+	const endpoint = "192.0.2.1:43"
+
+	limiter.Observe(endpoint, lookupFailure(ErrConnection))
+	if budget := limiter.Remaining(endpoint); !budget.CooldownUntil.IsZero() || budget.Slowdown != 1 {
+		t.Fatalf("budget after connection error = %+v", budget)
+	}
+
+	limiter.Observe(endpoint, lookupFailure(ErrRateLimited))
+	budget := limiter.Remaining(endpoint)
+	if budget.CooldownUntil.IsZero() || budget.Queries != 0 || budget.Slowdown != 2 || budget.Addresses != -1 {
+		t.Fatalf("budget after rate limit = %+v", budget)
+	}
+	started := time.Now()
+	if err := limiter.Wait(context.Background(), endpoint, 0); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 25*time.Millisecond {
+		t.Errorf("wait during cool-down took %s, want about 30ms", elapsed)
+	}
+
+	limiter.Observe(endpoint, lookupFailure(ErrRateLimited))
+	budget = limiter.Remaining(endpoint)
+	if pause := time.Until(budget.CooldownUntil); budget.Slowdown != 4 || pause < 45*time.Millisecond {
+		t.Errorf("second rate limit: slowdown = %d, pause = %s, want 4 and about 60ms", budget.Slowdown, pause)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := limiter.Wait(ctx, endpoint, 0); !errors.Is(err, ErrCanceled) {
+		t.Fatalf("canceled wait error = %v, want ErrCanceled", err)
+	}
+
+	time.Sleep(time.Until(budget.CooldownUntil))
+	limiter.Observe(endpoint, nil)
+	if slowdown := limiter.Remaining(endpoint).Slowdown; slowdown != 2 {
+		t.Errorf("slowdown after recovery = %d, want 2", slowdown)
+	}
+}
+
+func TestRateLimiterPacesClientLookups(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimiterConfig{QueriesPerSecond: 1, QueryBurst: 5, AddressesPerSecond: 1, AddressBurst: 10})
+	if err != nil {
+		t.Fatalf("new rate limiter: %v", err)
+	}
+
+	server, _ := startIPBatchServer(t, ipRecords)
+	client, err := NewClient(ClientConfig{Server: server, RateLimiter: limiter})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.LookupIPBatch(context.Background(), "192.0.2.1", "192.0.2.2", "192.0.2.3"); err != nil {
+		t.Fatalf("batch lookup: %v", err)
+	}
+	if budget := limiter.Remaining(server.ServerAddressString()); budget.Queries != 4 || budget.Addresses != 7 {
+		t.Errorf("budget after batch = %+v, want 4 queries and 7 addresses", budget)
+	}
+
+	limited, _ := startIPBatchServer(t, func([]string) string { return "Error: query limit exceeded\n" })
+	limitedClient, err := NewClient(ClientConfig{Server: limited, RateLimiter: limiter})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := limitedClient.LookupIP(context.Background(), "192.0.2.1"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("rate-limited error = %v, want ErrRateLimited", err)
+	}
+	if budget := limiter.Remaining(limited.ServerAddressString()); budget.CooldownUntil.IsZero() || budget.Slowdown != 2 {
+		t.Errorf("budget after rate-limited response = %+v", budget)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limitedClient.LookupIP(ctx, "192.0.2.2")
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("lookup during cool-down error = %v, want ErrTimeout", err)
+	}
+	assertOperationError(t, err, "lookup IP", limited.ServerAddressString())
+}
+
+func TestRateLimiterValidation(t *testing.T) {
+	for name, config := range map[string]RateLimiterConfig{
+		"negative rate":     {QueriesPerSecond: -1},
+		"negative burst":    {AddressBurst: -1},
+		"negative cooldown": {Cooldown: -time.Second},
+	} {
+		if _, err := NewRateLimiter(config); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: error = %v, want ErrInvalidInput", name, err)
+		}
+	}
+
+	limiter, err := NewRateLimiter(RateLimiterConfig{})
+	if err != nil {
+		t.Fatalf("new rate limiter: %v", err)
+	}
+	if err := limiter.Wait(context.Background(), "192.0.2.1:43", -1); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("negative addresses error = %v, want ErrInvalidInput", err)
+	}
+	if budget := limiter.Remaining("192.0.2.1:43"); budget.Queries != -1 || budget.Addresses != -1 {
+		t.Errorf("unlimited budget = %+v", budget)
+	}
+
+	for query, want := range map[string]int{
+		"app=\"GO pwhois Module\" registry source-as=64500\n":            0,
+		"app=\"GO pwhois Module\"\n192.0.2.1\n":                          1,
+		"app=\"GO pwhois Module\"\nbegin\n192.0.2.1\n2001:db8::1\nend\n": 2,
+	} {
+		if got := queryAddressCount(query); got != want {
+			t.Errorf("queryAddressCount(%q) = %d, want %d", query, got, want)
+		}
+	}
+}
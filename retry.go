@@ -0,0 +1,178 @@
+package pwhois
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// DefaultRetryMaxAttempts is the attempt limit used when
+// RetryPolicy.MaxAttempts is zero.
+const DefaultRetryMaxAttempts int = 3
+
+// DefaultRetryInitialBackoff is the first backoff used when
+// RetryPolicy.InitialBackoff is zero.
+const DefaultRetryInitialBackoff time.Duration = 200 * time.Millisecond
+
+// DefaultRetryMaxBackoff is the backoff ceiling used when
+// RetryPolicy.MaxBackoff is zero.
+const DefaultRetryMaxBackoff time.Duration = 5 * time.Second
+
+// RetryPolicy is an opt-in policy for retrying a lookup by its stable error
+// class. No lookup method retries on its own; pass one to RetryPolicy.Do.
+//
+// ErrConnection and ErrTimeout are retried after an exponential backoff that
+// starts at InitialBackoff, doubles per retry up to MaxBackoff, and is
+// jittered to between half and all of that value. ErrRateLimited is retried
+// only when RateLimitCooldown is positive, after waiting that long, because
+// retrying a rate-limited server quickly prolongs the limit. Every other
+// error, including ErrCanceled and unclassified errors, ends the retries.
+type RetryPolicy struct {
+	// MaxAttempts bounds the total number of attempts, including the first.
+	// Zero uses DefaultRetryMaxAttempts.
+	MaxAttempts int
+	// InitialBackoff is the backoff before the first connection or timeout
+	// retry. Zero uses DefaultRetryInitialBackoff.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the connection and timeout backoff. Zero uses
+	// DefaultRetryMaxBackoff.
+	MaxBackoff time.Duration
+	// RateLimitCooldown is the wait before retrying ErrRateLimited. Zero
+	// leaves rate-limit errors unretried.
+	RateLimitCooldown time.Duration
+	// Budget bounds the time from the first attempt until the start of the
+	// last one. Zero leaves only the context to bound it. A retry whose wait
+	// would overrun the budget or the context deadline is not attempted.
+	Budget time.Duration
+}
+
+// RetryFunc performs one attempt. It receives the context passed to Do.
+type RetryFunc func(context.Context) error
+
+// RetryError records every failed attempt made by RetryPolicy.Do. errors.Is,
+// errors.As, and ClassifyProviderError match against the final attempt's
+// error and against Context, so the error is classified by the failure that
+// ended the retries. Inspect Attempts for the earlier failures.
+type RetryError struct {
+	// Attempts holds each attempt's error in order.
+	Attempts []error
+	// Context is the classified ErrCanceled or ErrTimeout when the context
+	// ended while waiting to retry, and nil otherwise.
+	Context error
+}
+
+func (err *RetryError) Error() string {
+	var message strings.Builder
+	fmt.Fprintf(&message, "pwhois lookup failed after %d attempt", len(err.Attempts))
+	if len(err.Attempts) != 1 {
+		message.WriteString("s")
+	}
+	if last := err.Last(); last != nil {
+		fmt.Fprintf(&message, ": %v", last)
+	}
+	if err.Context != nil {
+		fmt.Fprintf(&message, "; retry stopped: %v", err.Context)
+	}
+	return message.String()
+}
+
+func (err *RetryError) Unwrap() []error {
+	errs := make([]error, 0, 2)
+	if last := err.Last(); last != nil {
+		errs = append(errs, last)
+	}
+	if err.Context != nil {
+		errs = append(errs, err.Context)
+	}
+	return errs
+}
+
+// Last returns the final attempt's error.
+func (err *RetryError) Last() error {
+	if len(err.Attempts) == 0 {
+		return nil
+	}
+	return err.Attempts[len(err.Attempts)-1]
+}
+
+func (policy RetryPolicy) normalize() (RetryPolicy, error) {
+	if policy.MaxAttempts < 0 || policy.InitialBackoff < 0 || policy.MaxBackoff < 0 || policy.RateLimitCooldown < 0 || policy.Budget < 0 {
+		return RetryPolicy{}, invalidInputError("retry policy values cannot be negative")
+	}
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if policy.InitialBackoff == 0 {
+		policy.InitialBackoff = DefaultRetryInitialBackoff
+	}
+	if policy.MaxBackoff == 0 {
+		policy.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	return policy, nil
+}
+
+// Do runs attempt until it succeeds, returns an error the policy does not
+// retry, or the attempt limit, budget, or context ends the retries. On
+// failure it returns a *RetryError. An invalid policy returns
+// ErrInvalidInput without calling attempt.
+func (policy RetryPolicy) Do(ctx context.Context, attempt RetryFunc) error {
+	if ctx == nil {
+		return invalidInputError("retry context is required")
+	}
+	policy, err := policy.normalize()
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	retryError := &RetryError{}
+	backoff := policy.InitialBackoff
+	for {
+		err := attempt(ctx)
+		if err == nil {
+			return nil
+		}
+		retryError.Attempts = append(retryError.Attempts, err)
+		if len(retryError.Attempts) >= policy.MaxAttempts || ctx.Err() != nil {
+			return retryError
+		}
+
+		var wait time.Duration
+		switch ClassifyProviderError(err) {
+		case ProviderErrorConnection, ProviderErrorTimeout:
+			wait = jitterBackoff(backoff)
+			backoff = min(backoff*2, policy.MaxBackoff)
+		case ProviderErrorRateLimited:
+			if policy.RateLimitCooldown == 0 {
+				return retryError
+			}
+			wait = policy.RateLimitCooldown
+		default:
+			return retryError
+		}
+
+		next := time.Now().Add(wait)
+		if policy.Budget > 0 && next.Sub(started) > policy.Budget {
+			return retryError
+		}
+		if deadline, ok := ctx.Deadline(); ok && next.After(deadline) {
+			return retryError
+		}
+		if err := waitUntil(ctx, next); err != nil {
+			retryError.Context = classifyTransportError(err)
+			return retryError
+		}
+	}
+}
+
+// jitterBackoff returns a random duration between half of backoff and
+// backoff, so clients that failed together do not retry together.
+func jitterBackoff(backoff time.Duration) time.Duration {
+	half := backoff / 2
+	if half <= 0 {
+		return backoff
+	}
+	return half + time.Duration(rand.Int63n(int64(backoff-half)+1))
+}
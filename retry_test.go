@@ -0,0 +1,178 @@
+package pwhois
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// scriptedAttempts returns a RetryFunc that fails with each error in turn and
+// then succeeds, and a counter of the calls made.
+func scriptedAttempts(errs ...error) (RetryFunc, *int) {
+	calls := 0
+	return func(context.Context) error {
+		calls++
+		if calls <= len(errs) {
+			return errs[calls-1]
+		}
+		return nil
+	}, &calls
+}
+
+func lookupFailure(class error) error {
+	return &OperationError{Operation: "lookup registry", Server: "192.0.2.1:43", Err: class}
+}
+
+func TestRetryPolicyRetriesByErrorClass(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		errs      []error
+		wantCalls int
+		wantErr   error
+		minWait   time.Duration
+	}{
+		{
+			name:      "connection then success",
+			policy:    RetryPolicy{InitialBackoff: 10 * time.Millisecond},
+			errs:      []error{lookupFailure(ErrConnection), lookupFailure(ErrTimeout)},
+			wantCalls: 3,
+			minWait:   5*time.Millisecond + 10*time.Millisecond,
+		},
+		{
+			name:      "attempts exhausted",
+			policy:    RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+			errs:      []error{lookupFailure(ErrConnection), lookupFailure(ErrConnection), lookupFailure(ErrConnection)},
+			wantCalls: 2,
+			wantErr:   ErrConnection,
+		},
+		{
+			name:      "rate limited without cool-down",
+			policy:    RetryPolicy{InitialBackoff: time.Millisecond},
+			errs:      []error{lookupFailure(ErrRateLimited)},
+			wantCalls: 1,
+			wantErr:   ErrRateLimited,
+		},
+		{
+			name:      "rate limited with cool-down",
+			policy:    RetryPolicy{InitialBackoff: time.Millisecond, RateLimitCooldown: 40 * time.Millisecond},
+			errs:      []error{lookupFailure(ErrRateLimited)},
+			wantCalls: 2,
+			minWait:   40 * time.Millisecond,
+		},
+		{
+			name:      "malformed response",
+			policy:    RetryPolicy{InitialBackoff: time.Millisecond},
+			errs:      []error{lookupFailure(ErrMalformedResponse)},
+			wantCalls: 1,
+			wantErr:   ErrMalformedResponse,
+		},
+		{
+			name:      "unclassified error",
+			policy:    RetryPolicy{InitialBackoff: time.Millisecond},
+			errs:      []error{errors.New("application failure")},
+			wantCalls: 1,
+		},
+		{
+			name:      "budget exceeded",
+			policy:    RetryPolicy{InitialBackoff: 100 * time.Millisecond, Budget: 10 * time.Millisecond},
+			errs:      []error{lookupFailure(ErrConnection)},
+			wantCalls: 1,
+			wantErr:   ErrConnection,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempt, calls := scriptedAttempts(test.errs...)
+			started := time.Now()
+			err := test.policy.Do(context.Background(), attempt)
+			elapsed := time.Since(started)
+
+			if *calls != test.wantCalls {
+				t.Errorf("attempts = %d, want %d", *calls, test.wantCalls)
+			}
+			if elapsed < test.minWait {
+				t.Errorf("elapsed = %s, want at least %s", elapsed, test.minWait)
+			}
+			if test.wantCalls > len(test.errs) {
+				if err != nil {
+					t.Fatalf("error = %v, want success", err)
+				}
+				return
+			}
+
+			var retryError *RetryError
+			if !errors.As(err, &retryError) || len(retryError.Attempts) != test.wantCalls {
+				t.Fatalf("error = %#v, want *RetryError with %d attempts", err, test.wantCalls)
+			}
+			if retryError.Last() != test.errs[test.wantCalls-1] {
+				t.Errorf("last = %v, want %v", retryError.Last(), test.errs[test.wantCalls-1])
+			}
+			if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Errorf("error = %v, want %v", err, test.wantErr)
+			}
+			var operationError *OperationError
+			if test.wantErr != nil && !errors.As(err, &operationError) {
+				t.Errorf("error = %v does not expose *OperationError", err)
+			}
+		})
+	}
+}
+
+func TestRetryErrorMatchesFinalAttempt(t *testing.T) {
+	attempt, _ := scriptedAttempts(lookupFailure(ErrTimeout), lookupFailure(ErrRateLimited), lookupFailure(ErrNoRecords))
+	err := RetryPolicy{InitialBackoff: time.Millisecond, RateLimitCooldown: time.Millisecond}.Do(context.Background(), attempt)
+
+	var retryError *RetryError
+	if !errors.As(err, &retryError) || len(retryError.Attempts) != 3 {
+		t.Fatalf("error = %#v, want *RetryError with 3 attempts", err)
+	}
+	if class := ClassifyProviderError(err); class != ProviderErrorNoRecords {
+		t.Errorf("class = %s, want %s", class, ProviderErrorNoRecords)
+	}
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrRateLimited) || !errors.Is(err, ErrNoRecords) {
+		t.Errorf("error = %v matches an earlier attempt's class", err)
+	}
+}
+
+func TestRetryPolicyStopsWhenContextEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempt, calls := scriptedAttempts(lookupFailure(ErrConnection), lookupFailure(ErrConnection))
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	err := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Second}.Do(ctx, attempt)
+	var retryError *RetryError
+	if !errors.As(err, &retryError) || !errors.Is(retryError.Context, ErrCanceled) {
+		t.Fatalf("error = %v, want *RetryError stopped by ErrCanceled", err)
+	}
+	if *calls != 1 || !errors.Is(err, ErrConnection) || !errors.Is(err, ErrCanceled) {
+		t.Errorf("attempts = %d, error = %v", *calls, err)
+	}
+
+	deadlineCtx, deadlineCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer deadlineCancel()
+	attempt, calls = scriptedAttempts(lookupFailure(ErrConnection))
+	started := time.Now()
+	err = RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Second}.Do(deadlineCtx, attempt)
+	if *calls != 1 || !errors.Is(err, ErrConnection) || time.Since(started) > 40*time.Millisecond {
+		t.Errorf("retry past the context deadline: attempts = %d, error = %v", *calls, err)
+	}
+}
+
+func TestRetryPolicyValidation(t *testing.T) {
+	attempt, calls := scriptedAttempts()
+	if err := (RetryPolicy{MaxAttempts: -1}).Do(context.Background(), attempt); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("negative attempts error = %v, want ErrInvalidInput", err)
+	}
+	if *calls != 0 {
+		t.Errorf("invalid policy made %d attempts", *calls)
+	}
+
+	for index := 0; index < 100; index++ {
+		if wait := jitterBackoff(100 * time.Millisecond); wait < 50*time.Millisecond || wait > 100*time.Millisecond {
+			t.Fatalf("jittered backoff = %s, want between 50ms and 100ms", wait)
+		}
+	}
+}
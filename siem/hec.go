@@ -0,0 +1,234 @@
+package siem
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/internal/check"
+)
+
+// DefaultHECBatchSize is the events per POST used when HECConfig.BatchSize
+// is zero.
+const DefaultHECBatchSize int = 100
+
+// DefaultHECMaxBatchBytes is the POST body bound used when
+// HECConfig.MaxBatchBytes is zero. It is well under the collector's default
+// content length limit.
+const DefaultHECMaxBatchBytes int64 = 1024 * 1024
+
+// DefaultHECMaxResponseBytes is the collector reply bound used when
+// HECConfig.MaxResponseBytes is zero. Collector replies are short JSON
+// acknowledgements.
+const DefaultHECMaxResponseBytes int64 = 64 * 1024
+
+// DefaultHECTimeout bounds each POST when HECConfig.Timeout is zero.
+const DefaultHECTimeout time.Duration = 10 * time.Second
+
+// HECConfig configures a HECEmitter.
+type HECConfig struct {
+	// URL is the collector endpoint, such as
+	// "https://splunk.example:8088/services/collector/event".
+	URL string
+	// Token is the HEC token sent in the Authorization header.
+	Token string
+	// Host, Source, and Index set the metadata of every event. Empty Source
+	// uses "pwhois"; empty Host and Index leave them to the collector.
+	Host   string
+	Source string
+	Index  string
+	// BatchSize bounds the events per POST. Zero uses DefaultHECBatchSize.
+	BatchSize int
+	// MaxBatchBytes bounds the body of each POST. An event that alone
+	// exceeds it fails with pwhois.ErrInvalidInput. Zero uses
+	// DefaultHECMaxBatchBytes.
+	MaxBatchBytes int64
+	// MaxResponseBytes bounds the collector reply read for each POST; a
+	// longer reply fails with a *pwhois.ResponseTooLargeError. Zero uses
+	// DefaultHECMaxResponseBytes.
+	MaxResponseBytes int64
+	// Timeout bounds each POST attempt. Zero uses DefaultHECTimeout.
+	Timeout time.Duration
+	// Client sends the POSTs. Nil uses http.DefaultClient.
+	Client *http.Client
+	// Retry retries each batch by error class: connection failures, server
+	// errors, and timeouts with backoff, and rate limiting when its
+	// RateLimitCooldown is set. Rejected requests are not retried.
+	Retry pwhois.RetryPolicy
+}
+
+// HECEmitter posts events to a Splunk HTTP Event Collector. It is safe for
+// concurrent use.
+type HECEmitter struct {
+	config HECConfig
+	host   string
+}
+
+// hecEnvelope is the collector's JSON event format.
+type hecEnvelope struct {
+	Time       json.Number `json:"time,omitempty"`
+	Host       string      `json:"host,omitempty"`
+	Source     string      `json:"source,omitempty"`
+	SourceType string      `json:"sourcetype"`
+	Index      string      `json:"index,omitempty"`
+	Event      any         `json:"event"`
+}
+
+// hecReply is the collector's acknowledgement.
+type hecReply struct {
+	Text string `json:"text"`
+	Code int    `json:"code"`
+}
+
+// NewHECEmitter validates config and returns an emitter for it.
+func NewHECEmitter(config HECConfig) (*HECEmitter, error) {
+	endpoint, err := url.Parse(config.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, check.InvalidInput("HEC URL must be an absolute http or https URL")
+	}
+	if config.Token == "" {
+		return nil, check.InvalidInput("HEC token is required")
+	}
+	if config.BatchSize < 0 || config.MaxBatchBytes < 0 || config.MaxResponseBytes < 0 || config.Timeout < 0 {
+		return nil, check.InvalidInput("HEC bounds cannot be negative")
+	}
+	if config.Source == "" {
+		config.Source = "pwhois"
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = DefaultHECBatchSize
+	}
+	if config.MaxBatchBytes == 0 {
+		config.MaxBatchBytes = DefaultHECMaxBatchBytes
+	}
+	if config.MaxResponseBytes == 0 {
+		config.MaxResponseBytes = DefaultHECMaxResponseBytes
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultHECTimeout
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &HECEmitter{config: config, host: endpoint.Host}, nil
+}
+
+// Emit posts events in batches of at most BatchSize events and MaxBatchBytes
+// bytes, in order, retrying each batch under the Retry policy. Every event
+// is encoded before the first POST, so an event that cannot be encoded sends
+// nothing. It stops at the first batch that fails; the error reports how
+// many events were accepted before it.
+func (emitter *HECEmitter) Emit(ctx context.Context, events ...Event) error {
+	if ctx == nil {
+		return check.InvalidInput("HEC emit context is required")
+	}
+	encoded := make([][]byte, len(events))
+	for index, event := range events {
+		envelope := hecEnvelope{
+			Host:       emitter.config.Host,
+			Source:     emitter.config.Source,
+			SourceType: event.SourceType(),
+			Index:      emitter.config.Index,
+			Event:      event.Data,
+		}
+		if !event.Time.IsZero() {
+			envelope.Time = json.Number(fmt.Sprintf("%d.%03d", event.Time.Unix(), event.Time.Nanosecond()/int(time.Millisecond)))
+		}
+		line, err := json.Marshal(envelope)
+		if err != nil {
+			return fmt.Errorf("encode HEC event %d: %w", index+1, check.InvalidInput(err.Error()))
+		}
+		if int64(len(line)) > emitter.config.MaxBatchBytes {
+			return fmt.Errorf("encode HEC event %d: %w", index+1, check.InvalidInput(fmt.Sprintf("event is %d bytes, over the %d byte batch limit", len(line), emitter.config.MaxBatchBytes)))
+		}
+		encoded[index] = line
+	}
+
+	sent := 0
+	for sent < len(encoded) {
+		var body bytes.Buffer
+		count := 0
+		for sent+count < len(encoded) && count < emitter.config.BatchSize {
+			line := encoded[sent+count]
+			if count > 0 && int64(body.Len()+1+len(line)) > emitter.config.MaxBatchBytes {
+				break
+			}
+			if count > 0 {
+				body.WriteByte('\n')
+			}
+			body.Write(line)
+			count++
+		}
+		err := emitter.config.Retry.Do(ctx, func(ctx context.Context) error {
+			return emitter.post(ctx, body.Bytes())
+		})
+		if err != nil {
+			return fmt.Errorf("post HEC batch after %d of %d events: %w", sent, len(encoded), err)
+		}
+		sent += count
+	}
+	return nil
+}
+
+// post sends one batch and classifies the collector's answer.
+func (emitter *HECEmitter) post(ctx context.Context, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, emitter.config.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, emitter.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return emitter.operationError(check.InvalidInput(err.Error()))
+	}
+	request.Header.Set("Authorization", "Splunk "+emitter.config.Token)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := emitter.config.Client.Do(request)
+	if err != nil {
+		return emitter.operationError(classifyTransportError(ctx, err))
+	}
+	defer response.Body.Close()
+
+	reply, err := io.ReadAll(io.LimitReader(response.Body, emitter.config.MaxResponseBytes+1))
+	if err != nil {
+		return emitter.operationError(classifyTransportError(ctx, err))
+	}
+	if int64(len(reply)) > emitter.config.MaxResponseBytes {
+		return emitter.operationError(&pwhois.ResponseTooLargeError{Limit: emitter.config.MaxResponseBytes})
+	}
+
+	var acknowledgement hecReply
+	decodeErr := json.Unmarshal(reply, &acknowledgement)
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		if decodeErr != nil {
+			return emitter.operationError(fmt.Errorf("%w: collector reply is not JSON", pwhois.ErrMalformedResponse))
+		}
+		return nil
+	}
+
+	status := response.Status
+	if decodeErr == nil && acknowledgement.Text != "" {
+		status = fmt.Sprintf("%s: %s (code %d)", response.Status, acknowledgement.Text, acknowledgement.Code)
+	}
+	var class error
+	switch {
+	case response.StatusCode == http.StatusTooManyRequests:
+		class = pwhois.ErrRateLimited
+	case response.StatusCode == http.StatusRequestTimeout || response.StatusCode == http.StatusGatewayTimeout:
+		class = pwhois.ErrTimeout
+	case response.StatusCode >= 500:
+		class = pwhois.ErrConnection
+	default:
+		class = pwhois.ErrInvalidInput
+	}
+	return emitter.operationError(fmt.Errorf("%w: collector responded %s", class, status))
+}
+
+func (emitter *HECEmitter) operationError(err error) error {
+	return &pwhois.OperationError{Operation: "post HEC batch", Server: emitter.host, Err: err}
+}
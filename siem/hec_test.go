@@ -0,0 +1,177 @@
+package siem
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+)
+
+// collector is a local HTTP Event Collector stand-in that records each
+// batch and answers with the status its respond function returns.
+type collector struct {
+	mu      sync.Mutex
+	batches [][]map[string]any
+	respond func(batch int) (int, string)
+}
+
+func (c *collector) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Header.Get("Authorization") != "Splunk test-token" {
+		writer.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(writer, `{"text":"Invalid authorization","code":3}`)
+		return
+	}
+	var batch []map[string]any
+	scanner := bufio.NewScanner(request.Body)
+	for scanner.Scan() {
+		var envelope map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &envelope); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			io.WriteString(writer, `{"text":"Invalid data format","code":6}`)
+			return
+		}
+		batch = append(batch, envelope)
+	}
+
+	c.mu.Lock()
+	c.batches = append(c.batches, batch)
+	number := len(c.batches)
+	c.mu.Unlock()
+
+	status, body := http.StatusOK, `{"text":"Success","code":0}`
+	if c.respond != nil {
+		status, body = c.respond(number)
+	}
+	writer.WriteHeader(status)
+	io.WriteString(writer, body)
+}
+
+func newTestHEC(t *testing.T, server *httptest.Server, config HECConfig) *HECEmitter {
+	t.Helper()
+	config.URL = server.URL + "/services/collector/event"
+	config.Token = "test-token"
+	config.Retry = pwhois.RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, RateLimitCooldown: config.Retry.RateLimitCooldown}
+	emitter, err := NewHECEmitter(config)
+	if err != nil {
+		t.Fatalf("NewHECEmitter: %v", err)
+	}
+	return emitter
+}
+
+func TestHECEmitterPostsBatchedEnvelopes(t *testing.T) {
+	stand := &collector{}
+	server := httptest.NewServer(stand)
+	defer server.Close()
+
+	emitter := newTestHEC(t, server, HECConfig{Host: "sensor-1", Index: "netops", BatchSize: 2})
+	records := []pwhois.WhoIs{
+		{IP: "192.0.2.1", CacheDate: time.Date(2026, 7, 18, 0, 0, 4, 250*int(time.Millisecond), time.UTC)},
+		{IP: "192.0.2.2"},
+		{IP: "192.0.2.3"},
+	}
+	events := append(WhoIsEvents(records...), RegistryEvent(pwhois.RegistryRecord{Asn: "64500"}))
+	if err := emitter.Emit(context.Background(), events...); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+
+	if len(stand.batches) != 2 || len(stand.batches[0]) != 2 || len(stand.batches[1]) != 2 {
+		t.Fatalf("batches = %v, want two batches of two", stand.batches)
+	}
+	first := stand.batches[0][0]
+	if first["time"] != 1784332804.25 || first["host"] != "sensor-1" || first["source"] != "pwhois" ||
+		first["sourcetype"] != "pwhois:ip" || first["index"] != "netops" || first["event"].(map[string]any)["ip"] != "192.0.2.1" {
+		t.Errorf("first envelope = %v", first)
+	}
+	if _, ok := stand.batches[0][1]["time"]; ok {
+		t.Errorf("undated envelope = %v, want no time", stand.batches[0][1])
+	}
+	if got := stand.batches[1][1]["sourcetype"]; got != "pwhois:registry" {
+		t.Errorf("registry sourcetype = %v", got)
+	}
+
+	// A byte bound splits batches before the count bound does.
+	stand.batches = nil
+	line, _ := json.Marshal(hecEnvelope{Source: "pwhois", SourceType: "pwhois:ip", Event: records[1]})
+	emitter = newTestHEC(t, server, HECConfig{MaxBatchBytes: int64(2*len(line) + 1)})
+	if err := emitter.Emit(context.Background(), WhoIsEvents(records[1], records[2], records[1])...); err != nil {
+		t.Fatalf("Emit with byte bound: %v", err)
+	}
+	if len(stand.batches) != 2 || len(stand.batches[0]) != 2 {
+		t.Errorf("byte-bounded batches = %d", len(stand.batches))
+	}
+}
+
+func TestHECEmitterRetriesAndClassifiesFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(int) (int, string)
+		config  HECConfig
+		want    error
+		batches int
+	}{
+		{name: "retry busy", respond: func(batch int) (int, string) {
+			if batch == 1 {
+				return http.StatusServiceUnavailable, `{"text":"Server is busy","code":9}`
+			}
+			return http.StatusOK, `{"text":"Success","code":0}`
+		}, batches: 2},
+		{name: "rejected", respond: func(int) (int, string) { return http.StatusBadRequest, `{"text":"No data","code":5}` }, want: pwhois.ErrInvalidInput, batches: 1},
+		{name: "rate limited", respond: func(int) (int, string) { return http.StatusTooManyRequests, "" }, want: pwhois.ErrRateLimited, batches: 1},
+		{name: "rate limit cooldown", respond: func(int) (int, string) { return http.StatusTooManyRequests, "" }, config: HECConfig{Retry: pwhois.RetryPolicy{RateLimitCooldown: time.Millisecond}}, want: pwhois.ErrRateLimited, batches: 3},
+		{name: "oversized reply", respond: func(int) (int, string) { return http.StatusOK, strings.Repeat(" ", 64) }, config: HECConfig{MaxResponseBytes: 32}, want: pwhois.ErrResponseTooLarge, batches: 1},
+		{name: "malformed reply", respond: func(int) (int, string) { return http.StatusOK, "ok" }, want: pwhois.ErrMalformedResponse, batches: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stand := &collector{respond: test.respond}
+			server := httptest.NewServer(stand)
+			defer server.Close()
+
+			err := newTestHEC(t, server, test.config).Emit(context.Background(), WhoIsEvents(pwhois.WhoIs{IP: "192.0.2.1"})...)
+			if test.want == nil && err != nil || test.want != nil && !errors.Is(err, test.want) {
+				t.Errorf("Emit error = %v, want %v", err, test.want)
+			}
+			if len(stand.batches) != test.batches {
+				t.Errorf("POSTs = %d, want %d", len(stand.batches), test.batches)
+			}
+		})
+	}
+
+	server := httptest.NewServer(&collector{})
+	server.Close()
+	if err := newTestHEC(t, server, HECConfig{}).Emit(context.Background(), WhoIsEvents(pwhois.WhoIs{IP: "192.0.2.1"})...); !errors.Is(err, pwhois.ErrConnection) {
+		t.Errorf("closed collector error = %v, want ErrConnection", err)
+	}
+}
+
+func TestHECEmitterRejectsBadConfigAndEvents(t *testing.T) {
+	for _, config := range []HECConfig{
+		{URL: "splunk:8088", Token: "t"},
+		{URL: "ftp://splunk:8088", Token: "t"},
+		{URL: "https://splunk:8088/services/collector/event"},
+		{URL: "https://splunk:8088/services/collector/event", Token: "t", BatchSize: -1},
+	} {
+		if _, err := NewHECEmitter(config); !errors.Is(err, pwhois.ErrInvalidInput) {
+			t.Errorf("NewHECEmitter(%+v) error = %v, want ErrInvalidInput", config, err)
+		}
+	}
+
+	stand := &collector{}
+	server := httptest.NewServer(stand)
+	defer server.Close()
+	emitter := newTestHEC(t, server, HECConfig{MaxBatchBytes: 256})
+	events := WhoIsEvents(pwhois.WhoIs{IP: "192.0.2.1"}, pwhois.WhoIs{IP: "192.0.2.2", OrgName: string(bytes.Repeat([]byte("x"), 256))})
+	if err := emitter.Emit(context.Background(), events...); !errors.Is(err, pwhois.ErrInvalidInput) || len(stand.batches) != 0 {
+		t.Errorf("oversized event = %v after %d POSTs, want ErrInvalidInput before any", err, len(stand.batches))
+	}
+}
@@ -0,0 +1,139 @@
+// Package siem emits pwhois records to log pipelines as Splunk HTTP Event
+// Collector events and RFC 5424 syslog messages.
+//
+// An Event wraps one record with the time it describes and the lookup that
+// produced it. WhoIsEvents, RouteEvents, RegistryEvent, and NetblockEvents
+// build events from lookup results, flattening routes and netblocks into one
+// event each that carries the ASN or organization it belongs to. A
+// HECEmitter posts events in bounded batches with retries, and a
+// SyslogEmitter writes one message per event over UDP or TCP.
+//
+// Failures use the pwhois error classes: an unreachable collector is
+// pwhois.ErrConnection, a rate-limited one pwhois.ErrRateLimited, an
+// oversized collector reply pwhois.ErrResponseTooLarge, and an event or
+// configuration the format cannot carry pwhois.ErrInvalidInput, so a
+// pwhois.RetryPolicy and pwhois.ClassifyProviderError apply unchanged.
+package siem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+)
+
+// Lookup names, used as the syslog message ID and, prefixed with "pwhois:",
+// as the Splunk sourcetype.
+const (
+	LookupIP        = "ip"
+	LookupRouteView = "routeview"
+	LookupRegistry  = "registry"
+	LookupNetblock  = "netblock"
+)
+
+// Event is one record to emit.
+type Event struct {
+	// Time is the time the record describes. Zero leaves the time to the
+	// collector.
+	Time time.Time
+	// Lookup names the lookup that produced the record, such as LookupIP.
+	Lookup string
+	// Data is the record. It is emitted as its JSON encoding.
+	Data any
+}
+
+// SourceType returns the Splunk sourcetype of the event, "pwhois:" followed by
+// its lookup name.
+func (event Event) SourceType() string {
+	return "pwhois:" + event.Lookup
+}
+
+// routeData is a route with the ASN it was looked up for.
+type routeData struct {
+	Asn string `json:"asn"`
+	pwhois.BGPRoute
+}
+
+// registryData is a registry with the ASN it was looked up for.
+type registryData struct {
+	Asn string `json:"asn"`
+	pwhois.Registry
+}
+
+// netblockData is a netblock with the organization it belongs to.
+type netblockData struct {
+	Asn       string `json:"asn"`
+	OriginAs  string `json:"origin_asn"`
+	ASSource  string `json:"as_source"`
+	OrgID     string `json:"org_id"`
+	Org       int64  `json:"org"`
+	AS        int64  `json:"as"`
+	OrgName   string `json:"org_name"`
+	OrgSource string `json:"org_source"`
+	pwhois.Netblock
+}
+
+// WhoIsEvents returns one event per IP record, timed by its CacheDate.
+func WhoIsEvents(records ...pwhois.WhoIs) []Event {
+	events := make([]Event, len(records))
+	for index, record := range records {
+		events[index] = Event{Time: record.CacheDate, Lookup: LookupIP, Data: record}
+	}
+	return events
+}
+
+// RouteEvents returns one event per route, timed by its ModifyDate. Each
+// event's data carries the route's fields and the asn of routes.
+func RouteEvents(routes pwhois.BGPRoutes) []Event {
+	events := make([]Event, len(routes.Routes))
+	for index, route := range routes.Routes {
+		events[index] = Event{Time: route.ModifyDate, Lookup: LookupRouteView, Data: routeData{Asn: routes.Asn, BGPRoute: route}}
+	}
+	return events
+}
+
+// RegistryEvent returns an event for record, timed by the registry's
+// ModifyDate. Its data carries the asn and the registry's fields.
+func RegistryEvent(record pwhois.RegistryRecord) Event {
+	return Event{Time: record.Registry.ModifyDate, Lookup: LookupRegistry, Data: registryData{Asn: record.Asn, Registry: record.Registry}}
+}
+
+// NetblockEvents returns one event per netblock, timed by its ModifyDate.
+// Each event's data carries the netblock's fields and the organization fields
+// of record.
+func NetblockEvents(record pwhois.NetblockRecord) []Event {
+	events := make([]Event, len(record.Netblocks))
+	for index, block := range record.Netblocks {
+		events[index] = Event{Time: block.ModifyDate, Lookup: LookupNetblock, Data: netblockData{
+			Asn:       record.Asn,
+			OriginAs:  record.OriginAs,
+			ASSource:  record.ASSource,
+			OrgID:     record.OrgID,
+			Org:       record.Org,
+			AS:        record.AS,
+			OrgName:   record.OrgName,
+			OrgSource: record.OrgSource,
+			Netblock:  block,
+		}}
+	}
+	return events
+}
+
+// classifyTransportError maps a network or context failure to its pwhois
+// error class.
+func classifyTransportError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("%w: %w", pwhois.ErrCanceled, err)
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", pwhois.ErrTimeout, err)
+	}
+	var networkError net.Error
+	if errors.As(err, &networkError) && networkError.Timeout() {
+		return fmt.Errorf("%w: %w", pwhois.ErrTimeout, err)
+	}
+	return fmt.Errorf("%w: %w", pwhois.ErrConnection, err)
+}
@@ -0,0 +1,59 @@
+package siem
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+)
+
+var (
+	testCacheDate  = time.Date(2026, 7, 18, 0, 0, 4, 0, time.UTC)
+	testModifyDate = time.Date(2026, 7, 18, 3, 19, 32, 0, time.UTC)
+)
+
+func TestEventsCarryTimeLookupAndParentFields(t *testing.T) {
+	var events []Event
+	events = append(events, WhoIsEvents(pwhois.WhoIs{IP: "192.0.2.1", OriginAS: "64500", CacheDate: testCacheDate})...)
+	events = append(events, RouteEvents(pwhois.BGPRoutes{Asn: "64500", Routes: []pwhois.BGPRoute{
+		{Prefix: "192.0.2.0/24", ModifyDate: testModifyDate, ASPath: []int{64501, 64500}},
+		{Prefix: "2001:db8::/32"},
+	}})...)
+	events = append(events, RegistryEvent(pwhois.RegistryRecord{Asn: "64500", Registry: pwhois.Registry{OrgName: "Example", ModifyDate: testModifyDate}}))
+	events = append(events, NetblockEvents(pwhois.NetblockRecord{Asn: "64500", OrgName: "Example Networks", Netblocks: []pwhois.Netblock{
+		{Name: "EXAMPLE-NET", Range: "192.0.2.0-192.0.2.255", ModifyDate: testModifyDate},
+	}})...)
+
+	tests := []struct {
+		time       time.Time
+		sourceType string
+		fields     map[string]any
+	}{
+		{time: testCacheDate, sourceType: "pwhois:ip", fields: map[string]any{"ip": "192.0.2.1", "origin_asn": "64500"}},
+		{time: testModifyDate, sourceType: "pwhois:routeview", fields: map[string]any{"asn": "64500", "prefix": "192.0.2.0/24", "as_path": []any{64501.0, 64500.0}}},
+		{sourceType: "pwhois:routeview", fields: map[string]any{"asn": "64500", "prefix": "2001:db8::/32"}},
+		{time: testModifyDate, sourceType: "pwhois:registry", fields: map[string]any{"asn": "64500", "org_name": "Example"}},
+		{time: testModifyDate, sourceType: "pwhois:netblock", fields: map[string]any{"asn": "64500", "org_name": "Example Networks", "net_name": "EXAMPLE-NET", "net_range": "192.0.2.0-192.0.2.255"}},
+	}
+	if len(events) != len(tests) {
+		t.Fatalf("events = %d, want %d", len(events), len(tests))
+	}
+	for index, test := range tests {
+		event := events[index]
+		if !event.Time.Equal(test.time) || event.SourceType() != test.sourceType {
+			t.Errorf("event %d = %v %s, want %v %s", index, event.Time, event.SourceType(), test.time, test.sourceType)
+		}
+		encoded, err := json.Marshal(event.Data)
+		var data map[string]any
+		if err != nil || json.Unmarshal(encoded, &data) != nil {
+			t.Fatalf("event %d data = %s, %v", index, encoded, err)
+		}
+		for key, want := range test.fields {
+			if !reflect.DeepEqual(data[key], want) {
+				t.Errorf("event %d %s = %v, want %v", index, key, data[key], want)
+			}
+		}
+	}
+}
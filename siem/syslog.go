@@ -0,0 +1,236 @@
+package siem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/internal/check"
+)
+
+// DefaultSyslogFacility is the facility used when SyslogConfig.Facility is
+// zero: local0.
+const DefaultSyslogFacility int = 16
+
+// DefaultSyslogMaxMessageBytes is the message bound used when
+// SyslogConfig.MaxMessageBytes is zero. RFC 5424 receivers should accept
+// messages of this size over UDP.
+const DefaultSyslogMaxMessageBytes int = 2048
+
+// syslogSeverity is the severity of every message: informational.
+const syslogSeverity = 6
+
+// syslogTimeLayout writes RFC 5424 timestamps in UTC to the millisecond.
+const syslogTimeLayout = "2006-01-02T15:04:05.000Z"
+
+// SyslogConfig configures a SyslogEmitter.
+type SyslogConfig struct {
+	// Network is "udp" or "tcp". Empty uses "udp". TCP messages are framed by
+	// octet counting as described in RFC 6587.
+	Network string
+	// Address is the host and port of the syslog receiver.
+	Address string
+	// Facility is the syslog facility, 1 to 23. Zero uses
+	// DefaultSyslogFacility.
+	Facility int
+	// Hostname and AppName fill the message header. Empty Hostname uses the
+	// local host name and empty AppName uses "pwhois".
+	Hostname string
+	AppName  string
+	// MaxMessageBytes bounds each message; a longer message fails with
+	// pwhois.ErrInvalidInput rather than being truncated into invalid JSON.
+	// Zero uses DefaultSyslogMaxMessageBytes.
+	MaxMessageBytes int
+	// Timeout bounds connecting and writing each message. Zero uses
+	// pwhois.SocketTimeout seconds.
+	Timeout time.Duration
+	// Dialer connects to the receiver. Nil uses a net.Dialer.
+	Dialer pwhois.Dialer
+	// Retry retries each message after a connection failure or timeout,
+	// reconnecting first.
+	Retry pwhois.RetryPolicy
+}
+
+// SyslogEmitter writes events as RFC 5424 syslog messages. It connects on
+// first use and reconnects after a failed write. It is safe for concurrent
+// use.
+type SyslogEmitter struct {
+	config SyslogConfig
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogEmitter validates config and returns an emitter for it. It does
+// not connect.
+func NewSyslogEmitter(config SyslogConfig) (*SyslogEmitter, error) {
+	switch config.Network {
+	case "":
+		config.Network = "udp"
+	case "udp", "tcp":
+	default:
+		return nil, check.InvalidInput(fmt.Sprintf("syslog network %q is not udp or tcp", config.Network))
+	}
+	if _, _, err := net.SplitHostPort(config.Address); err != nil {
+		return nil, check.InvalidInput("syslog address must be host:port")
+	}
+	if config.Facility == 0 {
+		config.Facility = DefaultSyslogFacility
+	}
+	if config.Facility < 1 || config.Facility > 23 {
+		return nil, check.InvalidInput("syslog facility must be between 1 and 23")
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.Hostname == "" {
+		config.Hostname = "-"
+	}
+	if config.AppName == "" {
+		config.AppName = "pwhois"
+	}
+	if err := checkHeaderField("hostname", config.Hostname, 255); err != nil {
+		return nil, err
+	}
+	if err := checkHeaderField("app name", config.AppName, 48); err != nil {
+		return nil, err
+	}
+	if config.MaxMessageBytes < 0 || config.Timeout < 0 {
+		return nil, check.InvalidInput("syslog bounds cannot be negative")
+	}
+	if config.MaxMessageBytes == 0 {
+		config.MaxMessageBytes = DefaultSyslogMaxMessageBytes
+	}
+	if config.Timeout == 0 {
+		config.Timeout = time.Duration(pwhois.SocketTimeout) * time.Second
+	}
+	if config.Dialer == nil {
+		config.Dialer = &net.Dialer{}
+	}
+	return &SyslogEmitter{config: config}, nil
+}
+
+// Format renders event as an RFC 5424 message without transport framing:
+// the header, with the event time as the timestamp and the lookup name as
+// the message ID, no structured data, and the JSON encoding of the event's
+// data as the message.
+func (emitter *SyslogEmitter) Format(event Event) (string, error) {
+	timestamp := "-"
+	if !event.Time.IsZero() {
+		timestamp = event.Time.UTC().Format(syslogTimeLayout)
+	}
+	messageID := event.Lookup
+	if messageID == "" {
+		messageID = "-"
+	}
+	if err := checkHeaderField("message ID", messageID, 32); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return "", check.InvalidInput(err.Error())
+	}
+
+	priority := emitter.config.Facility*8 + syslogSeverity
+	message := fmt.Sprintf("<%d>1 %s %s %s - %s - %s", priority, timestamp, emitter.config.Hostname, emitter.config.AppName, messageID, data)
+	if len(message) > emitter.config.MaxMessageBytes {
+		return "", check.InvalidInput(fmt.Sprintf("syslog message is %d bytes, over the %d byte limit", len(message), emitter.config.MaxMessageBytes))
+	}
+	return message, nil
+}
+
+// Emit writes one message per event, in order, retrying each under the Retry
+// policy. Every event is formatted before the first write, so an event that
+// cannot be formatted sends nothing.
+func (emitter *SyslogEmitter) Emit(ctx context.Context, events ...Event) error {
+	if ctx == nil {
+		return check.InvalidInput("syslog emit context is required")
+	}
+	messages := make([]string, len(events))
+	for index, event := range events {
+		message, err := emitter.Format(event)
+		if err != nil {
+			return fmt.Errorf("format syslog event %d: %w", index+1, err)
+		}
+		messages[index] = message
+	}
+
+	emitter.mu.Lock()
+	defer emitter.mu.Unlock()
+	for index, message := range messages {
+		err := emitter.config.Retry.Do(ctx, func(ctx context.Context) error {
+			return emitter.write(ctx, message)
+		})
+		if err != nil {
+			return fmt.Errorf("write syslog event %d of %d: %w", index+1, len(messages), err)
+		}
+	}
+	return nil
+}
+
+// Close closes the connection to the receiver, if any.
+func (emitter *SyslogEmitter) Close() error {
+	emitter.mu.Lock()
+	defer emitter.mu.Unlock()
+	if emitter.conn == nil {
+		return nil
+	}
+	err := emitter.conn.Close()
+	emitter.conn = nil
+	return err
+}
+
+// write sends one message, connecting first if needed. A failed write drops
+// the connection so the next attempt reconnects.
+func (emitter *SyslogEmitter) write(ctx context.Context, message string) error {
+	ctx, cancel := context.WithTimeout(ctx, emitter.config.Timeout)
+	defer cancel()
+
+	if emitter.conn == nil {
+		conn, err := emitter.config.Dialer.DialContext(ctx, emitter.config.Network, emitter.config.Address)
+		if err != nil {
+			return emitter.operationError(classifyTransportError(ctx, err))
+		}
+		emitter.conn = conn
+	}
+
+	frame := message
+	if emitter.config.Network == "tcp" {
+		frame = strconv.Itoa(len(message)) + " " + message
+	}
+	deadline, _ := ctx.Deadline()
+	err := emitter.conn.SetWriteDeadline(deadline)
+	if err == nil {
+		_, err = emitter.conn.Write([]byte(frame))
+	}
+	if err != nil {
+		_ = emitter.conn.Close()
+		emitter.conn = nil
+		return emitter.operationError(classifyTransportError(ctx, err))
+	}
+	return nil
+}
+
+func (emitter *SyslogEmitter) operationError(err error) error {
+	return &pwhois.OperationError{Operation: "write syslog message", Server: emitter.config.Address, Err: err}
+}
+
+// checkHeaderField reports whether value is a valid RFC 5424 header field:
+// printable US-ASCII without spaces, at most limit bytes.
+func checkHeaderField(name, value string, limit int) error {
+	if value == "" || len(value) > limit {
+		return check.InvalidInput(fmt.Sprintf("syslog %s must be 1 to %d characters", name, limit))
+	}
+	for index := 0; index < len(value); index++ {
+		if value[index] < 33 || value[index] > 126 {
+			return check.InvalidInput(fmt.Sprintf("syslog %s %q must be printable ASCII without spaces", name, value))
+		}
+	}
+	return nil
+}
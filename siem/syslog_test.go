@@ -0,0 +1,151 @@
+package siem
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+)
+
+func TestSyslogFormatsRFC5424Messages(t *testing.T) {
+	emitter, err := NewSyslogEmitter(SyslogConfig{Address: "127.0.0.1:514", Hostname: "sensor-1"})
+	if err != nil {
+		t.Fatalf("NewSyslogEmitter: %v", err)
+	}
+
+	event := WhoIsEvents(pwhois.WhoIs{IP: "192.0.2.1", CacheDate: testCacheDate})[0]
+	message, err := emitter.Format(event)
+	wantPrefix := `<134>1 2026-07-18T00:00:04.000Z sensor-1 pwhois - ip - {"ip":"192.0.2.1",`
+	if err != nil || !strings.HasPrefix(message, wantPrefix) || strings.Contains(message, "\n") {
+		t.Errorf("Format = %q, %v; want prefix %q", message, err, wantPrefix)
+	}
+
+	undated := RouteEvents(pwhois.BGPRoutes{Asn: "64500", Routes: []pwhois.BGPRoute{{Prefix: "192.0.2.0/24"}}})[0]
+	if message, err := emitter.Format(undated); err != nil || !strings.HasPrefix(message, "<134>1 - sensor-1 pwhois - routeview - {") {
+		t.Errorf("Format undated = %q, %v", message, err)
+	}
+
+	oversized := WhoIsEvents(pwhois.WhoIs{IP: "192.0.2.1", OrgName: strings.Repeat("x", DefaultSyslogMaxMessageBytes)})[0]
+	if _, err := emitter.Format(oversized); !errors.Is(err, pwhois.ErrInvalidInput) {
+		t.Errorf("Format oversized error = %v, want ErrInvalidInput", err)
+	}
+	if _, err := emitter.Format(Event{Lookup: "has space", Data: 1}); !errors.Is(err, pwhois.ErrInvalidInput) {
+		t.Errorf("Format bad message ID error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestSyslogEmitterWritesUDPDatagrams(t *testing.T) {
+	receiver, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	defer receiver.Close()
+
+	emitter, err := NewSyslogEmitter(SyslogConfig{Address: receiver.LocalAddr().String(), Hostname: "sensor-1", Facility: 1})
+	if err != nil {
+		t.Fatalf("NewSyslogEmitter: %v", err)
+	}
+	defer emitter.Close()
+	events := WhoIsEvents(pwhois.WhoIs{IP: "192.0.2.1"}, pwhois.WhoIs{IP: "192.0.2.2"})
+	if err := emitter.Emit(context.Background(), events...); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+
+	buffer := make([]byte, DefaultSyslogMaxMessageBytes)
+	for _, event := range events {
+		_ = receiver.SetReadDeadline(time.Now().Add(2 * time.Second))
+		read, _, err := receiver.ReadFrom(buffer)
+		want, _ := emitter.Format(event)
+		if err != nil || string(buffer[:read]) != want || !strings.HasPrefix(want, "<14>1 - sensor-1 ") {
+			t.Errorf("datagram = %q, %v; want %q", buffer[:read], err, want)
+		}
+	}
+}
+
+func TestSyslogEmitterFramesTCPMessages(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	defer listener.Close()
+	received := make(chan string, 4)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			size, _ := strconv.Atoi(strings.TrimSuffix(length, " "))
+			message := make([]byte, size)
+			if _, err := io.ReadFull(reader, message); err != nil {
+				return
+			}
+			received <- string(message)
+		}
+	}()
+
+	emitter, err := NewSyslogEmitter(SyslogConfig{Network: "tcp", Address: listener.Addr().String(), Hostname: "sensor-1"})
+	if err != nil {
+		t.Fatalf("NewSyslogEmitter: %v", err)
+	}
+	defer emitter.Close()
+	events := NetblockEvents(pwhois.NetblockRecord{Asn: "64500", Netblocks: []pwhois.Netblock{{Name: "A"}, {Name: "B"}}})
+	if err := emitter.Emit(context.Background(), events...); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	for _, event := range events {
+		want, _ := emitter.Format(event)
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("message = %q, want %q", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no message for %q", want)
+		}
+	}
+}
+
+func TestSyslogEmitterReportsConnectionFailures(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+
+	emitter, err := NewSyslogEmitter(SyslogConfig{Network: "tcp", Address: address, Retry: pwhois.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}})
+	if err != nil {
+		t.Fatalf("NewSyslogEmitter: %v", err)
+	}
+	err = emitter.Emit(context.Background(), WhoIsEvents(pwhois.WhoIs{IP: "192.0.2.1"})...)
+	var retryError *pwhois.RetryError
+	if !errors.Is(err, pwhois.ErrConnection) || !errors.As(err, &retryError) || len(retryError.Attempts) != 2 {
+		t.Errorf("Emit error = %v, want two connection failures", err)
+	}
+
+	for _, config := range []SyslogConfig{
+		{Address: "localhost"},
+		{Network: "unix", Address: "127.0.0.1:514"},
+		{Address: "127.0.0.1:514", Facility: 24},
+		{Address: "127.0.0.1:514", Hostname: "two words"},
+		{Address: "127.0.0.1:514", AppName: strings.Repeat("a", 49)},
+	} {
+		if _, err := NewSyslogEmitter(config); !errors.Is(err, pwhois.ErrInvalidInput) {
+			t.Errorf("NewSyslogEmitter(%+v) error = %v, want ErrInvalidInput", config, err)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package pwhois
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+const (
+	socks5Version            byte = 0x05
+	socks5MethodNoAuth       byte = 0x00
+	socks5MethodPassword     byte = 0x02
+	socks5MethodNoAcceptable byte = 0xff
+	socks5PasswordVersion    byte = 0x01
+	socks5CommandConnect     byte = 0x01
+	socks5AddressIPv4        byte = 0x01
+	socks5AddressDomain      byte = 0x03
+	socks5AddressIPv6        byte = 0x04
+	socks5ReplySucceeded     byte = 0x00
+)
+
+// SOCKS5Dialer connects through a SOCKS5 proxy (RFC 1928) with the CONNECT
+// command. Hostnames are sent to the proxy unresolved so name resolution
+// follows the proxy's egress policy. The zero value is not usable; set
+// ProxyAddress.
+type SOCKS5Dialer struct {
+	// ProxyAddress is the proxy's host:port.
+	ProxyAddress string
+	// Username and Password enable RFC 1929 username/password
+	// authentication when Username is not empty.
+	Username string
+	Password string
+	// Forward dials the proxy itself. A nil value dials TCP directly.
+	Forward Dialer
+}
+
+// SOCKS5Error reports a proxy that refused a CONNECT request. Reply is the
+// RFC 1928 reply code.
+type SOCKS5Error struct {
+	Reply byte
+}
+
+func (err *SOCKS5Error) Error() string {
+	return fmt.Sprintf("socks5 proxy refused connection: %s", socks5ReplyText(err.Reply))
+}
+
+func socks5ReplyText(reply byte) string {
+	switch reply {
+	case 0x01:
+		return "general failure"
+	case 0x02:
+		return "connection not allowed by ruleset"
+	case 0x03:
+		return "network unreachable"
+	case 0x04:
+		return "host unreachable"
+	case 0x05:
+		return "connection refused"
+	case 0x06:
+		return "TTL expired"
+	case 0x07:
+		return "command not supported"
+	case 0x08:
+		return "address type not supported"
+	default:
+		return "reply code " + strconv.Itoa(int(reply))
+	}
+}
+
+// DialContext connects to address through the proxy. Only TCP networks are
+// supported. ctx bounds both the proxy connection and the SOCKS5 handshake.
+func (dialer *SOCKS5Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, invalidInputError(fmt.Sprintf("socks5 dialer does not support network %q", network))
+	}
+	if dialer.ProxyAddress == "" {
+		return nil, invalidInputError("socks5 proxy address is required")
+	}
+	if len(dialer.Username) > 255 || len(dialer.Password) > 255 {
+		return nil, invalidInputError("socks5 username and password are limited to 255 bytes")
+	}
+	request, err := socks5ConnectRequest(address)
+	if err != nil {
+		return nil, err
+	}
+
+	forward := dialer.Forward
+	if forward == nil {
+		forward = &net.Dialer{KeepAlive: time.Second * time.Duration(SocketKeepAlive)}
+	}
+	connection, err := forward.DialContext(ctx, "tcp", dialer.ProxyAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dialer.handshake(ctx, connection, request); err != nil {
+		_ = connection.Close()
+		if contextErr := ctx.Err(); contextErr != nil && !errors.Is(err, contextErr) {
+			return nil, errors.Join(contextErr, err)
+		}
+		return nil, err
+	}
+	return connection, nil
+}
+
+// socks5ConnectRequest encodes the CONNECT request for address, preferring
+// literal IPv4 and IPv6 address types over a domain name.
+func socks5ConnectRequest(address string) ([]byte, error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, invalidInputError("socks5 target address must be host:port")
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil || port == 0 {
+		return nil, invalidInputError("socks5 target port must be between 1 and 65535")
+	}
+
+	request := []byte{socks5Version, socks5CommandConnect, 0x00}
+	if ip, err := netip.ParseAddr(host); err == nil && ip.Zone() == "" {
+		ip = ip.Unmap()
+		if ip.Is4() {
+			request = append(request, socks5AddressIPv4)
+		} else {
+			request = append(request, socks5AddressIPv6)
+		}
+		request = append(request, ip.AsSlice()...)
+	} else {
+		if host == "" || len(host) > 255 {
+			return nil, invalidInputError("socks5 target host must be between 1 and 255 bytes")
+		}
+		request = append(request, socks5AddressDomain, byte(len(host)))
+		request = append(request, host...)
+	}
+	return append(request, byte(port>>8), byte(port)), nil
+}
+
+func (dialer *SOCKS5Dialer) handshake(ctx context.Context, connection net.Conn, request []byte) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := connection.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = connection.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	methods := []byte{socks5MethodNoAuth}
+	if dialer.Username != "" {
+		methods = []byte{socks5MethodPassword}
+	}
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err := connection.Write(greeting); err != nil {
+		return err
+	}
+
+	var selection [2]byte
+	if _, err := io.ReadFull(connection, selection[:]); err != nil {
+		return err
+	}
+	if selection[0] != socks5Version {
+		return fmt.Errorf("socks5 proxy replied with version %d", selection[0])
+	}
+	switch selection[1] {
+	case socks5MethodNoAuth:
+		if dialer.Username != "" {
+			return errors.New("socks5 proxy skipped required authentication")
+		}
+	case socks5MethodPassword:
+		if dialer.Username == "" {
+			return errors.New("socks5 proxy requires username/password authentication")
+		}
+		if err := dialer.authenticate(connection); err != nil {
+			return err
+		}
+	case socks5MethodNoAcceptable:
+		return errors.New("socks5 proxy rejected all authentication methods")
+	default:
+		return fmt.Errorf("socks5 proxy selected unsupported method %d", selection[1])
+	}
+
+	if _, err := connection.Write(request); err != nil {
+		return err
+	}
+	if err := readSOCKS5Reply(connection); err != nil {
+		return err
+	}
+
+	if stop() {
+		return connection.SetDeadline(time.Time{})
+	}
+	return ctx.Err()
+}
+
+func (dialer *SOCKS5Dialer) authenticate(connection net.Conn) error {
+	request := []byte{socks5PasswordVersion, byte(len(dialer.Username))}
+	request = append(request, dialer.Username...)
+	request = append(request, byte(len(dialer.Password)))
+	request = append(request, dialer.Password...)
+	if _, err := connection.Write(request); err != nil {
+		return err
+	}
+
+	var response [2]byte
+	if _, err := io.ReadFull(connection, response[:]); err != nil {
+		return err
+	}
+	if response[0] != socks5PasswordVersion {
+		return fmt.Errorf("socks5 proxy replied to authentication with version %d", response[0])
+	}
+	if response[1] != 0x00 {
+		return errors.New("socks5 proxy rejected username/password authentication")
+	}
+	return nil
+}
+
+// readSOCKS5Reply reads a complete CONNECT reply, including the bound address
+// the proxy reports, so no reply bytes precede the PWHOIS response.
+func readSOCKS5Reply(connection net.Conn) error {
+	var header [4]byte
+	if _, err := io.ReadFull(connection, header[:]); err != nil {
+		return err
+	}
+	if header[0] != socks5Version {
+		return fmt.Errorf("socks5 proxy replied with version %d", header[0])
+	}
+	if header[1] != socks5ReplySucceeded {
+		return &SOCKS5Error{Reply: header[1]}
+	}
+
+	var addressLength int
+	switch header[3] {
+	case socks5AddressIPv4:
+		addressLength = net.IPv4len
+	case socks5AddressIPv6:
+		addressLength = net.IPv6len
+	case socks5AddressDomain:
+		var length [1]byte
+		if _, err := io.ReadFull(connection, length[:]); err != nil {
+			return err
+		}
+		addressLength = int(length[0])
+	default:
+		return fmt.Errorf("socks5 proxy replied with unsupported address type %d", header[3])
+	}
+
+	bound := make([]byte, addressLength+2)
+	_, err := io.ReadFull(connection, bound)
+	return err
+}
@@ -0,0 +1,344 @@
+package pwhois
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type socks5ProxyScript struct {
+	username string
+	password string
+	reply    byte
+	// authVersion, when set, replaces the authentication reply's version
+	// byte, and the proxy expects the client to hang up after it.
+	authVersion byte
+}
+
+type socks5ProxyResult struct {
+	target string
+	err    error
+}
+
+// startSOCKS5Proxy runs a one-connection, loopback-only SOCKS5 stand-in
+// proxy. It authenticates when the script names a username, records the
+// requested target, and relays bytes with orderly half-closes in each
+// direction so the PWHOIS EOF contract survives the proxy hop.
+func startSOCKS5Proxy(t *testing.T, script socks5ProxyScript) (string, <-chan socks5ProxyResult) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	results := make(chan socks5ProxyResult, 1)
+
+	go func() {
+		result := socks5ProxyResult{}
+		defer func() { results <- result }()
+
+		client, acceptErr := listener.Accept()
+		if acceptErr != nil {
+			result.err = acceptErr
+			return
+		}
+		defer client.Close()
+		_ = client.SetDeadline(time.Now().Add(3 * time.Second))
+
+		result.target, result.err = serveSOCKS5Handshake(client, script)
+		if result.err != nil || result.target == "" || script.reply != socks5ReplySucceeded {
+			return
+		}
+
+		target, dialErr := net.Dial("tcp", result.target)
+		if dialErr != nil {
+			result.err = dialErr
+			return
+		}
+		defer target.Close()
+		_ = client.SetDeadline(time.Time{})
+
+		done := make(chan struct{})
+		go func() {
+			_, _ = io.Copy(target, client)
+			_ = target.(*net.TCPConn).CloseWrite()
+			close(done)
+		}()
+		_, _ = io.Copy(client, target)
+		_ = client.(*net.TCPConn).CloseWrite()
+		<-done
+	}()
+
+	return listener.Addr().String(), results
+}
+
+func serveSOCKS5Handshake(client net.Conn, script socks5ProxyScript) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(client, header); err != nil {
+		return "", fmt.Errorf("read greeting: %w", err)
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(client, methods); err != nil {
+		return "", fmt.Errorf("read methods: %w", err)
+	}
+
+	wantMethod := socks5MethodNoAuth
+	if script.username != "" {
+		wantMethod = socks5MethodPassword
+	}
+	if !bytes.Contains(methods, []byte{wantMethod}) {
+		_, _ = client.Write([]byte{socks5Version, socks5MethodNoAcceptable})
+		return "", nil
+	}
+	if _, err := client.Write([]byte{socks5Version, wantMethod}); err != nil {
+		return "", err
+	}
+
+	if wantMethod == socks5MethodPassword {
+		credentials := make([]byte, 2)
+		if _, err := io.ReadFull(client, credentials); err != nil {
+			return "", err
+		}
+		username := make([]byte, credentials[1])
+		if _, err := io.ReadFull(client, username); err != nil {
+			return "", err
+		}
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(client, length); err != nil {
+			return "", err
+		}
+		password := make([]byte, length[0])
+		if _, err := io.ReadFull(client, password); err != nil {
+			return "", err
+		}
+		status := byte(0x00)
+		if string(username) != script.username || string(password) != script.password {
+			status = 0x01
+		}
+		if script.authVersion != 0 {
+			if _, err := client.Write([]byte{script.authVersion, status}); err != nil {
+				return "", err
+			}
+			if _, err := io.ReadFull(client, make([]byte, 4)); err == nil {
+				return "", errors.New("client sent a request after a bad authentication version")
+			}
+			return "", nil
+		}
+		if _, err := client.Write([]byte{socks5PasswordVersion, status}); err != nil || status != 0x00 {
+			return "", err
+		}
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(client, request); err != nil {
+		return "", fmt.Errorf("read request: %w", err)
+	}
+	var host string
+	switch request[3] {
+	case socks5AddressIPv4:
+		address := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(client, address); err != nil {
+			return "", err
+		}
+		host = net.IP(address).String()
+	case socks5AddressDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(client, length); err != nil {
+			return "", err
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(client, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		return "", fmt.Errorf("unexpected address type %d", request[3])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(client, port); err != nil {
+		return "", err
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1])))
+
+	reply := []byte{socks5Version, script.reply, 0x00, socks5AddressIPv4, 0, 0, 0, 0, 0, 0}
+	_, err := client.Write(reply)
+	return target, err
+}
+
+func TestClientLookupThroughSOCKS5Proxy(t *testing.T) {
+	const request = "app=\"GO pwhois Module\" registry source-as=64500\n"
+
+	tests := []struct {
+		name   string
+		script socks5ProxyScript
+		dialer SOCKS5Dialer
+	}{
+		{name: "no authentication"},
+		{
+			name:   "username and password",
+			script: socks5ProxyScript{username: "analyst", password: "example-secret"},
+			dialer: SOCKS5Dialer{Username: "analyst", Password: "example-secret"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, results := startLoopbackProtocolServer(t, loopbackProtocolScript{
+				expectedRequest: request,
+				responseChunks:  []string{"Org-ID: TEST\nOrg-Name: Example Registry Organization\n"},
+			})
+			proxyAddress, proxyResults := startSOCKS5Proxy(t, test.script)
+			dialer := test.dialer
+			dialer.ProxyAddress = proxyAddress
+			server.Dialer = &dialer
+
+			client, err := NewClient(ClientConfig{Server: server})
+			if err != nil {
+				t.Fatalf("new client: %v", err)
+			}
+			record, err := client.LookupRegistry(context.Background(), "64500")
+			if err != nil {
+				t.Fatalf("lookup through proxy: %v", err)
+			}
+			if record.Registry.OrgID != "TEST" {
+				t.Fatalf("registry response = %+v", record)
+			}
+			waitForLoopbackProtocol(t, results, request)
+
+			proxyResult := <-proxyResults
+			if proxyResult.err != nil {
+				t.Fatalf("proxy: %v", proxyResult.err)
+			}
+			if got, want := proxyResult.target, server.ServerAddressString(); got != want {
+				t.Errorf("proxy target = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSOCKS5DialerFailuresAreConnectionErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script socks5ProxyScript
+		dialer SOCKS5Dialer
+		check  func(*testing.T, error)
+	}{
+		{
+			name:   "refused",
+			script: socks5ProxyScript{reply: 0x05},
+			check: func(t *testing.T, err error) {
+				var socksError *SOCKS5Error
+				if !errors.As(err, &socksError) || socksError.Reply != 0x05 {
+					t.Fatalf("error = %v, want *SOCKS5Error with reply 5", err)
+				}
+			},
+		},
+		{
+			name:   "bad credentials",
+			script: socks5ProxyScript{username: "analyst", password: "expected"},
+			dialer: SOCKS5Dialer{Username: "analyst", Password: "wrong"},
+		},
+		{
+			name:   "bad authentication version",
+			script: socks5ProxyScript{username: "analyst", password: "expected", authVersion: socks5Version},
+			dialer: SOCKS5Dialer{Username: "analyst", Password: "expected"},
+		},
+		{
+			name:   "authentication required",
+			script: socks5ProxyScript{username: "analyst", password: "expected"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			proxyAddress, proxyResults := startSOCKS5Proxy(t, test.script)
+			dialer := test.dialer
+			dialer.ProxyAddress = proxyAddress
+			server := WhoisServer{Server: "192.0.2.1", Port: 43, Timeout: time.Second, Dialer: &dialer}
+
+			err := server.Connect()
+			if !errors.Is(err, ErrConnection) {
+				t.Fatalf("connect error = %v, want ErrConnection", err)
+			}
+			assertOperationError(t, err, "connect", "192.0.2.1:43")
+			if test.check != nil {
+				test.check(t, err)
+			}
+			if server.Connection != nil {
+				t.Fatal("failed connect left a connection")
+			}
+			if result := <-proxyResults; result.err != nil {
+				t.Fatalf("proxy: %v", result.err)
+			}
+		})
+	}
+}
+
+func TestSOCKS5ConnectRequestAddressTypes(t *testing.T) {
+	tests := []struct {
+		address string
+		want    []byte
+		wantErr bool
+	}{
+		{address: "192.0.2.1:43", want: []byte{5, 1, 0, 1, 192, 0, 2, 1, 0, 43}},
+		{address: "[::ffff:192.0.2.1]:43", want: []byte{5, 1, 0, 1, 192, 0, 2, 1, 0, 43}},
+		{address: "[2001:db8::1]:43", want: append(append([]byte{5, 1, 0, 4}, net.ParseIP("2001:db8::1").To16()...), 0, 43)},
+		{address: "whois.example:43", want: append(append([]byte{5, 1, 0, 3, 13}, "whois.example"...), 0, 43)},
+		{address: "whois.example", wantErr: true},
+		{address: "whois.example:0", wantErr: true},
+		{address: "whois.example:65536", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			got, err := socks5ConnectRequest(test.address)
+			if test.wantErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("error = %v, want ErrInvalidInput", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("encode request: %v", err)
+			}
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("request = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSOCKS5DialerHonorsContext(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on loopback: %v", err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		connection, acceptErr := listener.Accept()
+		if acceptErr == nil {
+			accepted <- connection
+		}
+	}()
+
+	dialer := &SOCKS5Dialer{ProxyAddress: listener.Addr().String()}
+	server := WhoisServer{Server: "192.0.2.1", Port: 43, Timeout: 100 * time.Millisecond, Dialer: dialer}
+	err = server.Connect()
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("connect to silent proxy error = %v, want ErrTimeout", err)
+	}
+	select {
+	case connection := <-accepted:
+		connection.Close()
+	case <-time.After(time.Second):
+		t.Fatal("silent proxy never accepted a connection")
+	}
+}
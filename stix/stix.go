@@ -0,0 +1,464 @@
+// Package stix exports pwhois lookup results as STIX 2.1 objects.
+//
+// A Builder collects WhoIs, RegistryRecord, and NetblockRecord values and
+// renders them as a Bundle of autonomous-system, ipv4-addr, and ipv6-addr
+// cyber observables, location and identity objects, and the relationships
+// between them. Every identifier is a UUIDv5 derived from the object's
+// content, with observables using the STIX namespace over their
+// ID-contributing properties, so exporting the same records always yields
+// the same bundle and repeated exports deduplicate in a threat intelligence
+// platform.
+package stix
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+	"github.com/georgestarcher/pwhois/internal/check"
+	"github.com/georgestarcher/pwhois/internal/uuid"
+)
+
+// SpecVersion is the STIX version of every exported object.
+const SpecVersion = "2.1"
+
+// timestampLayout writes STIX timestamps in UTC to the millisecond.
+const timestampLayout = "2006-01-02T15:04:05.000Z"
+
+// Relationship types used between exported objects.
+const (
+	RelationshipLocatedAt = "located-at"
+	RelationshipRelatedTo = "related-to"
+)
+
+var (
+	// observableNamespace is the STIX 2.1 namespace for deterministic cyber
+	// observable identifiers.
+	observableNamespace = uuid.MustParse("00abedb4-aa42-466c-9c01-fed23315a9b7")
+	// objectNamespace names the identifiers of the other objects and bundles
+	// this package exports.
+	objectNamespace = uuid.V5(uuid.URLNamespace, "https://github.com/georgestarcher/pwhois/stix")
+)
+
+// Bundle is a STIX bundle. Objects are sorted by ID.
+type Bundle struct {
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Objects []any  `json:"objects,omitempty"`
+}
+
+// AutonomousSystem is an autonomous-system cyber observable.
+type AutonomousSystem struct {
+	Type        string `json:"type"`
+	SpecVersion string `json:"spec_version"`
+	ID          string `json:"id"`
+	Number      uint32 `json:"number"`
+	Name        string `json:"name,omitempty"`
+	RIR         string `json:"rir,omitempty"`
+}
+
+// Address is an ipv4-addr or ipv6-addr cyber observable for an address or a
+// CIDR prefix. BelongsToRefs names the autonomous systems that originate it.
+type Address struct {
+	Type          string   `json:"type"`
+	SpecVersion   string   `json:"spec_version"`
+	ID            string   `json:"id"`
+	Value         string   `json:"value"`
+	BelongsToRefs []string `json:"belongs_to_refs,omitempty"`
+}
+
+// Location is a location domain object for the geolocation of an address.
+type Location struct {
+	Type               string   `json:"type"`
+	SpecVersion        string   `json:"spec_version"`
+	ID                 string   `json:"id"`
+	Created            string   `json:"created"`
+	Modified           string   `json:"modified"`
+	Latitude           *float64 `json:"latitude,omitempty"`
+	Longitude          *float64 `json:"longitude,omitempty"`
+	Country            string   `json:"country,omitempty"`
+	AdministrativeArea string   `json:"administrative_area,omitempty"`
+	City               string   `json:"city,omitempty"`
+}
+
+// Identity is an identity domain object for the organization of a registry
+// record.
+type Identity struct {
+	Type               string `json:"type"`
+	SpecVersion        string `json:"spec_version"`
+	ID                 string `json:"id"`
+	Created            string `json:"created"`
+	Modified           string `json:"modified"`
+	Name               string `json:"name"`
+	IdentityClass      string `json:"identity_class"`
+	ContactInformation string `json:"contact_information,omitempty"`
+	Description        string `json:"description,omitempty"`
+}
+
+// Relationship is a relationship object between two exported objects.
+type Relationship struct {
+	Type             string `json:"type"`
+	SpecVersion      string `json:"spec_version"`
+	ID               string `json:"id"`
+	Created          string `json:"created"`
+	Modified         string `json:"modified"`
+	RelationshipType string `json:"relationship_type"`
+	SourceRef        string `json:"source_ref"`
+	TargetRef        string `json:"target_ref"`
+}
+
+// Config configures a Builder.
+type Config struct {
+	// Time is the created and modified time of domain objects and
+	// relationships whose record carries no date of its own. When it is zero,
+	// adding such a record fails with pwhois.ErrInvalidInput.
+	Time time.Time
+}
+
+// Builder accumulates STIX objects from pwhois records. Objects with the same
+// ID are merged: an autonomous system keeps the first non-empty name and
+// RIR, an address keeps the union of its references, and a domain object or
+// relationship keeps its earliest created and latest modified time. A
+// Builder is not safe for concurrent use.
+type Builder struct {
+	config  Config
+	objects map[string]any
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder(config Config) *Builder {
+	return &Builder{config: config, objects: make(map[string]any)}
+}
+
+// AddWhoIs adds IP lookup records. Each record adds an address observable
+// for its IP and, when present, its prefix, both belonging to an
+// autonomous-system observable for each ASN of OriginAS, named by
+// AsnOrgName. A record with coordinates or a country code also adds a
+// location, dated by CacheDate, that the IP is located-at.
+func (builder *Builder) AddWhoIs(records ...pwhois.WhoIs) error {
+	for index, record := range records {
+		if err := builder.addWhoIs(record); err != nil {
+			return fmt.Errorf("add IP record %d: %w", index+1, err)
+		}
+	}
+	return nil
+}
+
+func (builder *Builder) addWhoIs(record pwhois.WhoIs) error {
+	addr := record.IPAddr
+	if record.IP != "" {
+		parsed, err := netip.ParseAddr(record.IP)
+		if err != nil {
+			return check.InvalidInput(fmt.Sprintf("IP %q is not an address", record.IP))
+		}
+		addr = parsed
+	}
+	if !addr.IsValid() {
+		return check.InvalidInput("IP record has no IP")
+	}
+
+	var asRefs []string
+	origins := strings.Fields(record.OriginAS)
+	if len(origins) == 0 {
+		if origin, ok := record.ASPath.Origin(); ok {
+			origins = []string{strconv.FormatUint(uint64(origin), 10)}
+		}
+	}
+	for _, origin := range origins {
+		number, err := check.ParseASN(origin)
+		if err != nil {
+			return err
+		}
+		asRefs = append(asRefs, builder.addAutonomousSystem(number, record.AsnOrgName, ""))
+	}
+
+	addrID := builder.addAddress(addr.Unmap().String(), addr.Unmap().Is4(), asRefs)
+	prefix := record.PrefixNet
+	if record.Prefix != "" {
+		parsed, err := netip.ParsePrefix(record.Prefix)
+		if err != nil {
+			return check.InvalidInput(fmt.Sprintf("prefix %q is not a CIDR prefix", record.Prefix))
+		}
+		prefix = parsed
+	}
+	if prefix.IsValid() {
+		builder.addAddress(prefix.Masked().String(), prefix.Addr().Is4(), asRefs)
+	}
+
+	hasCoordinates := record.Latitude != 0 || record.Longitude != 0
+	if !hasCoordinates && record.CountryCode == "" {
+		return nil
+	}
+	timestamp, err := builder.timestamp(record.CacheDate)
+	if err != nil {
+		return err
+	}
+	location := Location{
+		Type:               "location",
+		SpecVersion:        SpecVersion,
+		Created:            timestamp,
+		Modified:           timestamp,
+		Country:            record.CountryCode,
+		AdministrativeArea: record.Region,
+		City:               record.City,
+	}
+	if hasCoordinates {
+		latitude, longitude := record.Latitude, record.Longitude
+		location.Latitude, location.Longitude = &latitude, &longitude
+	}
+	location.ID = objectID("location", "location", formatFloat(record.Latitude, hasCoordinates), formatFloat(record.Longitude, hasCoordinates),
+		location.Country, location.AdministrativeArea, location.City)
+	builder.add(location.ID, location)
+	builder.addRelationship(RelationshipLocatedAt, addrID, location.ID, timestamp, timestamp)
+	return nil
+}
+
+// AddRegistry adds a registry record: an autonomous-system observable for
+// Asn with Source as its RIR and, when the registry names an organization,
+// an identity for it that the autonomous system is related-to. The identity
+// is created at CreateDate, or RegisterDate, and modified at the later of
+// ModifyDate and UpdateDate.
+func (builder *Builder) AddRegistry(record pwhois.RegistryRecord) error {
+	number, err := check.ParseASN(record.Asn)
+	if err != nil {
+		return fmt.Errorf("add registry record: %w", err)
+	}
+	registry := record.Registry
+	asID := builder.addAutonomousSystem(number, "", registry.Source)
+
+	name := registry.OrgName
+	if name == "" {
+		name = registry.OrgID
+	}
+	if name == "" {
+		return nil
+	}
+
+	createdAt := registry.CreateDate
+	if createdAt.IsZero() {
+		createdAt = registry.RegisterDate
+	}
+	modifiedAt := latest(createdAt, registry.ModifyDate, registry.UpdateDate)
+	if createdAt.IsZero() {
+		createdAt = modifiedAt
+	}
+	created, err := builder.timestamp(createdAt)
+	if err != nil {
+		return fmt.Errorf("add registry record: %w", err)
+	}
+	modified, _ := builder.timestamp(modifiedAt)
+
+	var contact []string
+	for _, part := range []string{registry.Street1, registry.City, registry.Region, registry.PostalCode, registry.Country} {
+		if part != "" {
+			contact = append(contact, part)
+		}
+	}
+	identity := Identity{
+		Type:               "identity",
+		SpecVersion:        SpecVersion,
+		Created:            created,
+		Modified:           modified,
+		Name:               name,
+		IdentityClass:      "organization",
+		ContactInformation: strings.Join(contact, ", "),
+		Description:        registry.Comment,
+	}
+	identity.ID = objectID("identity", "identity", registry.Source, registry.OrgID, name)
+	builder.add(identity.ID, identity)
+	builder.addRelationship(RelationshipRelatedTo, asID, identity.ID, created, modified)
+	return nil
+}
+
+// AddNetblocks adds a netblock record: an autonomous-system observable for
+// its AS, or OriginAs or Asn when AS is not set, with ASSource as its RIR,
+// and one address observable per CIDR prefix of each netblock's range, each
+// belonging to that autonomous system.
+func (builder *Builder) AddNetblocks(record pwhois.NetblockRecord) error {
+	asn := record.Asn
+	switch {
+	case record.AS > 0:
+		asn = strconv.FormatInt(record.AS, 10)
+	case record.OriginAs != "":
+		asn = record.OriginAs
+	}
+	number, err := check.ParseASN(asn)
+	if err != nil {
+		return fmt.Errorf("add netblock record: %w", err)
+	}
+	asRefs := []string{builder.addAutonomousSystem(number, "", record.ASSource)}
+
+	for index, block := range record.Netblocks {
+		prefixes := block.Prefixes()
+		if len(prefixes) == 0 {
+			return fmt.Errorf("add netblock %d: %w", index+1, check.InvalidInput(fmt.Sprintf("netblock %q has no address range", block.Name)))
+		}
+		for _, prefix := range prefixes {
+			builder.addAddress(prefix.String(), prefix.Addr().Is4(), asRefs)
+		}
+	}
+	return nil
+}
+
+// Bundle returns the accumulated objects as a bundle whose ID is derived from
+// the IDs of its objects.
+func (builder *Builder) Bundle() Bundle {
+	ids := make([]string, 0, len(builder.objects))
+	for id := range builder.objects {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	bundle := Bundle{Type: "bundle", ID: objectID("bundle", append([]string{"bundle"}, ids...)...)}
+	for _, id := range ids {
+		bundle.Objects = append(bundle.Objects, builder.objects[id])
+	}
+	return bundle
+}
+
+func (builder *Builder) addAutonomousSystem(number uint32, name, rir string) string {
+	system := AutonomousSystem{
+		Type:        "autonomous-system",
+		SpecVersion: SpecVersion,
+		ID:          observableID("autonomous-system", map[string]any{"number": number}),
+		Number:      number,
+		Name:        name,
+		RIR:         rir,
+	}
+	if existing, ok := builder.objects[system.ID].(AutonomousSystem); ok {
+		if existing.Name == "" {
+			existing.Name = name
+		}
+		if existing.RIR == "" {
+			existing.RIR = rir
+		}
+		system = existing
+	}
+	builder.objects[system.ID] = system
+	return system.ID
+}
+
+func (builder *Builder) addAddress(value string, ipv4 bool, belongsTo []string) string {
+	kind := "ipv6-addr"
+	if ipv4 {
+		kind = "ipv4-addr"
+	}
+	address := Address{
+		Type:        kind,
+		SpecVersion: SpecVersion,
+		ID:          observableID(kind, map[string]any{"value": value}),
+		Value:       value,
+	}
+	if existing, ok := builder.objects[address.ID].(Address); ok {
+		address = existing
+	}
+	address.BelongsToRefs = mergeRefs(address.BelongsToRefs, belongsTo)
+	builder.objects[address.ID] = address
+	return address.ID
+}
+
+func (builder *Builder) addRelationship(kind, source, target, created, modified string) {
+	relationship := Relationship{
+		Type:             "relationship",
+		SpecVersion:      SpecVersion,
+		ID:               objectID("relationship", "relationship", kind, source, target),
+		Created:          created,
+		Modified:         modified,
+		RelationshipType: kind,
+		SourceRef:        source,
+		TargetRef:        target,
+	}
+	builder.add(relationship.ID, relationship)
+}
+
+// add stores a domain object or relationship, merging its dates with an
+// object of the same ID.
+func (builder *Builder) add(id string, object any) {
+	switch existing := builder.objects[id].(type) {
+	case Location:
+		merged := object.(Location)
+		merged.Created, merged.Modified = mergeDates(existing.Created, existing.Modified, merged.Created, merged.Modified)
+		object = merged
+	case Identity:
+		merged := object.(Identity)
+		merged.Created, merged.Modified = mergeDates(existing.Created, existing.Modified, merged.Created, merged.Modified)
+		object = merged
+	case Relationship:
+		merged := object.(Relationship)
+		merged.Created, merged.Modified = mergeDates(existing.Created, existing.Modified, merged.Created, merged.Modified)
+		object = merged
+	}
+	builder.objects[id] = object
+}
+
+// timestamp formats value, or the configured Time when value is zero.
+func (builder *Builder) timestamp(value time.Time) (string, error) {
+	if value.IsZero() {
+		value = builder.config.Time
+	}
+	if value.IsZero() {
+		return "", check.InvalidInput("record has no date and no Config.Time is set")
+	}
+	return value.UTC().Format(timestampLayout), nil
+}
+
+func mergeDates(created, modified, otherCreated, otherModified string) (string, string) {
+	// Timestamps share one fixed-width layout, so they order as strings.
+	if otherCreated < created {
+		created = otherCreated
+	}
+	if otherModified > modified {
+		modified = otherModified
+	}
+	return created, modified
+}
+
+func mergeRefs(refs, more []string) []string {
+	for _, ref := range more {
+		found := false
+		for _, existing := range refs {
+			found = found || existing == ref
+		}
+		if !found {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+func latest(times ...time.Time) time.Time {
+	var last time.Time
+	for _, value := range times {
+		if value.After(last) {
+			last = value
+		}
+	}
+	return last
+}
+
+func formatFloat(value float64, present bool) string {
+	if !present {
+		return ""
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// observableID derives a cyber observable ID from its ID-contributing
+// properties as the STIX specification requires. With a single property,
+// encoding/json produces the canonical JSON form.
+func observableID(kind string, properties map[string]any) string {
+	canonical, _ := json.Marshal(properties)
+	return kind + "--" + uuid.V5(observableNamespace, string(canonical)).String()
+}
+
+// objectID derives the ID of a non-observable object from the parts that
+// identify it.
+func objectID(kind string, parts ...string) string {
+	return kind + "--" + uuid.V5(objectNamespace, strings.Join(parts, "\x00")).String()
+}
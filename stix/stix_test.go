@@ -0,0 +1,208 @@
+package stix
+
+import (
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/georgestarcher/pwhois"
+)
+
+var (
+	testWhoIs = []pwhois.WhoIs{
+		{
+			IP: "192.0.2.1", OriginAS: "64500", Prefix: "192.0.2.0/24", AsnOrgName: "Example AS",
+			CacheDate: time.Date(2026, 7, 18, 0, 0, 4, 0, time.UTC), Latitude: 37.751, Longitude: -97.822,
+			City: "Wichita", Region: "Kansas", CountryCode: "US",
+		},
+		{IP: "2001:db8::1", OriginAS: "64500", Prefix: "2001:db8::/32"},
+	}
+	testRegistry = pwhois.RegistryRecord{Asn: "AS64500", Registry: pwhois.Registry{
+		OrgID: "EXAMPLE", OrgName: "Example Networks", Source: "ARIN", Street1: "1 Example Way", City: "Wichita",
+		Region: "KS", PostalCode: "01234", Country: "United States", Comment: "Example comment",
+		CreateDate: time.Date(2019, 6, 28, 16, 53, 1, 0, time.UTC), UpdateDate: time.Date(2019, 9, 25, 0, 0, 0, 0, time.UTC),
+		ModifyDate: time.Date(2026, 7, 18, 3, 19, 32, 0, time.UTC),
+	}}
+	testNetblocks = pwhois.NetblockRecord{Asn: "64500", AS: 64500, ASSource: "ARIN", Netblocks: []pwhois.Netblock{{
+		Name: "EXAMPLE-NET", RangeStart: netip.MustParseAddr("198.51.100.0"), RangeEnd: netip.MustParseAddr("198.51.101.127"),
+	}}}
+)
+
+func TestBuilderExportsDeterministicBundle(t *testing.T) {
+	builder := NewBuilder(Config{})
+	if err := builder.AddWhoIs(testWhoIs...); err != nil {
+		t.Fatalf("AddWhoIs: %v", err)
+	}
+	if err := builder.AddRegistry(testRegistry); err != nil {
+		t.Fatalf("AddRegistry: %v", err)
+	}
+	if err := builder.AddNetblocks(testNetblocks); err != nil {
+		t.Fatalf("AddNetblocks: %v", err)
+	}
+	bundle := builder.Bundle()
+
+	// Observable IDs follow the STIX 2.1 UUIDv5 derivation, so other tools
+	// derive the same IDs for the same addresses and ASNs.
+	const (
+		asID   = "autonomous-system--0a5f7072-df00-5729-9f0a-4eb18631a446"
+		ipv4ID = "ipv4-addr--8dded90c-40c0-545a-8027-5b212bb37e8e"
+		ipv6ID = "ipv6-addr--6469e3a9-b053-5e34-a025-9396ae051d26"
+	)
+	objects := make(map[string]any)
+	counts := make(map[string]int)
+	for _, object := range bundle.Objects {
+		encoded, _ := json.Marshal(object)
+		var fields struct{ Type, ID string }
+		_ = json.Unmarshal(encoded, &fields)
+		objects[fields.ID] = object
+		counts[fields.Type]++
+	}
+	if want := map[string]int{"autonomous-system": 1, "ipv4-addr": 4, "ipv6-addr": 2, "location": 1, "identity": 1, "relationship": 2}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("object counts = %v, want %v", counts, want)
+	}
+
+	system, _ := objects[asID].(AutonomousSystem)
+	if system.Number != 64500 || system.Name != "Example AS" || system.RIR != "ARIN" {
+		t.Errorf("autonomous system = %+v", system)
+	}
+	address, _ := objects[ipv4ID].(Address)
+	if address.Value != "192.0.2.1" || !reflect.DeepEqual(address.BelongsToRefs, []string{asID}) {
+		t.Errorf("ipv4 address = %+v", address)
+	}
+	if address, _ := objects[ipv6ID].(Address); address.Value != "2001:db8::1" {
+		t.Errorf("ipv6 address = %+v", address)
+	}
+
+	var location Location
+	var identity Identity
+	relationships := make(map[string]Relationship)
+	for _, object := range bundle.Objects {
+		switch object := object.(type) {
+		case Location:
+			location = object
+		case Identity:
+			identity = object
+		case Relationship:
+			relationships[object.RelationshipType] = object
+		}
+	}
+	if location.Country != "US" || location.City != "Wichita" || location.AdministrativeArea != "Kansas" ||
+		location.Latitude == nil || *location.Latitude != 37.751 || location.Created != "2026-07-18T00:00:04.000Z" {
+		t.Errorf("location = %+v", location)
+	}
+	if identity.Name != "Example Networks" || identity.IdentityClass != "organization" ||
+		identity.ContactInformation != "1 Example Way, Wichita, KS, 01234, United States" ||
+		identity.Created != "2019-06-28T16:53:01.000Z" || identity.Modified != "2026-07-18T03:19:32.000Z" {
+		t.Errorf("identity = %+v", identity)
+	}
+	if located := relationships[RelationshipLocatedAt]; located.SourceRef != ipv4ID || located.TargetRef != location.ID {
+		t.Errorf("located-at = %+v", located)
+	}
+	if related := relationships[RelationshipRelatedTo]; related.SourceRef != asID || related.TargetRef != identity.ID {
+		t.Errorf("related-to = %+v", related)
+	}
+
+	// The same records in another order produce the same bundle.
+	again := NewBuilder(Config{})
+	if err := again.AddNetblocks(testNetblocks); err != nil {
+		t.Fatalf("AddNetblocks: %v", err)
+	}
+	if err := again.AddRegistry(testRegistry); err != nil {
+		t.Fatalf("AddRegistry: %v", err)
+	}
+	if err := again.AddWhoIs(testWhoIs[1], testWhoIs[0]); err != nil {
+		t.Fatalf("AddWhoIs: %v", err)
+	}
+	first, _ := json.Marshal(bundle)
+	second, _ := json.Marshal(again.Bundle())
+	if string(first) != string(second) {
+		t.Errorf("bundles differ by insertion order:\n%s\n%s", first, second)
+	}
+}
+
+func TestBuilderConvertsNetblockRangesToPrefixes(t *testing.T) {
+	builder := NewBuilder(Config{})
+	if err := builder.AddNetblocks(testNetblocks); err != nil {
+		t.Fatalf("AddNetblocks: %v", err)
+	}
+	var values []string
+	for _, object := range builder.Bundle().Objects {
+		if address, ok := object.(Address); ok {
+			values = append(values, address.Value)
+		}
+	}
+	sort.Strings(values)
+	if want := []string{"198.51.100.0/24", "198.51.101.0/25"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("netblock prefixes = %q, want %q", values, want)
+	}
+}
+
+func TestBuilderConvertsDecodedNetblockRanges(t *testing.T) {
+	record := pwhois.NetblockRecord{Asn: "64500", Netblocks: []pwhois.Netblock{{Name: "EXAMPLE-NET", Range: "198.51.100.0-198.51.101.127"}}}
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		t.Fatalf("marshal netblocks: %v", err)
+	}
+	var decoded pwhois.NetblockRecord
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("unmarshal netblocks: %v", err)
+	}
+
+	builder := NewBuilder(Config{})
+	if err := builder.AddNetblocks(decoded); err != nil {
+		t.Fatalf("AddNetblocks: %v", err)
+	}
+	var values []string
+	for _, object := range builder.Bundle().Objects {
+		if address, ok := object.(Address); ok {
+			values = append(values, address.Value)
+		}
+	}
+	sort.Strings(values)
+	if want := []string{"198.51.100.0/24", "198.51.101.0/25"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("decoded netblock prefixes = %q, want %q", values, want)
+	}
+}
+
+func TestBuilderRejectsUnrepresentableRecords(t *testing.T) {
+	dated := Config{Time: time.Date(2026, 7, 18, 0, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		name string
+		add  func(*Builder) error
+	}{
+		{name: "no IP", add: func(builder *Builder) error { return builder.AddWhoIs(pwhois.WhoIs{OriginAS: "64500"}) }},
+		{name: "bad IP", add: func(builder *Builder) error { return builder.AddWhoIs(pwhois.WhoIs{IP: "192.0.2"}) }},
+		{name: "bad origin", add: func(builder *Builder) error { return builder.AddWhoIs(pwhois.WhoIs{IP: "192.0.2.1", OriginAS: "AS-X"}) }},
+		{name: "undated location", add: func(builder *Builder) error {
+			return builder.AddWhoIs(pwhois.WhoIs{IP: "192.0.2.1", CountryCode: "US"})
+		}},
+		{name: "undated identity", add: func(builder *Builder) error {
+			return builder.AddRegistry(pwhois.RegistryRecord{Asn: "64500", Registry: pwhois.Registry{OrgName: "Example"}})
+		}},
+		{name: "bad registry ASN", add: func(builder *Builder) error { return builder.AddRegistry(pwhois.RegistryRecord{Asn: "4294967296"}) }},
+		{name: "netblock without range", add: func(builder *Builder) error {
+			return builder.AddNetblocks(pwhois.NetblockRecord{Asn: "64500", Netblocks: []pwhois.Netblock{{Name: "EMPTY"}}})
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.add(NewBuilder(Config{})); !errors.Is(err, pwhois.ErrInvalidInput) {
+				t.Errorf("error = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+
+	builder := NewBuilder(dated)
+	if err := builder.AddWhoIs(pwhois.WhoIs{IP: "192.0.2.1", CountryCode: "US"}); err != nil {
+		t.Fatalf("AddWhoIs with Config.Time: %v", err)
+	}
+	for _, object := range builder.Bundle().Objects {
+		if location, ok := object.(Location); ok && location.Created != "2026-07-18T00:00:00.000Z" {
+			t.Errorf("location created = %q, want Config.Time", location.Created)
+		}
+	}
+}
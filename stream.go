@@ -0,0 +1,240 @@
+package pwhois
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+)
+
+// streamQueryContext executes query like executeQueryContext but passes each
+// response line to handle as it is read instead of buffering the response.
+// MaxRecordBytes bounds each line, MaxResponseBytes still bounds the whole
+// response, and the lookup deadline still bounds the whole exchange. Errors
+// returned by handle are returned unchanged.
+func (server WhoisServer) streamQueryContext(ctx context.Context, operation, query string, handle func(line string) error) error {
+	stop, err := server.writeQueryContext(ctx, operation, query)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	limit, recordLimit := server.responseSizeLimit(), server.recordSizeLimit()
+	scanner := bufio.NewScanner(server.Connection)
+	scanner.Buffer(make([]byte, 0, min(recordLimit, responseReadChunkSize)), int(recordLimit))
+	var read int64
+	for scanner.Scan() {
+		line := scanner.Text()
+		read += int64(len(line)) + 1
+		if read > limit {
+			_ = server.Connection.Close()
+			return server.operationError(operation, &ResponseTooLargeError{Limit: limit})
+		}
+		if isRateLimitedResponse(line) {
+			return server.operationError(operation, ErrRateLimited)
+		}
+		if err := handle(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			_ = server.Connection.Close()
+			return server.operationError(operation, &ResponseTooLargeError{Limit: recordLimit})
+		}
+		return server.operationError(operation, classifyContextTransportError(ctx, err))
+	}
+	return nil
+}
+
+// stream connects, streams one query through handle, and closes the
+// connection.
+func (client *Client) stream(ctx context.Context, operation, query string, handle func(line string) error) error {
+	server := client.server
+	if ctx == nil {
+		return invalidInputError("lookup context is required")
+	}
+	probe, err := client.guard.admit(ctx, server, operation, query)
+	if err != nil {
+		return err
+	}
+	err = server.dialStreamContext(ctx, operation, query, handle)
+	client.guard.settle(ctx, server, probe, err)
+	return err
+}
+
+// dialStreamContext connects, streams one query, and closes the connection.
+func (server WhoisServer) dialStreamContext(ctx context.Context, operation, query string, handle func(line string) error) error {
+	if err := server.ConnectContext(ctx); err != nil {
+		return err
+	}
+	defer server.Connection.Close()
+	return server.streamQueryContext(ctx, operation, query, handle)
+}
+
+// StreamIP looks up one or more IP addresses in a single query and calls
+// yield with each record as soon as it has been read, so memory use depends
+// on the largest record rather than the whole response. MaxRecordBytes bounds
+// each record and MaxResponseBytes the whole response; exceeding either fails
+// with ErrResponseTooLarge.
+//
+// Records delivered before a failure are not retracted. If yield returns an
+// error the lookup stops and StreamIP returns that error unchanged. Other
+// failures use the same error classes as LookupIP, including ErrNoRecords
+// when the response held no records.
+func (client *Client) StreamIP(ctx context.Context, ips []string, yield func(WhoIs) error) error {
+	query, err := client.server.FormatIpQuery(ips)
+	if err != nil {
+		return err
+	}
+
+	const operation = "lookup IP"
+	limit := client.server.recordSizeLimit()
+	var (
+		lines        []string
+		recordBytes  int64
+		recordNumber int
+		delivered    int
+	)
+	flush := func() error {
+		recordNumber++
+		record, ok, err := parseIPRecord(recordNumber, lines)
+		lines = lines[:0]
+		recordBytes = 0
+		if err != nil {
+			return client.server.operationError(operation, malformedResponseError(err))
+		}
+		if !ok {
+			return nil
+		}
+		delivered++
+		return yield(record)
+	}
+
+	err = client.stream(ctx, operation, query, func(line string) error {
+		if line == "" {
+			if len(lines) == 0 {
+				return nil
+			}
+			return flush()
+		}
+		recordBytes += int64(len(line)) + 1
+		if recordBytes > limit {
+			return client.server.operationError(operation, &ResponseTooLargeError{Limit: limit})
+		}
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(lines) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	if delivered == 0 {
+		return client.server.operationError(operation, noRecordsError("IP lookup"))
+	}
+	return nil
+}
+
+// StreamRouteView calls yield with each route originated by asn as soon as
+// its line has been read. MaxRecordBytes bounds each line and
+// MaxResponseBytes the whole response. Error handling follows StreamIP.
+func (client *Client) StreamRouteView(ctx context.Context, asn string, yield func(BGPRoute) error) error {
+	normalizedASN, err := normalizeASN(asn)
+	if err != nil {
+		return err
+	}
+	query, err := client.server.FormatRouteViewQuery(normalizedASN)
+	if err != nil {
+		return err
+	}
+
+	const operation = "lookup RouteView"
+	var lineNumber, delivered int
+	err = client.stream(ctx, operation, query, func(line string) error {
+		lineNumber++
+		route, ok, err := parseBGPRouteLine(lineNumber, line)
+		if err != nil {
+			return client.server.operationError(operation, malformedResponseError(err))
+		}
+		if !ok {
+			return nil
+		}
+		delivered++
+		return yield(route)
+	})
+	if err != nil {
+		return err
+	}
+	if delivered == 0 {
+		return client.server.operationError(operation, noRecordsError("RouteView lookup"))
+	}
+	return nil
+}
+
+// StreamNetblock calls yield with each netblock announced by asn as soon as
+// its line has been read, and returns the record built from the response
+// header once the response ends. The returned record's Netblocks is nil.
+// MaxRecordBytes bounds each line and MaxResponseBytes the whole response.
+// Error handling follows StreamIP.
+func (client *Client) StreamNetblock(ctx context.Context, asn string, yield func(Netblock) error) (NetblockRecord, error) {
+	normalizedASN, err := normalizeASN(asn)
+	if err != nil {
+		return NetblockRecord{}, err
+	}
+	query, err := client.server.FormatNetblockQuery(normalizedASN)
+	if err != nil {
+		return NetblockRecord{}, err
+	}
+
+	const operation = "lookup netblock"
+	header := make(map[string]string)
+	var headerLines, blockNumber int
+	err = client.stream(ctx, operation, query, func(line string) error {
+		if len(line) == 0 {
+			return nil
+		}
+		if strings.HasPrefix(line, "*>") {
+			blockNumber++
+			block, err := parseNetblockBlock(blockNumber, strings.TrimPrefix(line, "*>"))
+			if err != nil {
+				return client.server.operationError(operation, malformedResponseError(err))
+			}
+			return yield(block)
+		}
+		if !strings.Contains(line, ": ") {
+			return nil
+		}
+		if strings.HasPrefix(line, "Error: ") {
+			return client.server.operationError(operation, noRecordsError("netblock lookup"))
+		}
+		headerLines++
+		key, value, err := parseResponseLine(line)
+		if err != nil {
+			return client.server.operationError(operation, malformedResponseError(err))
+		}
+		header[key] = value
+		return nil
+	})
+	if err != nil {
+		return NetblockRecord{}, err
+	}
+	if headerLines == 0 && blockNumber == 0 {
+		return NetblockRecord{}, client.server.operationError(operation, noRecordsError("netblock lookup"))
+	}
+	if headerLines == 0 {
+		return NetblockRecord{}, client.server.operationError(operation, malformedResponseError(errors.New("no header found")))
+	}
+	if blockNumber == 0 {
+		return NetblockRecord{}, client.server.operationError(operation, malformedResponseError(errors.New("no network blocks found")))
+	}
+
+	record, err := parseNetblockHeader(normalizedASN, header)
+	if err != nil {
+		return NetblockRecord{}, client.server.operationError(operation, malformedResponseError(err))
+	}
+	return record, nil
+}
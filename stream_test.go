@@ -0,0 +1,211 @@
+package pwhois
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStreamIPBoundsRecordsAndWholeResponse(t *testing.T) {
+	server, _ := startIPBatchServer(t, ipRecords)
+	server.MaxResponseBytes = 256
+	client, err := NewClient(ClientConfig{Server: server})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	ips := make([]string, 0, 40)
+	for index := 1; index <= 40; index++ {
+		ips = append(ips, fmt.Sprintf("192.0.2.%d", index))
+	}
+
+	if _, err := client.LookupIP(context.Background(), ips...); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("buffered lookup error = %v, want ErrResponseTooLarge", err)
+	}
+	calls := 0
+	err = client.StreamIP(context.Background(), ips, func(WhoIs) error {
+		calls++
+		return nil
+	})
+	var tooLarge *ResponseTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 256 || calls == len(ips) {
+		t.Fatalf("stream over response limit = %v after %d records, want ErrResponseTooLarge at 256 bytes", err, calls)
+	}
+
+	// A small record bound with a large response bound streams everything.
+	server.MaxRecordBytes = 64
+	server.MaxResponseBytes = 1 << 20
+	if client, err = NewClient(ClientConfig{Server: server}); err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	var streamed []string
+	err = client.StreamIP(context.Background(), ips, func(record WhoIs) error {
+		streamed = append(streamed, record.IP)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream IP: %v", err)
+	}
+	if strings.Join(streamed, ",") != strings.Join(ips, ",") {
+		t.Fatalf("streamed records = %v, want %v", streamed, ips)
+	}
+
+	large, _ := startIPBatchServer(t, func([]string) string {
+		return "IP: 192.0.2.1\nOrg-Name: " + strings.Repeat("x", 80) + "\n"
+	})
+	large.MaxRecordBytes = 64
+	if client, err = NewClient(ClientConfig{Server: large}); err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	err = client.StreamIP(context.Background(), []string{"192.0.2.1"}, func(WhoIs) error { return nil })
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 64 {
+		t.Fatalf("stream over record limit = %v, want ErrResponseTooLarge at 64 bytes", err)
+	}
+}
+
+func TestStreamIPFailures(t *testing.T) {
+	stop := errors.New("stop streaming")
+	tests := []struct {
+		name     string
+		response string
+		yield    func(WhoIs) error
+		want     error
+		maxCalls int
+	}{
+		{
+			name:     "record over limit",
+			response: "IP: 192.0.2.1\nOrg-Name: " + strings.Repeat("x", 100) + "\nCity: " + strings.Repeat("y", 100) + "\n",
+			want:     ErrResponseTooLarge,
+		},
+		{
+			name:     "rate limited",
+			response: "Error: query limit exceeded\n",
+			want:     ErrRateLimited,
+		},
+		{
+			name:     "malformed second record",
+			response: "IP: 192.0.2.1\n\nIP 192.0.2.2\n",
+			want:     ErrMalformedResponse,
+			maxCalls: 1,
+		},
+		{
+			name:     "no records",
+			response: "\n\n",
+			want:     ErrNoRecords,
+		},
+		{
+			name:     "yield error",
+			response: ipRecords([]string{"192.0.2.1", "192.0.2.2"}),
+			yield:    func(WhoIs) error { return stop },
+			want:     stop,
+			maxCalls: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, _ := startIPBatchServer(t, func([]string) string { return test.response })
+			server.MaxResponseBytes = 128
+			client, err := NewClient(ClientConfig{Server: server})
+			if err != nil {
+				t.Fatalf("new client: %v", err)
+			}
+
+			calls := 0
+			err = client.StreamIP(context.Background(), []string{"192.0.2.1", "192.0.2.2"}, func(record WhoIs) error {
+				calls++
+				if test.yield != nil {
+					return test.yield(record)
+				}
+				return nil
+			})
+			if !errors.Is(err, test.want) {
+				t.Fatalf("error = %v, want %v", err, test.want)
+			}
+			if calls > test.maxCalls {
+				t.Errorf("yield calls = %d, want at most %d", calls, test.maxCalls)
+			}
+			if test.want != stop {
+				assertOperationError(t, err, "lookup IP", server.ServerAddressString())
+			}
+		})
+	}
+}
+
+func TestStreamRouteViewAndNetblock(t *testing.T) {
+	const routeRequest = "app=\"GO pwhois Module\" routeview source-as=64500\n"
+	routeServer, routeResults := startLoopbackProtocolServer(t, loopbackProtocolScript{
+		expectedRequest: routeRequest,
+		responseChunks: []string{
+			"Origin-AS: 64500\n*> 192.0.2.0/24 | Jul 18 2026 00:00:04 | Jul 18 2026 00:00:04 | May 28 2026 06:56:01 | 192.0.2.254 | 64501 64500\n*> 198.51",
+			".100.0/24 | Jul 18 2026 00:00:04 | Jul 18 2026 00:00:04 | May 28 2026 06:56:01 | 192.0.2.254 | 64501 64500\n",
+		},
+	})
+	routeClient, err := NewClient(ClientConfig{Server: routeServer})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	var prefixes []string
+	err = routeClient.StreamRouteView(context.Background(), "AS64500", func(route BGPRoute) error {
+		prefixes = append(prefixes, route.PrefixNet.String())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream RouteView: %v", err)
+	}
+	if strings.Join(prefixes, ",") != "192.0.2.0/24,198.51.100.0/24" {
+		t.Errorf("streamed prefixes = %v", prefixes)
+	}
+	waitForLoopbackProtocol(t, routeResults, routeRequest)
+
+	const netblockRequest = "app=\"GO pwhois Module\" netblock source-as=64500\n"
+	netblockServer, netblockResults := startLoopbackProtocolServer(t, loopbackProtocolScript{
+		expectedRequest: netblockRequest,
+		responseChunks: []string{
+			"Origin-AS: 64500\nOrg-Name: Example Networks\n",
+			"*> 192.0.2.0 - 192.0.2.255 | EXAMPLE-NET | reassignment | 2019-05-25 | 2019-09-25 | Jun 28 2019 16:53:01 | Jul 18 2026 03:19:32 | TEST\n",
+			"*> 2001:db8:: - 2001:db8::ffff | EXAMPLE-V6 | reassignment | 2019-05-25 | 2019-09-25 | Jun 28 2019 16:53:01 | Jul 18 2026 03:19:32 | TEST\n",
+		},
+	})
+	netblockClient, err := NewClient(ClientConfig{Server: netblockServer})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	var names []string
+	record, err := netblockClient.StreamNetblock(context.Background(), "64500", func(block Netblock) error {
+		names = append(names, block.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream netblock: %v", err)
+	}
+	if record.Asn != "64500" || record.OrgName != "Example Networks" || record.Netblocks != nil {
+		t.Errorf("netblock header = %+v", record)
+	}
+	if strings.Join(names, ",") != "EXAMPLE-NET,EXAMPLE-V6" {
+		t.Errorf("streamed netblocks = %v", names)
+	}
+	waitForLoopbackProtocol(t, netblockResults, netblockRequest)
+}
+
+func TestStreamNetblockReportsServerError(t *testing.T) {
+	const request = "app=\"GO pwhois Module\" netblock source-as=64500\n"
+	server, results := startLoopbackProtocolServer(t, loopbackProtocolScript{
+		expectedRequest: request,
+		responseChunks:  []string{"Error: No netblock found in registry database for org-id=EXAMPLE\n"},
+	})
+	client, err := NewClient(ClientConfig{Server: server})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	_, err = client.StreamNetblock(context.Background(), "64500", func(Netblock) error {
+		t.Error("yield called for an error response")
+		return nil
+	})
+	if !errors.Is(err, ErrNoRecords) {
+		t.Fatalf("error = %v, want ErrNoRecords", err)
+	}
+	waitForLoopbackProtocol(t, results, request)
+}
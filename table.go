@@ -0,0 +1,369 @@
+package pwhois
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TableFormat selects the output format of a TableWriter.
+type TableFormat string
+
+const (
+	// TableCSV writes comma-separated values as described in RFC 4180.
+	TableCSV TableFormat = "csv"
+	// TableTSV writes tab-separated values. A value containing a tab, quote,
+	// or line break is quoted the way TableCSV quotes it.
+	TableTSV TableFormat = "tsv"
+	// TableJSONLines writes one JSON object per row, keyed by column name in
+	// column order.
+	TableJSONLines TableFormat = "jsonl"
+)
+
+// TableConfig configures a TableWriter.
+type TableConfig struct {
+	// Format is the output format. The zero value selects TableCSV.
+	Format TableFormat
+	// Columns names the columns to write, in order. Empty selects every
+	// column of the record type in its stable order.
+	Columns []string
+	// NoHeader omits the CSV or TSV header row. JSON lines have no header.
+	NoHeader bool
+}
+
+// TableWriter flattens records of type T into rows of named columns and
+// streams them as CSV, TSV, or JSON lines. Column names are the JSON keys of
+// the record fields; nested routes and netblocks become one row each,
+// repeating the fields of the record they belong to.
+//
+// In CSV and TSV, times are RFC 3339 in UTC and AS paths are space-separated
+// ASNs, and a zero time is empty. In JSON lines, numbers, booleans, and AS
+// path arrays keep their JSON types and a zero time is null. Text fields
+// fall back to their typed counterparts the way the Encode functions do.
+//
+// Output is buffered; call Flush after the last Write. A TableWriter is not
+// safe for concurrent use.
+type TableWriter[T any] struct {
+	columns []string
+	header  bool
+	rows    func(T) [][]any
+	csv     *csv.Writer
+	json    *bufio.Writer
+}
+
+// tableColumn names one column of rows of type R and extracts its value.
+type tableColumn[R any] struct {
+	name  string
+	value func(R) any
+}
+
+// routeRow is one BGPRoute with the ASN of the BGPRoutes it belongs to.
+type routeRow struct {
+	asn   string
+	route BGPRoute
+}
+
+// netblockRow is one Netblock with the NetblockRecord it belongs to.
+type netblockRow struct {
+	record *NetblockRecord
+	block  Netblock
+}
+
+var whoIsColumns = []tableColumn[WhoIs]{
+	{"ip", func(record WhoIs) any { return textOrAddr(record.IP, record.IPAddr) }},
+	{"origin_asn", func(record WhoIs) any { return record.OriginAS }},
+	{"prefix", func(record WhoIs) any { return textOrPrefix(record.Prefix, record.PrefixNet) }},
+	{"org_name", func(record WhoIs) any { return record.OrgName }},
+	{"asn_path", func(record WhoIs) any {
+		if record.AsnPath == "" && len(record.ASPath) > 0 {
+			return record.ASPath.String()
+		}
+		return record.AsnPath
+	}},
+	{"asn_org_name", func(record WhoIs) any { return record.AsnOrgName }},
+	{"net_name", func(record WhoIs) any { return record.NetworkName }},
+	{"cache_date", func(record WhoIs) any { return record.CacheDate }},
+	{"latitude", func(record WhoIs) any { return record.Latitude }},
+	{"longitude", func(record WhoIs) any { return record.Longitude }},
+	{"city", func(record WhoIs) any { return record.City }},
+	{"region", func(record WhoIs) any { return record.Region }},
+	{"country", func(record WhoIs) any { return record.Country }},
+	{"country_code", func(record WhoIs) any { return record.CountryCode }},
+	{"route_originated_date", func(record WhoIs) any { return record.RouteOriginatedDate }},
+	{"route_originated_ts", func(record WhoIs) any { return record.RouteOriginatedTS }},
+}
+
+var routeColumns = []tableColumn[routeRow]{
+	{"asn", func(row routeRow) any { return row.asn }},
+	{"prefix", func(row routeRow) any { return textOrPrefix(row.route.Prefix, row.route.PrefixNet) }},
+	{"create_date", func(row routeRow) any { return row.route.CreateDate }},
+	{"modify_date", func(row routeRow) any { return row.route.ModifyDate }},
+	{"originated_date", func(row routeRow) any { return row.route.OriginatedDate }},
+	{"next_hop", func(row routeRow) any { return textOrAddr(row.route.NextHop, row.route.NextHopAddr) }},
+	{"as_path", func(row routeRow) any { return row.route.ASPath }},
+}
+
+var registryColumns = []tableColumn[RegistryRecord]{
+	{"asn", func(record RegistryRecord) any { return record.Asn }},
+	{"org_record", func(record RegistryRecord) any { return record.Registry.OrgRecord }},
+	{"org_id", func(record RegistryRecord) any { return record.Registry.OrgID }},
+	{"org_name", func(record RegistryRecord) any { return record.Registry.OrgName }},
+	{"can_allocate", func(record RegistryRecord) any { return record.Registry.CanAllocate }},
+	{"source", func(record RegistryRecord) any { return record.Registry.Source }},
+	{"street_1", func(record RegistryRecord) any { return record.Registry.Street1 }},
+	{"postal_code", func(record RegistryRecord) any { return record.Registry.PostalCode }},
+	{"city", func(record RegistryRecord) any { return record.Registry.City }},
+	{"region", func(record RegistryRecord) any { return record.Registry.Region }},
+	{"country", func(record RegistryRecord) any { return record.Registry.Country }},
+	{"country_code", func(record RegistryRecord) any { return record.Registry.CountryCode }},
+	{"register_date", func(record RegistryRecord) any { return record.Registry.RegisterDate }},
+	{"update_date", func(record RegistryRecord) any { return record.Registry.UpdateDate }},
+	{"create_date", func(record RegistryRecord) any { return record.Registry.CreateDate }},
+	{"modify_date", func(record RegistryRecord) any { return record.Registry.ModifyDate }},
+	{"admin_handle_0", func(record RegistryRecord) any { return record.Registry.AdminHandle0 }},
+	{"abuse_handle_0", func(record RegistryRecord) any { return record.Registry.AbuseHandle0 }},
+	{"tech_handle_0", func(record RegistryRecord) any { return record.Registry.TechHandle0 }},
+	{"comment", func(record RegistryRecord) any { return record.Registry.Comment }},
+}
+
+var netblockColumns = []tableColumn[netblockRow]{
+	{"asn", func(row netblockRow) any { return row.record.Asn }},
+	{"origin_asn", func(row netblockRow) any { return row.record.OriginAs }},
+	{"as_source", func(row netblockRow) any { return row.record.ASSource }},
+	{"org_id", func(row netblockRow) any { return row.record.OrgID }},
+	{"org", func(row netblockRow) any { return row.record.Org }},
+	{"as", func(row netblockRow) any { return row.record.AS }},
+	{"org_name", func(row netblockRow) any { return row.record.OrgName }},
+	{"org_source", func(row netblockRow) any { return row.record.OrgSource }},
+	{"net_name", func(row netblockRow) any { return row.block.Name }},
+	{"net_type", func(row netblockRow) any { return row.block.Type }},
+	{"net_range", func(row netblockRow) any {
+		if row.block.Range == "" && row.block.RangeStart.IsValid() && row.block.RangeEnd.IsValid() {
+			return row.block.RangeStart.String() + "-" + row.block.RangeEnd.String()
+		}
+		return row.block.Range
+	}},
+	{"register_date", func(row netblockRow) any { return row.block.RegisterDate }},
+	{"update_date", func(row netblockRow) any { return row.block.UpdateDate }},
+	{"create_date", func(row netblockRow) any { return row.block.CreateDate }},
+	{"modify_date", func(row netblockRow) any { return row.block.ModifyDate }},
+	{"source", func(row netblockRow) any { return row.block.Source }},
+}
+
+// NewWhoIsTableWriter returns a TableWriter that writes one row per WhoIs
+// record. Its columns are ip, origin_asn, prefix, org_name, asn_path,
+// asn_org_name, net_name, cache_date, latitude, longitude, city, region,
+// country, country_code, route_originated_date, and route_originated_ts.
+func NewWhoIsTableWriter(w io.Writer, config TableConfig) (*TableWriter[WhoIs], error) {
+	return newTableWriter(w, config, whoIsColumns, func(record WhoIs) []WhoIs {
+		return []WhoIs{record}
+	})
+}
+
+// NewRouteTableWriter returns a TableWriter that writes one row per route of
+// a BGPRoutes value. Its columns are asn, the ASN the routes were looked up
+// for, followed by prefix, create_date, modify_date, originated_date,
+// next_hop, and as_path.
+func NewRouteTableWriter(w io.Writer, config TableConfig) (*TableWriter[BGPRoutes], error) {
+	return newTableWriter(w, config, routeColumns, func(routes BGPRoutes) []routeRow {
+		rows := make([]routeRow, len(routes.Routes))
+		for index, route := range routes.Routes {
+			rows[index] = routeRow{asn: routes.Asn, route: route}
+		}
+		return rows
+	})
+}
+
+// NewRegistryTableWriter returns a TableWriter that writes one row per
+// RegistryRecord. Its columns are asn followed by the Registry fields:
+// org_record, org_id, org_name, can_allocate, source, street_1,
+// postal_code, city, region, country, country_code, register_date,
+// update_date, create_date, modify_date, admin_handle_0, abuse_handle_0,
+// tech_handle_0, and comment.
+func NewRegistryTableWriter(w io.Writer, config TableConfig) (*TableWriter[RegistryRecord], error) {
+	return newTableWriter(w, config, registryColumns, func(record RegistryRecord) []RegistryRecord {
+		return []RegistryRecord{record}
+	})
+}
+
+// NewNetblockTableWriter returns a TableWriter that writes one row per
+// netblock of a NetblockRecord. Its columns are the organization fields asn,
+// origin_asn, as_source, org_id, org, as, org_name, and org_source, followed
+// by the netblock fields net_name, net_type, net_range, register_date,
+// update_date, create_date, modify_date, and source.
+func NewNetblockTableWriter(w io.Writer, config TableConfig) (*TableWriter[NetblockRecord], error) {
+	return newTableWriter(w, config, netblockColumns, func(record NetblockRecord) []netblockRow {
+		rows := make([]netblockRow, len(record.Netblocks))
+		for index, block := range record.Netblocks {
+			rows[index] = netblockRow{record: &record, block: block}
+		}
+		return rows
+	})
+}
+
+func newTableWriter[T, R any](w io.Writer, config TableConfig, columns []tableColumn[R], flatten func(T) []R) (*TableWriter[T], error) {
+	if w == nil {
+		return nil, invalidInputError("table writer requires an io.Writer")
+	}
+
+	byName := make(map[string]tableColumn[R], len(columns))
+	for _, column := range columns {
+		byName[column.name] = column
+	}
+	selected := columns
+	if len(config.Columns) > 0 {
+		selected = make([]tableColumn[R], 0, len(config.Columns))
+		seen := make(map[string]bool, len(config.Columns))
+		for _, name := range config.Columns {
+			column, ok := byName[name]
+			if !ok {
+				return nil, invalidInputError(fmt.Sprintf("unknown table column %q", name))
+			}
+			if seen[name] {
+				return nil, invalidInputError(fmt.Sprintf("table column %q selected more than once", name))
+			}
+			seen[name] = true
+			selected = append(selected, column)
+		}
+	}
+
+	writer := &TableWriter[T]{columns: make([]string, len(selected))}
+	for index, column := range selected {
+		writer.columns[index] = column.name
+	}
+	writer.rows = func(record T) [][]any {
+		flattened := flatten(record)
+		rows := make([][]any, len(flattened))
+		for index, row := range flattened {
+			values := make([]any, len(selected))
+			for position, column := range selected {
+				values[position] = column.value(row)
+			}
+			rows[index] = values
+		}
+		return rows
+	}
+
+	switch config.Format {
+	case "", TableCSV:
+		writer.csv = csv.NewWriter(w)
+	case TableTSV:
+		writer.csv = csv.NewWriter(w)
+		writer.csv.Comma = '\t'
+	case TableJSONLines:
+		writer.json = bufio.NewWriter(w)
+	default:
+		return nil, invalidInputError(fmt.Sprintf("unknown table format %q", config.Format))
+	}
+	writer.header = writer.csv != nil && !config.NoHeader
+	return writer, nil
+}
+
+// Columns returns the names of the columns the writer writes, in order.
+func (writer *TableWriter[T]) Columns() []string {
+	return append([]string(nil), writer.columns...)
+}
+
+// Write writes the rows of record, preceded by the header row if it has not
+// been written yet. A record with no routes or netblocks writes no rows.
+func (writer *TableWriter[T]) Write(record T) error {
+	if err := writer.writeHeader(); err != nil {
+		return err
+	}
+	for _, row := range writer.rows(record) {
+		if err := writer.writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes the header row if no record was written, so that empty output
+// still names its columns, and flushes buffered rows to the underlying
+// writer.
+func (writer *TableWriter[T]) Flush() error {
+	if err := writer.writeHeader(); err != nil {
+		return err
+	}
+	if writer.json != nil {
+		return writer.json.Flush()
+	}
+	writer.csv.Flush()
+	return writer.csv.Error()
+}
+
+func (writer *TableWriter[T]) writeHeader() error {
+	if !writer.header {
+		return nil
+	}
+	writer.header = false
+	return writer.csv.Write(writer.columns)
+}
+
+func (writer *TableWriter[T]) writeRow(values []any) error {
+	if writer.csv != nil {
+		cells := make([]string, len(values))
+		for index, value := range values {
+			cells[index] = formatTableCell(value)
+		}
+		return writer.csv.Write(cells)
+	}
+
+	line := []byte{'{'}
+	for index, value := range values {
+		if index > 0 {
+			line = append(line, ',')
+		}
+		if moment, ok := value.(time.Time); ok {
+			value = nil
+			if !moment.IsZero() {
+				value = formatTableTime(moment)
+			}
+		}
+		key, _ := json.Marshal(writer.columns[index])
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("encode table column %s: %w", writer.columns[index], err)
+		}
+		line = append(line, key...)
+		line = append(line, ':')
+		line = append(line, encoded...)
+	}
+	line = append(line, '}', '\n')
+	_, err := writer.json.Write(line)
+	return err
+}
+
+func formatTableCell(value any) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case time.Time:
+		if value.IsZero() {
+			return ""
+		}
+		return formatTableTime(value)
+	case []int:
+		asns := make([]string, len(value))
+		for index, asn := range value {
+			asns[index] = strconv.Itoa(asn)
+		}
+		return strings.Join(asns, " ")
+	}
+	return fmt.Sprint(value)
+}
+
+func formatTableTime(value time.Time) string {
+	return value.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,142 @@
+package pwhois
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestTableWritersFlattenRecords(t *testing.T) {
+	records, err := parseIpResponse(encodeIPFixture)
+	if err != nil {
+		t.Fatalf("parse IP fixture: %v", err)
+	}
+	routes, err := parseBgpResponse(encodeRouteViewFixture)
+	if err != nil {
+		t.Fatalf("parse routeview fixture: %v", err)
+	}
+	registry, err := parseRegistryResponse(encodeRegistryFixture)
+	if err != nil {
+		t.Fatalf("parse registry fixture: %v", err)
+	}
+	netblocks, err := parseNetblockResponse("64500", encodeNetblockFixture)
+	if err != nil {
+		t.Fatalf("parse netblock fixture: %v", err)
+	}
+
+	var output bytes.Buffer
+	whoIs, err := NewWhoIsTableWriter(&output, TableConfig{})
+	if err != nil {
+		t.Fatalf("NewWhoIsTableWriter: %v", err)
+	}
+	for _, record := range records {
+		if err := whoIs.Write(record); err != nil {
+			t.Fatalf("write WhoIs: %v", err)
+		}
+	}
+	assertTable(t, "WhoIs CSV", whoIs.Flush, &output,
+		"ip,origin_asn,prefix,org_name,asn_path,asn_org_name,net_name,cache_date,latitude,longitude,city,region,country,country_code,route_originated_date,route_originated_ts\n"+
+			"192.0.2.1,64500,192.0.2.0/24,Example Networks,\"64501 {64502,64503} 64500\",Example AS,EXAMPLE-NET,2026-07-18T00:00:04Z,37.751,-97.822,Wichita,Kansas,United States,US,2026-05-28T06:56:01Z,1779951361\n"+
+			"2001:db8::1,64501,2001:db8::/32,,,,,,0,0,,,,,,0\n")
+
+	route, err := NewRouteTableWriter(&output, TableConfig{Format: TableTSV})
+	if err != nil {
+		t.Fatalf("NewRouteTableWriter: %v", err)
+	}
+	if err := route.Write(BGPRoutes{Asn: "64500", Routes: routes}); err != nil {
+		t.Fatalf("write routes: %v", err)
+	}
+	assertTable(t, "route TSV", route.Flush, &output,
+		"asn\tprefix\tcreate_date\tmodify_date\toriginated_date\tnext_hop\tas_path\n"+
+			"64500\t192.0.2.0/24\t2026-07-18T00:00:04Z\t2026-07-18T00:00:04Z\t2026-05-28T06:56:01Z\t192.0.2.254\t64501 64500\n"+
+			"64500\t2001:db8::/32\t2026-07-18T00:00:04Z\t2026-07-19T01:02:03Z\t2026-05-28T06:56:01Z\t2001:db8::fe\t64500\n")
+
+	table, err := NewRegistryTableWriter(&output, TableConfig{Format: TableJSONLines, Columns: []string{"asn", "org_name", "can_allocate", "update_date", "comment"}})
+	if err != nil {
+		t.Fatalf("NewRegistryTableWriter: %v", err)
+	}
+	for _, record := range []RegistryRecord{{Asn: "64500", Registry: registry[0]}, {Asn: "64501"}} {
+		if err := table.Write(record); err != nil {
+			t.Fatalf("write registry: %v", err)
+		}
+	}
+	assertTable(t, "registry JSON lines", table.Flush, &output,
+		`{"asn":"64500","org_name":"Example Registry Organization","can_allocate":true,"update_date":"2019-09-25T10:11:12Z","comment":"Example: a comment with a delimiter"}`+"\n"+
+			`{"asn":"64501","org_name":"","can_allocate":false,"update_date":null,"comment":""}`+"\n")
+
+	netblock, err := NewNetblockTableWriter(&output, TableConfig{Columns: []string{"net_range", "org_name", "asn", "source"}, NoHeader: true})
+	if err != nil {
+		t.Fatalf("NewNetblockTableWriter: %v", err)
+	}
+	if err := netblock.Write(netblocks[0]); err != nil {
+		t.Fatalf("write netblocks: %v", err)
+	}
+	assertTable(t, "netblock CSV", netblock.Flush, &output,
+		"192.0.2.0-192.0.2.255,Example Networks,64500,TEST\n"+
+			"2001:db8::-2001:db8::ffff,Example Networks,64500,TEST\n")
+	if got, want := netblock.Columns(), []string{"net_range", "org_name", "asn", "source"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Columns = %q, want %q", got, want)
+	}
+}
+
+func TestTableWritersStreamJSONLinesAndEmptyTables(t *testing.T) {
+	var output bytes.Buffer
+	route, err := NewRouteTableWriter(&output, TableConfig{Format: TableJSONLines})
+	if err != nil {
+		t.Fatalf("NewRouteTableWriter: %v", err)
+	}
+	err = route.Write(BGPRoutes{Asn: "64500", Routes: []BGPRoute{{
+		PrefixNet:   netip.MustParsePrefix("192.0.2.0/24"),
+		NextHopAddr: netip.MustParseAddr("192.0.2.254"),
+		ASPath:      []int{64501, 64500},
+	}}})
+	if err == nil {
+		err = route.Flush()
+	}
+	var row map[string]any
+	if err != nil || json.Unmarshal(output.Bytes(), &row) != nil {
+		t.Fatalf("route JSON line = %q, %v", output.String(), err)
+	}
+	if row["prefix"] != "192.0.2.0/24" || row["next_hop"] != "192.0.2.254" || row["create_date"] != nil ||
+		!reflect.DeepEqual(row["as_path"], []any{64501.0, 64500.0}) {
+		t.Errorf("route JSON line = %q", output.String())
+	}
+
+	output.Reset()
+	netblock, err := NewNetblockTableWriter(&output, TableConfig{Columns: []string{"asn", "net_name"}})
+	if err != nil {
+		t.Fatalf("NewNetblockTableWriter: %v", err)
+	}
+	if err := netblock.Write(NetblockRecord{Asn: "64500"}); err != nil {
+		t.Fatalf("write netblocks: %v", err)
+	}
+	assertTable(t, "netblock without blocks", netblock.Flush, &output, "asn,net_name\n")
+}
+
+func TestTableWritersRejectBadConfig(t *testing.T) {
+	for _, config := range []TableConfig{
+		{Format: "xml"},
+		{Columns: []string{"ip", "asn"}},
+		{Columns: []string{"ip", "ip"}},
+	} {
+		if _, err := NewWhoIsTableWriter(&bytes.Buffer{}, config); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("NewWhoIsTableWriter(%+v) error = %v, want ErrInvalidInput", config, err)
+		}
+	}
+	if _, err := NewRegistryTableWriter(nil, TableConfig{}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("NewRegistryTableWriter(nil) error = %v, want ErrInvalidInput", err)
+	}
+}
+
+// assertTable flushes a table writer, compares its output with want, and
+// resets output for the next table.
+func assertTable(t *testing.T, name string, flush func() error, output *bytes.Buffer, want string) {
+	t.Helper()
+	if err := flush(); err != nil || output.String() != want {
+		t.Errorf("%s = %q, %v\nwant %q", name, output.String(), err, want)
+	}
+	output.Reset()
+}